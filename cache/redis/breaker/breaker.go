@@ -0,0 +1,233 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open") //熔断器打开，请求被直接拒绝
+
+// State 熔断器状态
+type State int
+
+const (
+	Closed   State = iota //关闭：请求正常放行，统计失败率
+	Open                  //打开：请求直接失败，OpenTimeout 后进入半开
+	HalfOpen              //半开：放行少量试探请求，全部成功则关闭，任一失败重新打开
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Settings 熔断器配置，零值字段使用默认值
+type Settings struct {
+	WindowSize       int           //统计最近多少次调用的结果，默认 100
+	MinRequests      int           //窗口内调用次数达到该值才计算失败率，默认 10
+	FailureRate      float64       //失败率达到该值（0~1）时打开，默认 0.5
+	OpenTimeout      time.Duration //打开多久后进入半开，默认 5 秒
+	HalfOpenRequests int           //半开状态允许的试探请求数，默认 3
+	// OnStateChange 状态变化回调，在持有锁时调用，不要在回调中访问熔断器
+	OnStateChange func(from, to State)
+}
+
+// Stats 熔断器运行状态快照
+type Stats struct {
+	State       State
+	Requests    int       //窗口内的调用次数
+	Failures    int       //窗口内的失败次数
+	FailureRate float64   //窗口内的失败率
+	OpenedAt    time.Time //最近一次打开的时间
+	LastError   string    //最近一次失败的错误信息
+}
+
+// Token Allow 放行请求时返回的凭证，请求结束后原样交给 Done
+// 凭证记录放行时的状态代数，状态切换后才结束的请求（例如关闭状态放行的慢请求在半开时才返回）不会被算作新状态的请求
+type Token struct {
+	generation uint64
+}
+
+// Breaker 基于滑动窗口失败率的熔断器，可安全地被多个goroutine同时使用
+type Breaker struct {
+	settings Settings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	window   []bool //环形缓冲区，true 表示失败
+	pos      int
+	filled   int
+	failures int
+	openedAt time.Time
+	lastErr  string
+
+	generation       uint64 //状态代数，每次切换状态加一
+	halfOpenInFlight int
+	halfOpenSuccess  int
+}
+
+// New 构造熔断器
+func New(settings Settings) *Breaker {
+	if settings.WindowSize <= 0 {
+		settings.WindowSize = 100
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = 10
+	}
+	if settings.FailureRate <= 0 || settings.FailureRate > 1 {
+		settings.FailureRate = 0.5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 5 * time.Second
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 3
+	}
+	return &Breaker{
+		settings: settings,
+		now:      time.Now,
+		window:   make([]bool, settings.WindowSize),
+	}
+}
+
+// Allow 判断请求是否放行，放行后必须用返回的 Token 调用 Done 报告结果；熔断打开时返回 ErrOpen
+func (b *Breaker) Allow() (Token, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.settings.OpenTimeout {
+			return Token{}, ErrOpen
+		}
+		b.setState(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.halfOpenInFlight+b.halfOpenSuccess >= b.settings.HalfOpenRequests {
+			return Token{}, ErrOpen
+		}
+		b.halfOpenInFlight++
+	}
+	return Token{generation: b.generation}, nil
+}
+
+// Done 报告放行请求的结果，err 为 nil 表示成功
+// 放行后熔断器已经切换过状态的，结果只更新 LastError，不参与新状态的统计
+func (b *Breaker) Done(token Token, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+	if token.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case HalfOpen:
+		b.halfOpenInFlight--
+		if err != nil {
+			b.trip()
+			return
+		}
+		b.halfOpenSuccess++
+		if b.halfOpenSuccess >= b.settings.HalfOpenRequests {
+			b.setState(Closed)
+		}
+	case Closed:
+		b.record(err != nil)
+		if b.filled >= b.settings.MinRequests &&
+			float64(b.failures)/float64(b.filled) >= b.settings.FailureRate {
+			b.trip()
+		}
+	}
+}
+
+// Do 在熔断保护下执行 fn
+func (b *Breaker) Do(fn func() error) error {
+	token, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.Done(token, err)
+	return err
+}
+
+// State 当前状态（打开超过 OpenTimeout 时视为半开）
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// Stats 运行状态快照
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Stats{
+		State:     b.currentState(),
+		Requests:  b.filled,
+		Failures:  b.failures,
+		OpenedAt:  b.openedAt,
+		LastError: b.lastErr,
+	}
+	if b.filled > 0 {
+		s.FailureRate = float64(b.failures) / float64(b.filled)
+	}
+	return s
+}
+
+func (b *Breaker) currentState() State {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// record 把结果写入环形窗口
+func (b *Breaker) record(failed bool) {
+	if b.filled == len(b.window) {
+		if b.window[b.pos] {
+			b.failures--
+		}
+	} else {
+		b.filled++
+	}
+	b.window[b.pos] = failed
+	if failed {
+		b.failures++
+	}
+	b.pos = (b.pos + 1) % len(b.window)
+}
+
+func (b *Breaker) trip() {
+	b.openedAt = b.now()
+	b.setState(Open)
+}
+
+// setState 切换状态并重置对应的计数
+func (b *Breaker) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.halfOpenInFlight, b.halfOpenSuccess = 0, 0
+	if to == Closed {
+		clear(b.window)
+		b.pos, b.filled, b.failures = 0, 0, 0
+	}
+	if b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,162 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+// newTestBreaker 使用可控时钟的熔断器
+func newTestBreaker(now *time.Time) *Breaker {
+	b := New(Settings{WindowSize: 10, MinRequests: 4, FailureRate: 0.5, OpenTimeout: time.Second, HalfOpenRequests: 2})
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestBreakerOpensOnFailureRate(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+
+	// 未达到 MinRequests 不打开
+	for i := 0; i < 3; i++ {
+		_ = b.Do(func() error { return errDown })
+	}
+	if s := b.State(); s != Closed {
+		t.Fatalf("state after 3 failures = %v, want closed", s)
+	}
+	_ = b.Do(func() error { return errDown })
+	if s := b.State(); s != Open {
+		t.Fatalf("state after 4 failures = %v, want open", s)
+	}
+	called := false
+	if err := b.Do(func() error { called = true; return nil }); !errors.Is(err, ErrOpen) || called {
+		t.Errorf("Do while open = %v, called = %v; want ErrOpen without calling", err, called)
+	}
+	if stats := b.Stats(); stats.LastError != errDown.Error() {
+		t.Errorf("LastError = %q", stats.LastError)
+	}
+}
+
+func TestBreakerStaysClosedBelowThreshold(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	for i := 0; i < 10; i++ {
+		i := i
+		_ = b.Do(func() error {
+			if i%4 == 0 {
+				return errDown
+			}
+			return nil
+		})
+	}
+	if stats := b.Stats(); stats.State != Closed || stats.Failures != 3 || stats.Requests != 10 {
+		t.Errorf("stats = %+v, want closed with 3/10 failures", stats)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		_ = b.Do(func() error { return errDown })
+	}
+
+	// OpenTimeout 后进入半开，试探失败重新打开
+	now = now.Add(time.Second)
+	if s := b.State(); s != HalfOpen {
+		t.Fatalf("state after OpenTimeout = %v, want half-open", s)
+	}
+	_ = b.Do(func() error { return errDown })
+	if s := b.State(); s != Open {
+		t.Fatalf("state after failed probe = %v, want open", s)
+	}
+
+	// 半开最多放行 HalfOpenRequests 个试探请求，全部成功后关闭
+	now = now.Add(time.Second)
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatalf("first probe rejected: %v", err)
+	}
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatalf("second probe rejected: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("third probe = %v, want ErrOpen", err)
+	}
+	b.Done(first, nil)
+	b.Done(second, nil)
+	if stats := b.Stats(); stats.State != Closed || stats.Requests != 0 {
+		t.Errorf("stats after successful probes = %+v, want closed with reset window", stats)
+	}
+}
+
+func TestBreakerIgnoresStaleResults(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(&now)
+
+	// 关闭状态放行的慢请求，结束时熔断器已经打开又进入半开
+	slow, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		_ = b.Do(func() error { return errDown })
+	}
+	now = now.Add(time.Second)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+
+	// 慢请求的结果既不算试探成功，也不释放试探名额
+	b.Done(slow, nil)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("second probe rejected: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("third probe = %v, want ErrOpen", err)
+	}
+	b.Done(slow, errDown)
+	if s := b.State(); s != HalfOpen {
+		t.Errorf("state after stale failure = %v, want half-open", s)
+	}
+	b.Done(probe, errDown)
+	if s := b.State(); s != Open {
+		t.Errorf("state after failed probe = %v, want open", s)
+	}
+}
+
+func TestFailureIgnoresCallerDeadline(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	timeout := &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "success", ctx: context.Background(), err: nil, want: false},
+		{name: "connection refused", ctx: context.Background(), err: errDown, want: true},
+		{name: "read timeout", ctx: context.Background(), err: timeout, want: true},
+		{name: "deadline not from caller", ctx: context.Background(), err: context.DeadlineExceeded, want: true},
+		{name: "caller deadline", ctx: expired, err: context.DeadlineExceeded, want: false},
+		{name: "read timeout after caller deadline", ctx: expired, err: timeout, want: false},
+		{name: "caller canceled", ctx: context.Background(), err: context.Canceled, want: false},
+		{name: "redis nil", ctx: context.Background(), err: redis.Nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failure(tt.ctx, tt.err) != nil; got != tt.want {
+				t.Errorf("failure(%v) counted = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"strings"
+)
+
+// Hook 实现 redis.Hook：命令执行前询问熔断器，熔断打开时直接返回 ErrOpen，不再等待连接/读写超时
+type Hook struct {
+	breaker *Breaker
+}
+
+// NewHook 构造熔断Hook
+func NewHook(b *Breaker) *Hook {
+	return &Hook{breaker: b}
+}
+
+// DialHook 建立连接不做处理，连接失败会体现在命令的错误上
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook 单条命令
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		token, err := h.breaker.Allow()
+		if err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err = next(ctx, cmd)
+		h.breaker.Done(token, failure(ctx, err))
+		return err
+	}
+}
+
+// ProcessPipelineHook 管道/事务管道整批算一次调用
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		token, err := h.breaker.Allow()
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err = next(ctx, cmds)
+		h.breaker.Done(token, failure(ctx, err))
+		return err
+	}
+}
+
+// IsFailure 判断错误是否说明 Redis 不可用（网络错误、超时、连接池耗尽、服务端加载中等）
+// redis.Nil、WRONGTYPE 这类服务端正常返回的错误以及调用方主动取消都不算
+func IsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		msg := redisErr.Error()
+		for _, prefix := range []string{"LOADING ", "CLUSTERDOWN ", "MASTERDOWN ", "TRYAGAIN ", "ERR max number of clients reached"} {
+			if strings.HasPrefix(msg, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// failure 只把不可用类错误交给熔断器统计
+// 调用方的 ctx 已经取消或超时时，错误（DeadlineExceeded 或连接读写超时）是调用方的期限太短造成的，不算 Redis 不可用，
+// 否则一个设置了很短超时的调用方就能让所有调用方熔断
+func failure(ctx context.Context, err error) error {
+	if ctx.Err() != nil || !IsFailure(err) {
+		return nil
+	}
+	return err
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"github.com/redis/go-redis/v9"
+	"log"
+	"redis/breaker"
+	"redis/instrument"
 	"sync"
+	"time"
 )
 
 var once sync.Once
@@ -10,9 +14,82 @@ var once sync.Once
 // RedisClient 为全局单例
 var RedisClient *redis.Client
 
+// RedisBreaker Redis 熔断器，InitRedisClient 时创建，redisutil.Health 通过它报告健康状态
+var RedisBreaker *breaker.Breaker
+
+// SlowCommandThreshold 慢命令阈值，耗时超过该值的命令会输出日志，需在 InitRedisClient 之前修改
+var SlowCommandThreshold = 100 * time.Millisecond
+
+// BreakerSettings 熔断器配置，需在 InitRedisClient 之前修改
+var BreakerSettings = breaker.Settings{
+	WindowSize:       100,
+	MinRequests:      10,
+	FailureRate:      0.5,
+	OpenTimeout:      5 * time.Second,
+	HalfOpenRequests: 3,
+}
+
 // InitRedisClient 初始化全局 Redis 客户端，应用启动时只需调用一次
+// 同时注册监控Hook：命令耗时直方图和错误计数见 instrument.Handler，慢命令和失败命令输出日志
+// 以及熔断Hook：Redis 不可用时快速失败，避免每次调用都等到超时
+// opts 原样使用，超时和重试沿用 go-redis 的默认值；需要更短的超时见 FastFailTimeouts
 func InitRedisClient(opts *redis.Options) {
 	once.Do(func() {
+		settings := BreakerSettings
+		if settings.OnStateChange == nil {
+			settings.OnStateChange = func(from, to breaker.State) {
+				log.Printf("redis熔断器状态变化：%s -> %s", from, to)
+			}
+		}
+		RedisBreaker = breaker.New(settings)
 		RedisClient = redis.NewClient(opts)
+		addHooks(RedisClient)
 	})
 }
+
+// NewNoRetryClient 构造与 RedisClient 配置相同、但失败后不自动重试的客户端，用完需调用 Close
+// 用于 INCRBY 这类非幂等写入：go-redis 超时后会重发整批命令，而 Redis 可能已经执行过，重试会重复计数
+// 与 RedisClient 共用监控指标和熔断器，但使用独立的连接池
+func NewNoRetryClient() *redis.Client {
+	opts := *RedisClient.Options()
+	opts.MaxRetries = -1
+	client := redis.NewClient(&opts)
+	addHooks(client)
+	return client
+}
+
+// addHooks 注册监控Hook，InitRedisClient 创建了熔断器时再注册熔断Hook
+func addHooks(client *redis.Client) {
+	client.AddHook(instrument.NewHook(instrument.DefaultRegistry, SlowCommandThreshold))
+	if RedisBreaker != nil {
+		client.AddHook(breaker.NewHook(RedisBreaker))
+	}
+}
+
+/*
+FastFailTimeouts 为 opts 中未设置的超时和重试次数填上推荐的较短值，返回 opts 本身，需要时在 InitRedisClient 之前调用：
+
+	config.InitRedisClient(config.FastFailTimeouts(&redis.Options{Addr: "localhost:6379"}))
+
+go-redis 默认连接 5 秒、读写 3 秒、连接池等待 ReadTimeout+1 秒、失败重试 3 次，Redis 宕机时调用方可能被阻塞十几秒。
+推荐值：连接 1 秒、读写 500 毫秒、连接池等待 1 秒、重试 1 次。
+注意 500 毫秒的读超时不适合阻塞命令（BLPOP 等）和耗时较长的 Lua 脚本，这类场景请单独设置 ReadTimeout。
+*/
+func FastFailTimeouts(opts *redis.Options) *redis.Options {
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = time.Second
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 500 * time.Millisecond
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 500 * time.Millisecond
+	}
+	if opts.PoolTimeout == 0 {
+		opts.PoolTimeout = time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 1
+	}
+	return opts
+}
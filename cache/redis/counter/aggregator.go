@@ -0,0 +1,136 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"redis/config"
+	"sync"
+	"time"
+)
+
+// 默认刷新间隔
+const defaultFlushInterval = time.Second
+
+/*
+Aggregator 本地聚合计数，定时批量写入 Redis，用于浏览量、点击数这类允许短暂延迟的高频计数以降低 Redis 写压力。
+同一个键在一个刷新周期内的多次 Add 合并为一次 INCRBY，所有键放在一个事务（MULTI/EXEC）中，用不自动重试的客户端发送。
+刷新失败时：
+  - Redis 返回了 EXEC 的结果时，已生效的不会重复；执行失败的 INCRBY（例如 WRONGTYPE）是键本身的问题，重试也不会成功，记录日志后丢弃
+  - 网络错误、超时时无法知道 EXEC 是否已经执行，整批合并回本地，下个周期重试；如果 Redis 实际已经执行，下个周期会重复计数
+
+进程异常退出时未刷新的增量会丢失。
+*/
+type Aggregator struct {
+	interval time.Duration
+	client   *redis.Client //不自动重试的客户端，见 config.NewNoRetryClient
+
+	mu      sync.Mutex
+	pending map[string]int64
+
+	flushMu sync.Mutex //保证同一时刻只有一次刷新
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewAggregator 构造聚合器并启动后台刷新协程，interval 为 0 时使用默认 1 秒；用完必须调用 Close
+func NewAggregator(interval time.Duration) *Aggregator {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Aggregator{
+		interval: interval,
+		client:   config.NewNoRetryClient(),
+		pending:  make(map[string]int64),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go a.loop(ctx)
+	return a
+}
+
+// Add 本地累加，不访问 Redis
+func (a *Aggregator) Add(key string, delta int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending[key] += delta
+}
+
+// Pending 尚未写入 Redis 的增量
+func (a *Aggregator) Pending(key string) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pending[key]
+}
+
+// Flush 立即把本地增量在一个事务中写入 Redis，失败时的处理规则见 Aggregator
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = make(map[string]int64)
+	a.mu.Unlock()
+
+	for key, delta := range batch {
+		if delta == 0 {
+			delete(batch, key)
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	cmds := make(map[string]*redis.IntCmd, len(batch))
+	_, err := a.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, delta := range batch {
+			cmds[key] = pipe.IncrBy(ctx, key, delta)
+		}
+		return nil
+	})
+	if err != nil {
+		// 错误是 Redis 的回复时 EXEC 的结果是确定的，丢弃 Redis 拒绝的命令；否则无法确定是否执行过，放回重试
+		var redisErr redis.Error
+		known := errors.As(err, &redisErr)
+		a.mu.Lock()
+		for key, cmd := range cmds {
+			switch {
+			case known && cmd.Err() == nil:
+			case known && errors.As(cmd.Err(), &redisErr):
+				log.Printf("计数写入失败，丢弃增量：key=%s, delta=%d, err=%v", key, batch[key], cmd.Err())
+			default:
+				a.pending[key] += batch[key]
+			}
+		}
+		a.mu.Unlock()
+	}
+	return err
+}
+
+// Close 停止后台刷新并把剩余增量写入 Redis，ctx 控制最后一次刷新
+func (a *Aggregator) Close(ctx context.Context) error {
+	a.cancel()
+	<-a.done
+	err := a.Flush(ctx)
+	_ = a.client.Close()
+	return err
+}
+
+func (a *Aggregator) loop(ctx context.Context) {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 定时刷新不属于任何请求，ctx 已用于停止循环，刷新用独立的 context
+			if err := a.Flush(context.Background()); err != nil {
+				log.Printf("计数刷新失败：%v", err)
+			}
+		}
+	}
+}
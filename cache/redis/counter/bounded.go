@@ -0,0 +1,83 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"redis/config"
+	"strconv"
+)
+
+var ErrOutOfRange = errors.New("counter out of range") //计数超出上下限，未修改
+
+// NoFloor、NoCeiling 表示不限制下限/上限
+const (
+	NoFloor   int64 = math.MinInt64
+	NoCeiling int64 = math.MaxInt64
+)
+
+// boundedIncrLua Lua 脚本：结果在 [ARGV[2], ARGV[3]] 内才执行 INCRBY（空字符串表示不限制），返回 {是否成功, 当前值}
+// INCRBY 会保留键原有的过期时间
+const boundedIncrLua = `
+		local current = tonumber(redis.call("get", KEYS[1]) or "0")
+		local next = current + tonumber(ARGV[1])
+		if (ARGV[2] ~= "" and next < tonumber(ARGV[2])) or (ARGV[3] ~= "" and next > tonumber(ARGV[3])) then
+			return {0, current}
+		end
+		return {1, redis.call("incrby", KEYS[1], ARGV[1])}
+	`
+
+var boundedIncrScript = redis.NewScript(boundedIncrLua)
+
+// Bounded 带上下限的计数器，例如库存不能为负：NewBounded("stock:1001", 0, NoCeiling)
+type Bounded struct {
+	key     string
+	floor   int64
+	ceiling int64
+}
+
+// NewBounded 构造带上下限的计数器，floor 传 NoFloor、ceiling 传 NoCeiling 表示不限制
+func NewBounded(key string, floor, ceiling int64) *Bounded {
+	return &Bounded{key: key, floor: floor, ceiling: ceiling}
+}
+
+// IncrBy 原子增加 delta，结果超出上下限时不修改并返回 ErrOutOfRange 和当前值
+func (b *Bounded) IncrBy(ctx context.Context, delta int64) (int64, error) {
+	res, err := boundedIncrScript.Run(ctx, config.RedisClient, []string{b.key},
+		delta, bound(b.floor, NoFloor), bound(b.ceiling, NoCeiling)).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	if res[0] != 1 {
+		return res[1], ErrOutOfRange
+	}
+	return res[1], nil
+}
+
+// DecrBy 原子减少 delta，结果超出上下限时不修改并返回 ErrOutOfRange 和当前值
+func (b *Bounded) DecrBy(ctx context.Context, delta int64) (int64, error) {
+	return b.IncrBy(ctx, -delta)
+}
+
+// Get 当前值，键不存在时为 0
+func (b *Bounded) Get(ctx context.Context) (int64, error) {
+	n, err := config.RedisClient.Get(ctx, b.key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Set 直接设置当前值（例如盘点后重置库存），不检查上下限
+func (b *Bounded) Set(ctx context.Context, value int64) error {
+	return config.RedisClient.Set(ctx, b.key, value, 0).Err()
+}
+
+// bound 不限制时传空字符串给脚本
+func bound(v, unlimited int64) string {
+	if v == unlimited {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
@@ -0,0 +1,348 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"redis/config"
+	"redis/instrument"
+	"redis/redistest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setup 启动假 Redis 并注册计数器用到的 Lua 脚本的 Go 实现
+func setup(t *testing.T) *redistest.Server {
+	t.Helper()
+	srv := redistest.Setup(t)
+	srv.RegisterScript(boundedIncrLua, fakeBoundedIncr)
+	srv.RegisterScript(reserveLua, fakeReserve)
+	srv.RegisterScript(rollbackLua, fakeRollback)
+	return srv
+}
+
+func getInt(call redistest.Call, args ...string) (int64, bool, error) {
+	v, err := call(args...)
+	if err != nil || v == nil {
+		return 0, false, err
+	}
+	n, err := strconv.ParseInt(v.(string), 10, 64)
+	return n, true, err
+}
+
+func fakeBoundedIncr(call redistest.Call, keys, argv []string) (interface{}, error) {
+	current, _, err := getInt(call, "get", keys[0])
+	if err != nil {
+		return nil, err
+	}
+	delta, _ := strconv.ParseInt(argv[0], 10, 64)
+	next := current + delta
+	if floor, err := strconv.ParseInt(argv[1], 10, 64); err == nil && next < floor {
+		return []interface{}{int64(0), current}, nil
+	}
+	if ceiling, err := strconv.ParseInt(argv[2], 10, 64); err == nil && next > ceiling {
+		return []interface{}{int64(0), current}, nil
+	}
+	n, err := call("incrby", keys[0], argv[0])
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{int64(1), n}, nil
+}
+
+func fakeReserve(call redistest.Call, keys, argv []string) (interface{}, error) {
+	if exists, _ := call("hexists", keys[1], argv[0]); exists == int64(1) {
+		return int64(1), nil
+	}
+	available, _, err := getInt(call, "get", keys[0])
+	if err != nil {
+		return nil, err
+	}
+	quantity, _ := strconv.ParseInt(argv[1], 10, 64)
+	if available < quantity {
+		return int64(0), nil
+	}
+	if _, err := call("decrby", keys[0], argv[1]); err != nil {
+		return nil, err
+	}
+	if _, err := call("hset", keys[1], argv[0], argv[1]); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+func fakeRollback(call redistest.Call, keys, argv []string) (interface{}, error) {
+	quantity, ok, err := getInt(call, "hget", keys[1], argv[0])
+	if err != nil || !ok {
+		return int64(0), err
+	}
+	if _, err := call("incrby", keys[0], strconv.FormatInt(quantity, 10)); err != nil {
+		return nil, err
+	}
+	if _, err := call("hdel", keys[1], argv[0]); err != nil {
+		return nil, err
+	}
+	return int64(1), nil
+}
+
+func TestBounded(t *testing.T) {
+	ctx := context.Background()
+	setup(t)
+	stock := NewBounded("stock:1001", 0, 10)
+	if err := stock.Set(ctx, 3); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if n, err := stock.DecrBy(ctx, 2); err != nil || n != 1 {
+		t.Errorf("DecrBy(2) = %d, %v; want 1", n, err)
+	}
+	if n, err := stock.DecrBy(ctx, 2); !errors.Is(err, ErrOutOfRange) || n != 1 {
+		t.Errorf("DecrBy(2) below floor = %d, %v; want 1, ErrOutOfRange", n, err)
+	}
+	if n, err := stock.IncrBy(ctx, 10); !errors.Is(err, ErrOutOfRange) || n != 1 {
+		t.Errorf("IncrBy(10) above ceiling = %d, %v; want 1, ErrOutOfRange", n, err)
+	}
+	if n, err := stock.IncrBy(ctx, 9); err != nil || n != 10 {
+		t.Errorf("IncrBy(9) = %d, %v; want 10", n, err)
+	}
+
+	unbounded := NewBounded("counter:any", NoFloor, NoCeiling)
+	if n, err := unbounded.DecrBy(ctx, 5); err != nil || n != -5 {
+		t.Errorf("unbounded DecrBy(5) = %d, %v; want -5", n, err)
+	}
+}
+
+// TestBoundedEdges 上下限的边界：等于上下限允许，不存在的键按 0 计算，INCRBY 保留过期时间
+func TestBoundedEdges(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name           string
+		floor, ceiling int64
+		start          *int64 //nil 表示键不存在
+		delta          int64
+		want           int64
+		wantErr        error
+	}{
+		{name: "missing key below floor", floor: 0, ceiling: NoCeiling, delta: -1, want: 0, wantErr: ErrOutOfRange},
+		{name: "missing key", floor: 0, ceiling: NoCeiling, delta: 2, want: 2},
+		{name: "down to floor", floor: 0, ceiling: NoCeiling, start: ptr(3), delta: -3, want: 0},
+		{name: "up to ceiling", floor: NoFloor, ceiling: 5, start: ptr(3), delta: 2, want: 5},
+		{name: "above ceiling", floor: NoFloor, ceiling: 5, start: ptr(3), delta: 3, want: 3, wantErr: ErrOutOfRange},
+		{name: "negative floor", floor: -10, ceiling: 10, start: ptr(-5), delta: -6, want: -5, wantErr: ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup(t)
+			c := NewBounded("bounded", tt.floor, tt.ceiling)
+			if tt.start != nil {
+				_ = c.Set(ctx, *tt.start)
+			}
+			n, err := c.IncrBy(ctx, tt.delta)
+			if n != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("IncrBy(%d) = %d, %v; want %d, %v", tt.delta, n, err, tt.want, tt.wantErr)
+			}
+			if got, _ := c.Get(ctx); got != tt.want {
+				t.Errorf("Get = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	srv := setup(t)
+	c := NewBounded("bounded:ttl", 0, NoCeiling)
+	_ = config.RedisClient.Set(ctx, "bounded:ttl", 1, time.Minute).Err()
+	if _, err := c.IncrBy(ctx, 1); err != nil {
+		t.Fatalf("IncrBy: %v", err)
+	}
+	if ttl := srv.TTL("bounded:ttl"); ttl != time.Minute {
+		t.Errorf("TTL after IncrBy = %v, want 1m", ttl)
+	}
+}
+
+func ptr(n int64) *int64 {
+	return &n
+}
+
+func TestWindow(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+	w := NewWindow("orders:per-minute", time.Minute, 0)
+	w.now = srv.Clock().Now
+
+	for i := 0; i < 3; i++ {
+		_, _ = w.Incr(ctx, 1)
+	}
+	if n, _ := w.Current(ctx); n != 3 {
+		t.Errorf("Current = %d, want 3", n)
+	}
+
+	srv.FastForward(time.Minute)
+	if n, err := w.Incr(ctx, 2); err != nil || n != 2 {
+		t.Errorf("Incr in next window = %d, %v; want 2", n, err)
+	}
+	if n, _ := w.Sum(ctx, 2); n != 5 {
+		t.Errorf("Sum(2) = %d, want 5", n)
+	}
+
+	// 窗口结束 retention（一个窗口）后自动过期
+	srv.FastForward(time.Minute)
+	if n, _ := w.Sum(ctx, 3); n != 2 {
+		t.Errorf("Sum(3) after first window expired = %d, want 2", n)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+	a := NewAggregator(time.Hour)
+
+	a.Add("views:1", 1)
+	a.Add("views:1", 2)
+	a.Add("views:2", 5)
+	if srv.Exists("views:1") {
+		t.Fatal("Add must not write to redis before flush")
+	}
+	if err := a.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if v, _ := srv.Get("views:1"); v != "3" {
+		t.Errorf("views:1 = %q, want 3", v)
+	}
+
+	a.Add("views:2", 1)
+	if err := a.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if v, _ := srv.Get("views:2"); v != "6" {
+		t.Errorf("views:2 after Close = %q, want 6", v)
+	}
+}
+
+func TestAggregatorKeepsDeltasWhenRedisDown(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+	a := NewAggregator(time.Hour)
+	defer a.Close(ctx)
+
+	a.Add("views:1", 4)
+	srv.Close()
+	if err := a.Flush(ctx); err == nil {
+		t.Fatal("Flush should fail when redis is down")
+	}
+	if n := a.Pending("views:1"); n != 4 {
+		t.Errorf("Pending after failed flush = %d, want 4", n)
+	}
+}
+
+func TestAggregatorDropsRejectedIncrements(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+	a := NewAggregator(time.Hour)
+	defer a.Close(ctx)
+
+	// views:bad 是哈希，EXEC 中它的 INCRBY 返回 WRONGTYPE，其余命令照常生效
+	_ = config.RedisClient.HSet(ctx, "views:bad", "f", "v").Err()
+	a.Add("views:ok", 3)
+	a.Add("views:bad", 2)
+	if err := a.Flush(ctx); err == nil {
+		t.Fatal("Flush should report the WRONGTYPE error")
+	}
+	if v, _ := srv.Get("views:ok"); v != "3" {
+		t.Errorf("views:ok = %q, want 3", v)
+	}
+	if n := a.Pending("views:ok"); n != 0 {
+		t.Errorf("Pending(views:ok) = %d, want 0: applied increments must not be sent again", n)
+	}
+	if n := a.Pending("views:bad"); n != 0 {
+		t.Errorf("Pending(views:bad) = %d, want 0: a rejected increment must not be retried forever", n)
+	}
+	a.Add("views:ok", 1)
+	if err := a.Flush(ctx); err != nil {
+		t.Errorf("Flush after the rejected increment was dropped = %v", err)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	ctx := context.Background()
+	setup(t)
+	stock := NewInventory("stock:2001")
+	_ = stock.SetAvailable(ctx, 5)
+
+	if err := stock.Reserve(ctx, "order-1", 3); err != nil {
+		t.Fatalf("Reserve order-1: %v", err)
+	}
+	// 重复预留是幂等的
+	if err := stock.Reserve(ctx, "order-1", 3); err != nil {
+		t.Fatalf("Reserve order-1 again: %v", err)
+	}
+	if err := stock.Reserve(ctx, "order-2", 3); !errors.Is(err, ErrInsufficient) {
+		t.Errorf("Reserve order-2 = %v, want ErrInsufficient", err)
+	}
+	if err := stock.Reserve(ctx, "order-2", 2); err != nil {
+		t.Fatalf("Reserve order-2: %v", err)
+	}
+	if n, _ := stock.Available(ctx); n != 0 {
+		t.Errorf("Available = %d, want 0", n)
+	}
+
+	if err := stock.Commit(ctx, "order-1"); err != nil {
+		t.Errorf("Commit order-1: %v", err)
+	}
+	if err := stock.Rollback(ctx, "order-1"); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Rollback committed order = %v, want ErrReservationNotFound", err)
+	}
+	if err := stock.Rollback(ctx, "order-2"); err != nil {
+		t.Errorf("Rollback order-2: %v", err)
+	}
+	if n, _ := stock.Available(ctx); n != 2 {
+		t.Errorf("Available after rollback = %d, want 2", n)
+	}
+	if err := stock.Rollback(ctx, "order-unknown"); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Rollback unknown order = %v, want ErrReservationNotFound", err)
+	}
+	if n, _ := stock.Reserved(ctx, "order-2"); n != 0 {
+		t.Errorf("Reserved after rollback = %d, want 0", n)
+	}
+	if err := stock.Commit(ctx, "order-2"); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Commit rolled back order = %v, want ErrReservationNotFound", err)
+	}
+}
+
+func TestInventoryMissingStock(t *testing.T) {
+	ctx := context.Background()
+	setup(t)
+	stock := NewInventory("stock:missing")
+	// 库存键不存在时可用库存按 0 计算
+	if err := stock.Reserve(ctx, "order-1", 1); !errors.Is(err, ErrInsufficient) {
+		t.Errorf("Reserve = %v, want ErrInsufficient", err)
+	}
+	if n, _ := stock.Reserved(ctx, "order-1"); n != 0 {
+		t.Errorf("Reserved = %d, want 0", n)
+	}
+}
+
+func TestCountersPassContext(t *testing.T) {
+	srv := setup(t)
+	hook := &redistest.TraceHook{}
+	config.RedisClient.AddHook(hook)
+	ctx := instrument.WithTraceID(context.Background(), "req-1")
+
+	_, _ = NewBounded("c:bounded", 0, NoCeiling).IncrBy(ctx, 1)
+	w := NewWindow("c:window", time.Minute, 0)
+	w.now = srv.Clock().Now
+	_, _ = w.Incr(ctx, 1)
+	_, _ = w.Sum(ctx, 2)
+	stock := NewInventory("c:stock")
+	_ = stock.SetAvailable(ctx, 1)
+	_ = stock.Reserve(ctx, "order-1", 1)
+	_ = stock.Rollback(ctx, "order-1")
+	_ = stock.Commit(ctx, "order-1")
+	for _, cmd := range hook.Commands() {
+		if !strings.HasSuffix(cmd, ":req-1") {
+			t.Errorf("command %s was sent without the caller's trace id", cmd)
+		}
+	}
+	if got := len(hook.Commands()); got < 8 {
+		t.Errorf("hook saw %d commands, want at least 8", got)
+	}
+}
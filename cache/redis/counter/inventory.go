@@ -0,0 +1,124 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"redis/config"
+)
+
+var (
+	ErrInsufficient        = errors.New("insufficient quantity") //可用数量不足，未预留
+	ErrReservationNotFound = errors.New("reservation not found") //预留不存在（未预留、已提交或已回滚）
+)
+
+// reserveLua Lua 脚本：同一订单重复预留直接返回成功（幂等）；可用数量足够时扣减并记录预留
+// 返回 1 成功，0 数量不足
+const reserveLua = `
+		if redis.call("hexists", KEYS[2], ARGV[1]) == 1 then
+			return 1
+		end
+		local available = tonumber(redis.call("get", KEYS[1]) or "0")
+		if available < tonumber(ARGV[2]) then
+			return 0
+		end
+		redis.call("decrby", KEYS[1], ARGV[2])
+		redis.call("hset", KEYS[2], ARGV[1], ARGV[2])
+		return 1
+	`
+
+// rollbackLua Lua 脚本：把订单预留的数量加回可用数量并删除预留记录，返回 1 成功，0 预留不存在
+const rollbackLua = `
+		local quantity = redis.call("hget", KEYS[2], ARGV[1])
+		if not quantity then
+			return 0
+		end
+		redis.call("incrby", KEYS[1], quantity)
+		redis.call("hdel", KEYS[2], ARGV[1])
+		return 1
+	`
+
+var (
+	reserveScript  = redis.NewScript(reserveLua)
+	rollbackScript = redis.NewScript(rollbackLua)
+)
+
+/*
+Inventory 数量的预留/提交/回滚，用于下单扣库存：
+ 1. 下单时 Reserve：可用数量原子扣减，预留记录在哈希 key:reserved 中（字段为订单号）
+ 2. 支付成功 Commit：删除预留记录，扣减生效
+ 3. 取消或超时未支付 Rollback：预留数量加回可用数量
+
+可用数量保存在字符串键 key 中，所有操作都是原子的，同一订单重复调用是幂等的。
+*/
+type Inventory struct {
+	key         string
+	reservedKey string
+}
+
+// NewInventory 构造库存对象，key 为可用数量的键，例如 "stock:1001"
+func NewInventory(key string) *Inventory {
+	return &Inventory{key: key, reservedKey: key + ":reserved"}
+}
+
+// Reserve 为订单预留 quantity，可用数量不足时返回 ErrInsufficient
+func (i *Inventory) Reserve(ctx context.Context, orderID string, quantity int64) error {
+	if quantity <= 0 {
+		return errors.New("reserve quantity must be positive")
+	}
+	ok, err := reserveScript.Run(ctx, config.RedisClient, []string{i.key, i.reservedKey}, orderID, quantity).Int64()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrInsufficient
+	}
+	return nil
+}
+
+// Commit 提交订单的预留，预留不存在时返回 ErrReservationNotFound
+func (i *Inventory) Commit(ctx context.Context, orderID string) error {
+	n, err := config.RedisClient.HDel(ctx, i.reservedKey, orderID).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// Rollback 回滚订单的预留，数量加回可用数量，预留不存在时返回 ErrReservationNotFound
+func (i *Inventory) Rollback(ctx context.Context, orderID string) error {
+	ok, err := rollbackScript.Run(ctx, config.RedisClient, []string{i.key, i.reservedKey}, orderID).Int64()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// Available 当前可用数量
+func (i *Inventory) Available(ctx context.Context) (int64, error) {
+	n, err := config.RedisClient.Get(ctx, i.key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Reserved 订单当前预留的数量，没有预留时为 0
+func (i *Inventory) Reserved(ctx context.Context, orderID string) (int64, error) {
+	n, err := config.RedisClient.HGet(ctx, i.reservedKey, orderID).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// SetAvailable 设置可用数量（入库、盘点）
+func (i *Inventory) SetAvailable(ctx context.Context, quantity int64) error {
+	return config.RedisClient.Set(ctx, i.key, quantity, 0).Err()
+}
@@ -0,0 +1,90 @@
+package counter
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"redis/config"
+	"strconv"
+	"time"
+)
+
+// Window 按固定时间窗口计数（例如每分钟下单次数），每个窗口一个键，窗口结束后保留 retention 再自动过期
+// 键名格式：prefix:窗口开始的Unix秒数
+type Window struct {
+	prefix    string
+	size      time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewWindow 构造窗口计数器，size 为窗口长度，retention 为窗口结束后保留多久（用于 Sum 统计最近多个窗口），传 0 时保留一个窗口长度
+func NewWindow(prefix string, size, retention time.Duration) *Window {
+	if retention <= 0 {
+		retention = size
+	}
+	return &Window{prefix: prefix, size: size, retention: retention, now: time.Now}
+}
+
+// Incr 当前窗口计数增加 n，返回增加后的值
+// INCRBY 和 PEXPIREAT 在同一个事务中执行，过期时间固定为窗口结束时间加 retention，重复设置是幂等的
+func (w *Window) Incr(ctx context.Context, n int64) (int64, error) {
+	start := w.start(w.now())
+	key := w.key(start)
+	var incr *redis.IntCmd
+	_, err := config.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, key, n)
+		pipe.PExpireAt(ctx, key, start.Add(w.size+w.retention))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Current 当前窗口的计数
+func (w *Window) Current(ctx context.Context) (int64, error) {
+	n, err := config.RedisClient.Get(ctx, w.key(w.start(w.now()))).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Sum 最近 windows 个窗口（含当前窗口）的计数之和，已过期的窗口按 0 计算
+func (w *Window) Sum(ctx context.Context, windows int) (int64, error) {
+	if windows <= 0 {
+		return 0, nil
+	}
+	start := w.start(w.now())
+	keys := make([]string, windows)
+	for i := range keys {
+		keys[i] = w.key(start.Add(-time.Duration(i) * w.size))
+	}
+	values, err := config.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		sum += n
+	}
+	return sum, nil
+}
+
+func (w *Window) start(t time.Time) time.Time {
+	return t.Truncate(w.size)
+}
+
+func (w *Window) key(start time.Time) string {
+	return w.prefix + ":" + strconv.FormatInt(start.Unix(), 10)
+}
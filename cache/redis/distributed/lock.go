@@ -17,6 +17,29 @@ const defaultExpiration = 30 * time.Second
 
 var ErrLockNotAcquired = errors.New("failed to acquire lock") //获取锁失败（未抢到锁）
 
+// renewLua Lua 脚本：只有当前持有者才能续期
+const renewLua = `
+		if redis.call("get", KEYS[1]) == ARGV[1] then 
+			return redis.call("expire", KEYS[1], ARGV[2]) 
+		else 
+			return 0 
+		end
+	`
+
+// unlockLua Lua 脚本：只有当前持有者才能删除锁
+const unlockLua = `
+		if redis.call("get", KEYS[1]) == ARGV[1] then 
+			return redis.call("del", KEYS[1])
+		else 
+			return 0 
+		end
+	`
+
+var (
+	renewScript  = redis.NewScript(renewLua)
+	unlockScript = redis.NewScript(unlockLua)
+)
+
 // DistributedLock 封装了分布式锁实现
 type DistributedLock struct {
 	client     *redis.Client
@@ -24,7 +47,7 @@ type DistributedLock struct {
 	value      string        // 唯一标识
 	expiration time.Duration // 锁过期时间
 	cancelFunc context.CancelFunc
-	ctx        context.Context
+	newTicker  func(d time.Duration) (<-chan time.Time, func()) // 看门狗的定时器，测试中替换为可控时钟驱动的实现
 }
 
 // NewDistributedLock 构造锁对象
@@ -41,13 +64,20 @@ func NewDistributedLock(key string, expiration time.Duration) (*DistributedLock,
 		key:        key,
 		value:      uuid.NewString(),
 		expiration: expiration,
-		ctx:        context.Background(),
+		newTicker:  realTicker,
 	}, nil
 }
 
+// realTicker 看门狗默认使用 time.Ticker，返回触发通道和停止函数
+func realTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
 // Lock 尝试加锁成功则启动看门狗自动续期
-func (l *DistributedLock) Lock() (bool, error) {
-	ok, err := l.client.SetNX(l.ctx, l.key, l.value, l.expiration).Result()
+// 看门狗的续期命令使用 ctx 的值（例如链路ID），但不随 ctx 取消，直到 Unlock 才停止
+func (l *DistributedLock) Lock(ctx context.Context) (bool, error) {
+	ok, err := l.client.SetNX(ctx, l.key, l.value, l.expiration).Result()
 	if err != nil {
 		return false, err
 	}
@@ -56,33 +86,25 @@ func (l *DistributedLock) Lock() (bool, error) {
 	}
 
 	// 锁获取成功，启动看门狗协程续约
-	renewCtx, cancel := context.WithCancel(l.ctx)
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	l.cancelFunc = cancel
-	go l.autoRenew(renewCtx, l.expiration/3)
+	// 定时器在返回前创建，保证续期周期从加锁成功时开始计算
+	ticks, stop := l.newTicker(l.expiration / 3)
+	go l.autoRenew(renewCtx, ticks, stop)
 	return true, nil
 }
 
 // autoRenew 看门狗定时续约，确保锁在业务逻辑执行期间不失效
-func (l *DistributedLock) autoRenew(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	// Lua 脚本：只有当前持有者才能续期
-	renewScript := redis.NewScript(`
-		if redis.call("get", KEYS[1]) == ARGV[1] then 
-			return redis.call("expire", KEYS[1], ARGV[2]) 
-		else 
-			return 0 
-		end
-	`)
+func (l *DistributedLock) autoRenew(ctx context.Context, ticks <-chan time.Time, stop func()) {
+	defer stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticks:
 			seconds := int64(l.expiration.Seconds())
-			res, err := renewScript.Run(l.ctx, l.client, []string{l.key}, l.value, seconds).Result()
+			res, err := renewScript.Run(ctx, l.client, []string{l.key}, l.value, seconds).Result()
 			if err != nil || res.(int64) != 1 {
 				log.Printf("续约失败：err=%v, result=%v", err, res)
 				return
@@ -92,19 +114,12 @@ func (l *DistributedLock) autoRenew(ctx context.Context, interval time.Duration)
 }
 
 // Unlock 释放锁，只有锁持有者才能删除锁
-func (l *DistributedLock) Unlock() error {
+func (l *DistributedLock) Unlock(ctx context.Context) error {
 	if l.cancelFunc != nil {
 		l.cancelFunc()
 	}
 
-	unlockScript := redis.NewScript(`
-		if redis.call("get", KEYS[1]) == ARGV[1] then 
-			return redis.call("del", KEYS[1])
-		else 
-			return 0 
-		end
-	`)
-	res, err := unlockScript.Run(l.ctx, l.client, []string{l.key}, l.value).Result()
+	res, err := unlockScript.Run(ctx, l.client, []string{l.key}, l.value).Result()
 	if err != nil {
 		return err
 	}
@@ -115,18 +130,18 @@ func (l *DistributedLock) Unlock() error {
 }
 
 // DoWithLockDefault 默认超时时间为30秒的分布式锁【分布式锁】
-func DoWithLockDefault(lockKey string, businessLogic func() error) error {
-	return DoWithLock(lockKey, defaultExpiration, businessLogic)
+func DoWithLockDefault(ctx context.Context, lockKey string, businessLogic func() error) error {
+	return DoWithLock(ctx, lockKey, defaultExpiration, businessLogic)
 }
 
 // DoWithLock 包装业务逻辑执行，内部负责加锁、看门狗续约及释放锁【分布式锁】
-// 参数 expiration 为可选，传 0 则使用默认超时 30 秒
-func DoWithLock(lockKey string, expiration time.Duration, businessLogic func() error) error {
+// 参数 expiration 为可选，传 0 则使用默认超时 30 秒；ctx 用于加锁、续约和释放锁的命令
+func DoWithLock(ctx context.Context, lockKey string, expiration time.Duration, businessLogic func() error) error {
 	lock, err := NewDistributedLock(lockKey, expiration)
 	if err != nil {
 		return fmt.Errorf("create lock failed: %w", err)
 	}
-	locked, err := lock.Lock()
+	locked, err := lock.Lock(ctx)
 	if err != nil {
 		return fmt.Errorf("lock error for key %s: %w", lockKey, err)
 	}
@@ -141,8 +156,9 @@ func DoWithLock(lockKey string, expiration time.Duration, businessLogic func() e
 				2.幂等性设计确保重复调用无害。
 				3.无需引入额外的竞态条件风险。
 			这种实现方式符合 Redis 分布式锁的最佳实践，兼顾了安全性和简洁性。
+			释放锁不随 ctx 取消，否则请求超时后锁要等到过期才能被其他人获取。
 		*/
-		if err := lock.Unlock(); err != nil {
+		if err := lock.Unlock(context.WithoutCancel(ctx)); err != nil {
 			log.Printf("unlock error: %v", err)
 		}
 	}()
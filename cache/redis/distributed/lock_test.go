@@ -0,0 +1,207 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"redis/config"
+	"redis/instrument"
+	"redis/redistest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setup 启动假 Redis 并注册锁用到的 Lua 脚本
+func setup(t *testing.T) *redistest.Server {
+	t.Helper()
+	srv := redistest.Setup(t)
+	srv.RegisterScript(renewLua, compareAnd("expire"))
+	srv.RegisterScript(unlockLua, compareAnd("del"))
+	return srv
+}
+
+// compareAnd 续期/解锁脚本的 Go 实现：KEYS[1] 的值等于 ARGV[1] 时执行 op KEYS[1] ARGV[2:]...，否则返回 0
+func compareAnd(op string) redistest.ScriptFunc {
+	return func(call redistest.Call, keys, argv []string) (interface{}, error) {
+		v, err := call("get", keys[0])
+		if err != nil {
+			return nil, err
+		}
+		if v != argv[0] {
+			return int64(0), nil
+		}
+		return call(append([]string{op, keys[0]}, argv[1:]...)...)
+	}
+}
+
+func TestNewDistributedLockWithoutClient(t *testing.T) {
+	old := config.RedisClient
+	config.RedisClient = nil
+	defer func() { config.RedisClient = old }()
+
+	if _, err := NewDistributedLock("lock:nil", 0); err == nil {
+		t.Fatal("expected error when redis client is nil")
+	}
+}
+
+func TestLockAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+
+	a, _ := NewDistributedLock("lock:order", 0)
+	b, _ := NewDistributedLock("lock:order", 0)
+	if ok, err := a.Lock(ctx); err != nil || !ok {
+		t.Fatalf("a.Lock(ctx) = %v, %v; want true, nil", ok, err)
+	}
+	if ttl := srv.TTL("lock:order"); ttl != defaultExpiration {
+		t.Errorf("TTL = %v, want %v", ttl, defaultExpiration)
+	}
+	if ok, err := b.Lock(ctx); err != nil || ok {
+		t.Fatalf("b.Lock(ctx) = %v, %v; want false, nil", ok, err)
+	}
+	if err := b.Unlock(ctx); err == nil {
+		t.Error("b.Unlock(ctx) should fail for non-owner")
+	}
+	if !srv.Exists("lock:order") {
+		t.Fatal("non-owner unlock must not delete the lock")
+	}
+
+	if err := a.Unlock(ctx); err != nil {
+		t.Fatalf("a.Unlock(ctx) = %v", err)
+	}
+	if srv.Exists("lock:order") {
+		t.Error("lock key still exists after Unlock")
+	}
+	if err := a.Unlock(ctx); err == nil {
+		t.Error("second Unlock should report not lock owner")
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+
+	a, _ := NewDistributedLock("lock:expire", 3*time.Second)
+	if ok, err := a.Lock(ctx); err != nil || !ok {
+		t.Fatalf("a.Lock(ctx) = %v, %v; want true, nil", ok, err)
+	}
+	// 看门狗 1 秒后才会续期，时钟直接前进 4 秒让锁过期
+	srv.FastForward(4 * time.Second)
+
+	b, _ := NewDistributedLock("lock:expire", 3*time.Second)
+	if ok, err := b.Lock(ctx); err != nil || !ok {
+		t.Fatalf("b.Lock(ctx) after expiry = %v, %v; want true, nil", ok, err)
+	}
+	if err := a.Unlock(ctx); err == nil {
+		t.Error("a.Unlock(ctx) should fail after its lock expired and was taken by b")
+	}
+	if err := b.Unlock(ctx); err != nil {
+		t.Errorf("b.Unlock(ctx) = %v", err)
+	}
+}
+
+// clockTicker 让看门狗由假 Redis 的时钟驱动，FastForward 即可触发续期，不需要真的等待
+func clockTicker(srv *redistest.Server) func(time.Duration) (<-chan time.Time, func()) {
+	return func(d time.Duration) (<-chan time.Time, func()) {
+		tk := srv.Clock().NewTicker(d)
+		return tk.C, tk.Stop
+	}
+}
+
+func TestWatchdogRenews(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+
+	l, _ := NewDistributedLock("lock:renew", 3*time.Second)
+	l.newTicker = clockTicker(srv)
+	if ok, err := l.Lock(ctx); err != nil || !ok {
+		t.Fatalf("Lock() = %v, %v; want true, nil", ok, err)
+	}
+
+	// 看门狗每 expiration/3 = 1 秒续期一次。FastForward 返回时第 9 秒的续期已经完成，
+	// 锁至少保持到第 12 秒；没有续期的话锁在第 3 秒就过期了
+	srv.FastForward(10 * time.Second)
+	if !srv.Exists("lock:renew") {
+		t.Fatal("watchdog did not renew the lock")
+	}
+
+	if err := l.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	// 解锁后看门狗停止，时钟前进不会再等待它，也不会重新创建锁
+	srv.FastForward(10 * time.Second)
+	if srv.Exists("lock:renew") {
+		t.Error("lock exists after Unlock")
+	}
+}
+
+func TestWatchdogStopsWhenLockLost(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+
+	l, _ := NewDistributedLock("lock:lost", 3*time.Second)
+	l.newTicker = clockTicker(srv)
+	if ok, err := l.Lock(ctx); err != nil || !ok {
+		t.Fatalf("Lock() = %v, %v; want true, nil", ok, err)
+	}
+	defer l.cancelFunc()
+
+	// 锁被其他人持有后续期脚本返回 0，看门狗退出，不会覆盖别人的锁
+	if err := config.RedisClient.Set(ctx, "lock:lost", "other", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	srv.FastForward(10 * time.Second)
+	if got, _ := srv.Get("lock:lost"); got != "other" {
+		t.Errorf("lock value = %q, want other", got)
+	}
+	if ttl := srv.TTL("lock:lost"); ttl != -1 {
+		t.Errorf("TTL = %v, the watchdog must not renew a lock it lost", ttl)
+	}
+}
+
+func TestDoWithLock(t *testing.T) {
+	ctx := context.Background()
+	srv := setup(t)
+	errBusiness := errors.New("business failed")
+
+	err := DoWithLock(ctx, "lock:do", 0, func() error {
+		if !srv.Exists("lock:do") {
+			t.Error("lock not held during business logic")
+		}
+		// 锁被占用时再次获取应返回 ErrLockNotAcquired
+		inner := DoWithLockDefault(ctx, "lock:do", func() error {
+			t.Error("business logic must not run without the lock")
+			return nil
+		})
+		if !errors.Is(inner, ErrLockNotAcquired) {
+			t.Errorf("nested DoWithLock = %v, want ErrLockNotAcquired", inner)
+		}
+		return errBusiness
+	})
+	if !errors.Is(err, errBusiness) {
+		t.Errorf("DoWithLock = %v, want business error", err)
+	}
+	if srv.Exists("lock:do") {
+		t.Error("lock not released after DoWithLock")
+	}
+}
+
+func TestLockPassesContext(t *testing.T) {
+	setup(t)
+	hook := &redistest.TraceHook{}
+	config.RedisClient.AddHook(hook)
+	ctx := instrument.WithTraceID(context.Background(), "req-1")
+
+	if err := DoWithLock(ctx, "lock:trace", 0, func() error { return nil }); err != nil {
+		t.Fatalf("DoWithLock = %v", err)
+	}
+	// 脚本先以 EVALSHA 执行，脚本缓存中没有时 go-redis 改用 EVAL
+	for _, cmd := range hook.Commands() {
+		if !strings.HasSuffix(cmd, ":req-1") {
+			t.Errorf("command %s was sent without the caller's trace id", cmd)
+		}
+	}
+	if got := hook.Commands(); len(got) < 2 || got[0] != "set:req-1" {
+		t.Errorf("commands = %v, want SET followed by the unlock script", got)
+	}
+}
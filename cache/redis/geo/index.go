@@ -0,0 +1,140 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"redis/redisutil"
+)
+
+// Redis GEO 支持的坐标范围（EPSG:3857），超出范围 GEOADD 会直接报错
+const (
+	minLongitude = -180.0
+	maxLongitude = 180.0
+	minLatitude  = -85.05112878
+	maxLatitude  = 85.05112878
+)
+
+var ErrInvalidCoordinate = errors.New("invalid coordinate") //经纬度超出 Redis GEO 支持的范围
+
+// Location 实体坐标
+type Location struct {
+	Longitude float64 //经度
+	Latitude  float64 //纬度
+}
+
+// Place 附近搜索的结果
+type Place struct {
+	ID string
+	Location
+	Distance float64 //与搜索中心的距离，单位与 Index.Unit 一致
+}
+
+// Index 把某类实体（例如门店）的坐标维护在一个 GEO 有序集合中
+// 实体新增、改址、删除时调用 Sync/Remove 保持索引与业务数据一致
+type Index struct {
+	key  string
+	unit string
+}
+
+// NewIndex 构造索引对象，key 为 Redis 键名，unit 为距离单位（m、km、ft、mi），传空字符串默认 km
+func NewIndex(key, unit string) *Index {
+	if unit == "" {
+		unit = "km"
+	}
+	return &Index{key: key, unit: unit}
+}
+
+// Add 新增或更新实体坐标，GEOADD 对已存在的成员会直接覆盖坐标
+func (i *Index) Add(ctx context.Context, id string, loc Location) error {
+	if err := loc.validate(); err != nil {
+		return err
+	}
+	return redisutil.GeoAdd(ctx, i.key, &redis.GeoLocation{
+		Name:      id,
+		Longitude: loc.Longitude,
+		Latitude:  loc.Latitude,
+	})
+}
+
+// Remove 从索引中移除实体（实体被删除时调用）
+func (i *Index) Remove(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(ids))
+	for n, id := range ids {
+		members[n] = id
+	}
+	return redisutil.ZRem(ctx, i.key, members...)
+}
+
+// Sync 根据实体最新状态同步索引：loc 为 nil（没有坐标或已下线）时移除，否则新增或更新
+func (i *Index) Sync(ctx context.Context, id string, loc *Location) error {
+	if loc == nil {
+		return i.Remove(ctx, id)
+	}
+	return i.Add(ctx, id, *loc)
+}
+
+// Nearby 查找圆心 center 半径 radius 内的实体，按距离由近到远排序，limit 为0表示不限制数量
+func (i *Index) Nearby(ctx context.Context, center Location, radius float64, limit int) ([]Place, error) {
+	if err := center.validate(); err != nil {
+		return nil, err
+	}
+	locations, err := redisutil.GeoSearchRadius(ctx, i.key, center.Longitude, center.Latitude, radius, i.unit, limit)
+	if err != nil {
+		return nil, err
+	}
+	return toPlaces(locations), nil
+}
+
+// WithinBox 查找以 center 为中心、宽 width 高 height 的矩形内的实体，按距离由近到远排序
+func (i *Index) WithinBox(ctx context.Context, center Location, width, height float64, limit int) ([]Place, error) {
+	if err := center.validate(); err != nil {
+		return nil, err
+	}
+	locations, err := redisutil.GeoSearchBox(ctx, i.key, center.Longitude, center.Latitude, width, height, i.unit, limit)
+	if err != nil {
+		return nil, err
+	}
+	return toPlaces(locations), nil
+}
+
+// Distance 计算两个实体之间的距离，任一实体不在索引中时返回 redis.Nil
+func (i *Index) Distance(ctx context.Context, id1, id2 string) (float64, error) {
+	return redisutil.GeoDist(ctx, i.key, id1, id2, i.unit)
+}
+
+// Position 获取实体坐标，实体不在索引中时返回 nil
+func (i *Index) Position(ctx context.Context, id string) (*Location, error) {
+	positions, err := redisutil.GeoPos(ctx, i.key, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(positions) == 0 || positions[0] == nil {
+		return nil, nil
+	}
+	return &Location{Longitude: positions[0].Longitude, Latitude: positions[0].Latitude}, nil
+}
+
+func (l Location) validate() error {
+	if l.Longitude < minLongitude || l.Longitude > maxLongitude ||
+		l.Latitude < minLatitude || l.Latitude > maxLatitude {
+		return fmt.Errorf("%w: longitude=%v, latitude=%v", ErrInvalidCoordinate, l.Longitude, l.Latitude)
+	}
+	return nil
+}
+
+func toPlaces(locations []redis.GeoLocation) []Place {
+	places := make([]Place, len(locations))
+	for n, loc := range locations {
+		places[n] = Place{
+			ID:       loc.Name,
+			Location: Location{Longitude: loc.Longitude, Latitude: loc.Latitude},
+			Distance: loc.Dist,
+		}
+	}
+	return places
+}
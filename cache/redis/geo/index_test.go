@@ -0,0 +1,160 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"redis/redistest"
+	"reflect"
+	"testing"
+)
+
+// 广州、深圳的几个地点，距离天河的直线距离约为：珠江新城 2.0km、白云 5.7km、番禺 23.1km、深圳 100.1km
+var places = map[string]Location{
+	"tianhe":   {Longitude: 113.3245, Latitude: 23.1372},
+	"zhujiang": {Longitude: 113.3240, Latitude: 23.1190},
+	"baiyun":   {Longitude: 113.2730, Latitude: 23.1570},
+	"panyu":    {Longitude: 113.3840, Latitude: 22.9370},
+	"shenzhen": {Longitude: 114.0579, Latitude: 22.5431},
+}
+
+// setupIndex 启动假 Redis 并把 places 写入索引
+func setupIndex(t *testing.T) *Index {
+	t.Helper()
+	redistest.Setup(t)
+	idx := NewIndex("geo:stores", "km")
+	for id, loc := range places {
+		if err := idx.Add(context.Background(), id, loc); err != nil {
+			t.Fatalf("Add(%s): %v", id, err)
+		}
+	}
+	return idx
+}
+
+func ids(places []Place) []string {
+	result := make([]string, len(places))
+	for i, p := range places {
+		result[i] = p.ID
+	}
+	return result
+}
+
+func TestNearby(t *testing.T) {
+	ctx := context.Background()
+	idx := setupIndex(t)
+	tests := []struct {
+		name   string
+		radius float64
+		limit  int
+		want   []string
+	}{
+		{name: "only center", radius: 1, want: []string{"tianhe"}},
+		{name: "same district", radius: 10, want: []string{"tianhe", "zhujiang", "baiyun"}},
+		{name: "limit", radius: 10, limit: 2, want: []string{"tianhe", "zhujiang"}},
+		{name: "whole city", radius: 30, want: []string{"tianhe", "zhujiang", "baiyun", "panyu"}},
+		{name: "two cities", radius: 200, want: []string{"tianhe", "zhujiang", "baiyun", "panyu", "shenzhen"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := idx.Nearby(ctx, places["tianhe"], tt.radius, tt.limit)
+			if err != nil {
+				t.Fatalf("Nearby: %v", err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("Nearby(%vkm) = %v, want %v", tt.radius, ids(got), tt.want)
+			}
+			for _, p := range got {
+				if p.Distance > tt.radius {
+					t.Errorf("%s distance %v is outside the radius %v", p.ID, p.Distance, tt.radius)
+				}
+			}
+		})
+	}
+}
+
+func TestWithinBox(t *testing.T) {
+	ctx := context.Background()
+	idx := setupIndex(t)
+	// 珠江新城在天河以南 2.02km；白云在北 2.2km、西 5.27km；番禺在南 22.3km、东 6.1km
+	tests := []struct {
+		name          string
+		width, height float64
+		want          []string
+	}{
+		{name: "too short", width: 12, height: 4, want: []string{"tianhe"}},
+		{name: "wide", width: 12, height: 6, want: []string{"tianhe", "zhujiang", "baiyun"}},
+		{name: "narrow and tall", width: 10, height: 50, want: []string{"tianhe", "zhujiang"}},
+		{name: "tall", width: 14, height: 50, want: []string{"tianhe", "zhujiang", "baiyun", "panyu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := idx.WithinBox(ctx, places["tianhe"], tt.width, tt.height, 0)
+			if err != nil {
+				t.Fatalf("WithinBox: %v", err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("WithinBox(%vx%vkm) = %v, want %v", tt.width, tt.height, ids(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	ctx := context.Background()
+	idx := setupIndex(t)
+	tests := []struct {
+		id1, id2 string
+		want     float64
+		wantErr  error
+	}{
+		{id1: "tianhe", id2: "tianhe", want: 0},
+		{id1: "tianhe", id2: "zhujiang", want: 2.025},
+		{id1: "zhujiang", id2: "baiyun", want: 6.7137},
+		{id1: "tianhe", id2: "shenzhen", want: 100.0902},
+		{id1: "tianhe", id2: "missing", wantErr: redis.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id1+"-"+tt.id2, func(t *testing.T) {
+			got, err := idx.Distance(ctx, tt.id1, tt.id2)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Distance err = %v, want %v", err, tt.wantErr)
+			}
+			// 坐标按 geohash 存储，有不到 1 米的误差
+			if math.Abs(got-tt.want) > 0.005 {
+				t.Errorf("Distance = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncAndPosition(t *testing.T) {
+	ctx := context.Background()
+	idx := setupIndex(t)
+
+	pos, err := idx.Position(ctx, "panyu")
+	if err != nil || pos == nil {
+		t.Fatalf("Position = %v, %v", pos, err)
+	}
+	if math.Abs(pos.Longitude-places["panyu"].Longitude) > 1e-5 || math.Abs(pos.Latitude-places["panyu"].Latitude) > 1e-5 {
+		t.Errorf("Position = %+v, want %+v", *pos, places["panyu"])
+	}
+
+	// 改址后按新坐标搜索，下线后从索引中移除
+	if err := idx.Sync(ctx, "panyu", &Location{Longitude: 113.3250, Latitude: 23.1380}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got, _ := idx.Nearby(ctx, places["tianhe"], 1, 0); !reflect.DeepEqual(ids(got), []string{"tianhe", "panyu"}) {
+		t.Errorf("Nearby after move = %v, want [tianhe panyu]", ids(got))
+	}
+	if err := idx.Sync(ctx, "panyu", nil); err != nil {
+		t.Fatalf("Sync(nil): %v", err)
+	}
+	if pos, err := idx.Position(ctx, "panyu"); err != nil || pos != nil {
+		t.Errorf("Position after removal = %v, %v; want nil", pos, err)
+	}
+
+	if err := idx.Add(ctx, "pole", Location{Longitude: 0, Latitude: 89}); !errors.Is(err, ErrInvalidCoordinate) {
+		t.Errorf("Add outside the GEO range = %v, want ErrInvalidCoordinate", err)
+	}
+}
@@ -0,0 +1,107 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// 默认慢命令阈值
+const defaultSlowThreshold = 100 * time.Millisecond
+
+type traceIDKey struct{}
+
+// WithTraceID 把链路/请求ID放入上下文，使用该上下文执行的Redis命令在日志中会带上它
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext 从上下文取出链路/请求ID，没有时返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// Hook 实现 redis.Hook：记录每条命令的耗时和错误、输出慢命令日志，并在日志中带上上下文中的链路ID
+type Hook struct {
+	registry      *Registry
+	slowThreshold time.Duration
+}
+
+// NewHook 构造Hook，registry 为 nil 时使用 DefaultRegistry，slowThreshold <= 0 时使用默认 100 毫秒
+func NewHook(registry *Registry, slowThreshold time.Duration) *Hook {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &Hook{registry: registry, slowThreshold: slowThreshold}
+}
+
+// DialHook 建立连接不做处理
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook 单条命令
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(ctx, cmd, time.Since(start))
+		return err
+	}
+}
+
+// ProcessPipelineHook 管道/事务管道，整批耗时记到每条命令上
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		cost := time.Since(start)
+		for _, cmd := range cmds {
+			h.observe(ctx, cmd, cost)
+		}
+		return err
+	}
+}
+
+func (h *Hook) observe(ctx context.Context, cmd redis.Cmder, cost time.Duration) {
+	name := cmd.Name()
+	err := cmd.Err()
+	// redis.Nil 表示键不存在，属于正常结果不计入错误
+	failed := err != nil && !errors.Is(err, redis.Nil)
+	h.registry.Observe(name, cost, failed)
+
+	if cost >= h.slowThreshold {
+		log.Printf("redis慢命令：cmd=%s, key=%s, cost=%v, trace_id=%s", name, commandKey(cmd), cost, TraceIDFromContext(ctx))
+	}
+	if failed {
+		log.Printf("redis命令失败：cmd=%s, key=%s, err=%v, trace_id=%s", name, commandKey(cmd), err, TraceIDFromContext(ctx))
+	}
+}
+
+// commandKey 取命令操作的第一个键，EVAL/EVALSHA 取 KEYS[1]
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	switch strings.ToLower(cmd.Name()) {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro", "fcall", "fcall_ro":
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(args) > 3 && fmt.Sprint(args[2]) != "0" {
+			return fmt.Sprint(args[3])
+		}
+		return ""
+	}
+	if len(args) > 1 {
+		return fmt.Sprint(args[1])
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package instrument
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 命令耗时直方图的桶上限（秒），覆盖 0.5ms ~ 1s
+var durationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// commandStats 单个命令的统计数据
+type commandStats struct {
+	buckets []uint64 //每个桶的计数（非累计，输出时再累加）
+	count   uint64
+	sum     float64 //总耗时（秒）
+	errors  uint64
+}
+
+// Registry 按命令名汇总耗时和错误次数，可安全地被多个goroutine同时使用
+type Registry struct {
+	mu       sync.Mutex
+	commands map[string]*commandStats
+}
+
+// DefaultRegistry 默认的指标注册表，config.InitRedisClient 注册的 Hook 使用它
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry 构造指标注册表
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]*commandStats)}
+}
+
+// Observe 记录一次命令执行，failed 为 true 时错误计数加一
+func (r *Registry) Observe(cmd string, cost time.Duration, failed bool) {
+	seconds := cost.Seconds()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.commands[cmd]
+	if !ok {
+		s = &commandStats{buckets: make([]uint64, len(durationBuckets))}
+		r.commands[cmd] = s
+	}
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += seconds
+	if failed {
+		s.errors++
+	}
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出所有指标
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.commands))
+	snapshot := make(map[string]commandStats, len(r.commands))
+	for name, s := range r.commands {
+		names = append(names, name)
+		c := *s
+		c.buckets = append([]uint64(nil), s.buckets...)
+		snapshot[name] = c
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	if _, err := fmt.Fprint(w, "# HELP redis_command_duration_seconds Redis command latency in seconds.\n"+
+		"# TYPE redis_command_duration_seconds histogram\n"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		s := snapshot[name]
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			cumulative += s.buckets[i]
+			if _, err := fmt.Fprintf(w, "redis_command_duration_seconds_bucket{cmd=%q,le=\"%g\"} %d\n", name, le, cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "redis_command_duration_seconds_bucket{cmd=%q,le=\"+Inf\"} %d\n"+
+			"redis_command_duration_seconds_sum{cmd=%q} %g\n"+
+			"redis_command_duration_seconds_count{cmd=%q} %d\n",
+			name, s.count, name, s.sum, name, s.count); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "# HELP redis_command_errors_total Redis commands that returned an error.\n"+
+		"# TYPE redis_command_errors_total counter\n"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "redis_command_errors_total{cmd=%q} %d\n", name, snapshot[name].errors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler 返回输出 DefaultRegistry 的 http.Handler，挂到 /metrics 供 Prometheus 抓取
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = DefaultRegistry.WritePrometheus(w)
+	})
+}
@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"os"
 	"redis/config"
+	"redis/counter"
 	"redis/distributed"
+	"redis/geo"
+	"redis/instrument"
 	"redis/redisutil"
 	"strconv"
 	"sync"
@@ -24,10 +28,13 @@ redis操作
 1.下载go-redis库：go get github.com/redis/go-redis/v9
 */
 func main() {
-	// 只需在应用初始化时调用一次
-	config.InitRedisClient(&redis.Options{
+	// 只需在应用初始化时调用一次；FastFailTimeouts 使用较短的超时，Redis 宕机时调用方不会被长时间阻塞
+	config.InitRedisClient(config.FastFailTimeouts(&redis.Options{
 		Addr: "localhost:6379",
-	})
+	}))
+	// Redis 不可用时的降级策略：读取用户缓存返回本地旧值，写缓存直接跳过（默认直接返回错误）
+	_ = redisutil.SetFallback("GetByte", redisutil.ServeStale)
+	_ = redisutil.SetFallback("Set", redisutil.SkipCache)
 
 	// 调用 DoWithLockDefault 分布式锁，锁超时时间默认 30 秒，超时后看门狗自动续期【分布式锁】
 	for i := 0; i < numWorkers; i++ { //开启1000个协程，看看分布式锁是否成功。
@@ -55,6 +62,18 @@ func main() {
 	// 原子事务操作（事务管道）【一次性操作多条redis命令时推荐】
 	txPipelined()
 
+	// 地理位置：查找附近 5 km 内的门店
+	nearbyStores()
+
+	// 计数器：库存不能为负、下单预留/提交/回滚
+	stockCounter()
+
+	// 监控：带链路ID执行命令，并以 Prometheus 文本格式输出命令耗时和错误计数
+	printMetrics()
+
+	// 健康状态：熔断器打开时不健康，可通过 redisutil.HealthHandler 挂到 /health/redis
+	log.Printf("redis健康状态：%+v", redisutil.Health())
+
 }
 
 type user struct {
@@ -67,7 +86,7 @@ type user struct {
 // 调用 DoWithLockDefault 分布式锁，锁超时时间默认 30 秒，超时后看门狗自动续期
 func doWithLockDefault() {
 	defer wg.Done() // 完成后通知WaitGroup
-	err := distributed.DoWithLockDefault("lockName", func() error {
+	err := distributed.DoWithLockDefault(context.Background(), "lockName", func() error {
 		log.Println("执行业务逻辑...")
 		// 模拟业务耗时
 		time.Sleep(3 * time.Second)
@@ -121,6 +140,8 @@ func increment(key string, maxRetries int, fn func(pipe redis.Pipeliner) error)
 
 // 原子操作示例（单条命令基本上都是原子操作）
 func redisSet() {
+	// 带链路ID的上下文，redisutil 的命令出错或变慢时日志会带上 trace_id
+	ctx := instrument.WithTraceID(context.Background(), "req-0002")
 	//1.序列化结构体
 	u := &user{}
 	u.Id = 1
@@ -134,12 +155,12 @@ func redisSet() {
 	}
 	//2.存储到Redis（设置过期时间30秒）
 	setKey := "u:" + strconv.Itoa(u.Id)
-	if err := redisutil.Set(setKey, userJSON, 30*time.Second); err != nil {
+	if err := redisutil.Set(ctx, setKey, userJSON, 30*time.Second); err != nil {
 		log.Printf("redisutil.Set：%v", err)
 		return
 	}
 	//3.从Redis读取数据
-	v, _ := redisutil.GetByte(setKey) //默认的Get是获取string
+	v, _ := redisutil.GetByte(ctx, setKey) //默认的Get是获取string
 	log.Printf("获取%v的value:%s\n", setKey, v)
 	//4.反序列化JSON到结构体
 	var retrievedUser user
@@ -155,7 +176,7 @@ func redisSet() {
 // 非原子批量操作（普通管道）【一次性操作多条redis命令时不推荐】
 func pipelined() {
 	ctx := context.Background()
-	if err := redisutil.Pipelined(func(pipeliner redis.Pipeliner) error {
+	if err := redisutil.Pipelined(ctx, func(pipeliner redis.Pipeliner) error {
 		pipeliner.Set(ctx, "key1", "Meta39", 30*time.Second)
 		v, _ := pipeliner.Get(ctx, "key1").Result()
 		log.Println("一次性操作多条redis命令 Pipelined 普通管道（非原子操作） key1:", v)
@@ -169,7 +190,7 @@ func pipelined() {
 // 原子事务操作（事务管道）【一次性操作多条redis命令时推荐】
 func txPipelined() {
 	ctx := context.Background()
-	if err := redisutil.TxPipelined(func(pipeliner redis.Pipeliner) error {
+	if err := redisutil.TxPipelined(ctx, func(pipeliner redis.Pipeliner) error {
 		pipeliner.Set(ctx, "key2", "Meta2", 30*time.Second)
 		v2, _ := pipeliner.Get(ctx, "key2").Result()
 		log.Println("一次性操作多条redis命令 TxPipelined 事务管道（原子操作） key2:", v2)
@@ -179,3 +200,56 @@ func txPipelined() {
 		return
 	}
 }
+
+// 地理位置：门店坐标维护在 GEO 索引中，查找附近 5 km 内的门店
+func nearbyStores() {
+	ctx := context.Background()
+	stores := geo.NewIndex("geo:stores", "km")
+	// 门店新增或改址时同步索引
+	_ = stores.Sync(ctx, "store:1", &geo.Location{Longitude: 113.324520, Latitude: 23.099994})
+	_ = stores.Sync(ctx, "store:2", &geo.Location{Longitude: 113.330000, Latitude: 23.120000})
+	_ = stores.Sync(ctx, "store:3", &geo.Location{Longitude: 113.600000, Latitude: 23.500000})
+	// 门店下线时传 nil 移除
+	defer stores.Sync(ctx, "store:3", nil)
+
+	places, err := stores.Nearby(ctx, geo.Location{Longitude: 113.320000, Latitude: 23.100000}, 5, 10)
+	if err != nil {
+		log.Printf("stores.Nearby：%v", err)
+		return
+	}
+	for _, p := range places {
+		log.Printf("附近门店 %s 距离 %.2f km，坐标(%f, %f)", p.ID, p.Distance, p.Longitude, p.Latitude)
+	}
+}
+
+// 监控：带链路ID执行命令，慢命令和失败命令的日志会带上 trace_id；指标可通过 instrument.Handler 挂到 /metrics
+func printMetrics() {
+	ctx := instrument.WithTraceID(context.Background(), "req-0001")
+	_, _ = config.RedisClient.Get(ctx, "u:1").Result()
+	if err := instrument.DefaultRegistry.WritePrometheus(os.Stdout); err != nil {
+		log.Printf("WritePrometheus：%v", err)
+	}
+}
+
+// 计数器：库存不能为负、下单预留/提交/回滚
+func stockCounter() {
+	ctx := context.Background()
+	stock := counter.NewBounded("stock:1001", 0, counter.NoCeiling)
+	_ = stock.Set(ctx, 1)
+	if _, err := stock.DecrBy(ctx, 2); errors.Is(err, counter.ErrOutOfRange) {
+		log.Println("库存不足，扣减失败")
+	}
+
+	inventory := counter.NewInventory("stock:1002")
+	_ = inventory.SetAvailable(ctx, 10)
+	if err := inventory.Reserve(ctx, "order-1", 3); err != nil {
+		log.Printf("预留库存失败：%v", err)
+		return
+	}
+	// 支付成功提交，取消订单则 inventory.Rollback(ctx, "order-1")
+	if err := inventory.Commit(ctx, "order-1"); err != nil {
+		log.Printf("提交预留失败：%v", err)
+	}
+	available, _ := inventory.Available(ctx)
+	log.Printf("stock:1002 可用库存：%d", available)
+}
@@ -0,0 +1,114 @@
+package redistest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 可控时钟，假 Redis 用它判断键是否过期
+// 时间默认静止，只有调用 Advance/Set 才会前进，测试过期和续期时不需要真的等待
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*Ticker
+}
+
+/*
+Ticker 由 Clock 驱动的定时器，用法同 time.Ticker，用于替换被测代码中的 time.NewTicker（例如分布式锁的看门狗）。
+与 time.Ticker 不同，C 没有缓冲：时钟前进越过触发时间时，Advance 会等接收方取走这次触发才继续，
+所以 Advance 返回时，除最后一次以外的触发都已经被接收方处理完（接收方处理完才会回来接收下一次）。
+*/
+type Ticker struct {
+	C <-chan time.Time
+
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// Stop 停止触发，之后 Advance 不再等待这个 Ticker
+func (t *Ticker) Stop() {
+	t.once.Do(func() { close(t.stopped) })
+}
+
+func (t *Ticker) isStopped() bool {
+	select {
+	case <-t.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewClock 构造从 start 开始的时钟
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now 当前时间
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker 创建每隔 d 触发一次的 Ticker，第一次在当前时间 +d 时触发
+func (c *Clock) NewTicker(d time.Duration) *Ticker {
+	if d <= 0 {
+		panic("redistest: non-positive interval for Clock.NewTicker")
+	}
+	ch := make(chan time.Time)
+	t := &Ticker{C: ch, c: ch, period: d, stopped: make(chan struct{})}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.next = c.now.Add(d)
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 时钟前进 d，途中到期的 Ticker 按时间顺序逐次触发
+func (c *Clock) Advance(d time.Duration) {
+	c.advanceTo(c.Now().Add(d))
+}
+
+// Set 把时钟设置为 t，t 晚于当前时间时途中到期的 Ticker 逐次触发
+func (c *Clock) Set(t time.Time) {
+	c.advanceTo(t)
+}
+
+func (c *Clock) advanceTo(target time.Time) {
+	for {
+		c.mu.Lock()
+		var due *Ticker
+		active := c.tickers[:0]
+		for _, t := range c.tickers {
+			if t.isStopped() {
+				continue
+			}
+			active = append(active, t)
+			if !t.next.After(target) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		c.tickers = active
+		if due == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		now := due.next
+		if now.After(c.now) {
+			c.now = now
+		}
+		due.next = due.next.Add(due.period)
+		c.mu.Unlock()
+
+		// 在锁外发送：接收方处理触发时通常会访问假 Redis，而假 Redis 需要读取时钟
+		select {
+		case due.c <- now:
+		case <-due.stopped:
+		}
+	}
+}
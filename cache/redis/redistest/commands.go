@@ -0,0 +1,1149 @@
+package redistest
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command 命令定义，arity 与 Redis 约定一致：正数为参数个数（含命令名），负数为最少参数个数
+type command struct {
+	fn    func(s *Server, args []string) interface{}
+	arity int
+}
+
+// 值类型
+type (
+	hashValue map[string]string
+	listValue struct{ items []string }
+	setValue  map[string]struct{}
+	zsetValue map[string]float64
+)
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// 连接
+		"ping":   {cmdPing, -1},
+		"echo":   {cmdEcho, 2},
+		"hello":  {cmdHello, -1},
+		"client": {cmdOK, -2},
+		"select": {cmdSelect, 2},
+
+		// 键管理
+		"del":       {cmdDel, -2},
+		"unlink":    {cmdDel, -2},
+		"exists":    {cmdExists, -2},
+		"expire":    {cmdExpire, -3},
+		"pexpire":   {cmdPExpire, -3},
+		"expireat":  {cmdExpireAt, -3},
+		"pexpireat": {cmdPExpireAt, -3},
+		"ttl":       {cmdTTL, 2},
+		"pttl":      {cmdPTTL, 2},
+		"persist":   {cmdPersist, 2},
+		"type":      {cmdType, 2},
+		"keys":      {cmdKeys, 2},
+		"dbsize":    {cmdDBSize, 1},
+		"flushdb":   {cmdFlush, -1},
+		"flushall":  {cmdFlush, -1},
+
+		// 字符串
+		"get":         {cmdGet, 2},
+		"set":         {cmdSet, -3},
+		"setnx":       {cmdSetNX, 3},
+		"setex":       {cmdSetEX, 4},
+		"mget":        {cmdMGet, -2},
+		"mset":        {cmdMSet, -3},
+		"incr":        {cmdIncr, 2},
+		"decr":        {cmdDecr, 2},
+		"incrby":      {cmdIncrBy, 3},
+		"decrby":      {cmdDecrBy, 3},
+		"incrbyfloat": {cmdIncrByFloat, 3},
+
+		// 哈希
+		"hset":    {cmdHSet, -4},
+		"hmset":   {cmdHMSet, -4},
+		"hget":    {cmdHGet, 3},
+		"hmget":   {cmdHMGet, -3},
+		"hgetall": {cmdHGetAll, 2},
+		"hdel":    {cmdHDel, -3},
+		"hexists": {cmdHExists, 3},
+		"hlen":    {cmdHLen, 2},
+		"hincrby": {cmdHIncrBy, 4},
+
+		// 列表
+		"lpush":  {cmdLPush, -3},
+		"rpush":  {cmdRPush, -3},
+		"lpop":   {cmdLPop, -2},
+		"rpop":   {cmdRPop, -2},
+		"llen":   {cmdLLen, 2},
+		"lrange": {cmdLRange, 4},
+
+		// 集合
+		"sadd":      {cmdSAdd, -3},
+		"srem":      {cmdSRem, -3},
+		"smembers":  {cmdSMembers, 2},
+		"sismember": {cmdSIsMember, 3},
+		"scard":     {cmdSCard, 2},
+
+		// 有序集合
+		"zadd":          {cmdZAdd, -4},
+		"zrem":          {cmdZRem, -3},
+		"zscore":        {cmdZScore, 3},
+		"zincrby":       {cmdZIncrBy, 4},
+		"zcard":         {cmdZCard, 2},
+		"zrange":        {cmdZRange, -4},
+		"zrangebyscore": {cmdZRangeByScore, -4},
+
+		// 地理位置（存储为有序集合，见 geo.go）
+		"geoadd":    {cmdGeoAdd, -5},
+		"geopos":    {cmdGeoPos, -2},
+		"geodist":   {cmdGeoDist, -4},
+		"geosearch": {cmdGeoSearch, -7},
+
+		// 脚本、发布
+		"eval":    {cmdEval, -3},
+		"evalsha": {cmdEvalSha, -3},
+		"script":  {cmdScript, -2},
+		"publish": {cmdPublish, 3},
+	}
+}
+
+func lookupCommand(args []string) (command, interface{}) {
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return cmd, errReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		return cmd, errArity(name)
+	}
+	return cmd, nil
+}
+
+func errArity(name string) errReply {
+	return errReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+var (
+	errNotInteger = errReply("ERR value is not an integer or out of range")
+	errNotFloat   = errReply("ERR value is not a valid float")
+	errSyntax     = errReply("ERR syntax error")
+)
+
+// ---------------------- 连接 ----------------------
+
+func cmdPing(_ *Server, args []string) interface{} {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return status("PONG")
+}
+
+func cmdEcho(_ *Server, args []string) interface{} {
+	return args[1]
+}
+
+// cmdHello 只支持 RESP2，go-redis 收到错误后会回退到 RESP2
+func cmdHello(_ *Server, _ []string) interface{} {
+	return errReply("ERR unknown command 'HELLO'")
+}
+
+func cmdOK(_ *Server, _ []string) interface{} {
+	return statusOK
+}
+
+func cmdSelect(_ *Server, args []string) interface{} {
+	if args[1] != "0" {
+		return errReply("ERR fake redis only supports DB 0")
+	}
+	return statusOK
+}
+
+// ---------------------- 键管理 ----------------------
+
+func cmdDel(s *Server, args []string) interface{} {
+	var n int64
+	for _, key := range args[1:] {
+		if s.lookup(key) != nil && s.remove(key) {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *Server, args []string) interface{} {
+	var n int64
+	for _, key := range args[1:] {
+		if s.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExpire(s *Server, args []string) interface{} {
+	return expire(s, args, time.Second)
+}
+
+func cmdPExpire(s *Server, args []string) interface{} {
+	return expire(s, args, time.Millisecond)
+}
+
+// expire 设置过期时间，非正数的过期时间会立即删除键（与 Redis 一致）
+func expire(s *Server, args []string, unit time.Duration) interface{} {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	e := s.lookup(args[1])
+	if e == nil {
+		return int64(0)
+	}
+	if n <= 0 {
+		s.remove(args[1])
+		return int64(1)
+	}
+	e.expireAt = s.clock.Now().Add(time.Duration(n) * unit)
+	s.touch(args[1])
+	return int64(1)
+}
+
+func cmdExpireAt(s *Server, args []string) interface{} {
+	return expireAt(s, args, time.Second)
+}
+
+func cmdPExpireAt(s *Server, args []string) interface{} {
+	return expireAt(s, args, time.Millisecond)
+}
+
+// expireAt 设置过期的绝对时间（Unix 时间戳），已过去的时间会立即删除键
+func expireAt(s *Server, args []string, unit time.Duration) interface{} {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	e := s.lookup(args[1])
+	if e == nil {
+		return int64(0)
+	}
+	at := time.Unix(0, 0).Add(time.Duration(n) * unit)
+	if !at.After(s.clock.Now()) {
+		s.remove(args[1])
+		return int64(1)
+	}
+	e.expireAt = at
+	s.touch(args[1])
+	return int64(1)
+}
+
+func cmdTTL(s *Server, args []string) interface{} {
+	return ttl(s, args[1], time.Second)
+}
+
+func cmdPTTL(s *Server, args []string) interface{} {
+	return ttl(s, args[1], time.Millisecond)
+}
+
+func ttl(s *Server, key string, unit time.Duration) interface{} {
+	e := s.lookup(key)
+	switch {
+	case e == nil:
+		return int64(-2)
+	case e.expireAt.IsZero():
+		return int64(-1)
+	}
+	remaining := e.expireAt.Sub(s.clock.Now())
+	// 与 Redis 一致四舍五入
+	return int64((remaining + unit/2) / unit)
+}
+
+func cmdPersist(s *Server, args []string) interface{} {
+	e := s.lookup(args[1])
+	if e == nil || e.expireAt.IsZero() {
+		return int64(0)
+	}
+	e.expireAt = time.Time{}
+	s.touch(args[1])
+	return int64(1)
+}
+
+func cmdType(s *Server, args []string) interface{} {
+	e := s.lookup(args[1])
+	if e == nil {
+		return status("none")
+	}
+	switch e.value.(type) {
+	case string:
+		return status("string")
+	case hashValue:
+		return status("hash")
+	case *listValue:
+		return status("list")
+	case setValue:
+		return status("set")
+	case zsetValue:
+		return status("zset")
+	}
+	return status("none")
+}
+
+// cmdKeys 使用 path.Match 近似 Redis 的 glob 匹配
+func cmdKeys(s *Server, args []string) interface{} {
+	keys := make([]string, 0)
+	for key := range s.data {
+		if s.lookup(key) == nil {
+			continue
+		}
+		if ok, _ := path.Match(args[1], key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cmdDBSize(s *Server, _ []string) interface{} {
+	var n int64
+	for key := range s.data {
+		if s.lookup(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdFlush(s *Server, _ []string) interface{} {
+	s.flush()
+	return statusOK
+}
+
+// ---------------------- 字符串 ----------------------
+
+func cmdGet(s *Server, args []string) interface{} {
+	v, ok, errRep := lookupAs[string](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// cmdSet SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]
+func cmdSet(s *Server, args []string) interface{} {
+	key, value := args[1], args[2]
+	var nx, xx, get, keepTTL bool
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "get":
+			get = true
+		case "keepttl":
+			keepTTL = true
+		case "ex", "px":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errReply("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if strings.EqualFold(args[i], "px") {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+
+	old := s.lookup(key)
+	var oldValue interface{}
+	if old != nil {
+		str, ok := old.value.(string)
+		if !ok && get {
+			return errWrongType
+		}
+		oldValue = str
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return oldValue
+		}
+		return nil
+	}
+
+	e := &entry{value: value}
+	if keepTTL && old != nil {
+		e.expireAt = old.expireAt
+	}
+	if ttl > 0 {
+		e.expireAt = s.clock.Now().Add(ttl)
+	}
+	s.data[key] = e
+	s.touch(key)
+	if get {
+		return oldValue
+	}
+	return statusOK
+}
+
+func cmdSetNX(s *Server, args []string) interface{} {
+	if s.lookup(args[1]) != nil {
+		return int64(0)
+	}
+	s.put(args[1], args[2])
+	return int64(1)
+}
+
+func cmdSetEX(s *Server, args []string) interface{} {
+	return cmdSet(s, []string{"set", args[1], args[3], "ex", args[2]})
+}
+
+func cmdMGet(s *Server, args []string) interface{} {
+	values := make([]interface{}, len(args)-1)
+	for i, key := range args[1:] {
+		// 类型不符的键在 MGET 中返回 nil
+		if v, ok, _ := lookupAs[string](s, key); ok {
+			values[i] = v
+		}
+	}
+	return values
+}
+
+func cmdMSet(s *Server, args []string) interface{} {
+	if len(args)%2 != 1 {
+		return errArity("mset")
+	}
+	for i := 1; i < len(args); i += 2 {
+		s.put(args[i], args[i+1])
+	}
+	return statusOK
+}
+
+func cmdIncr(s *Server, args []string) interface{} {
+	return incrBy(s, args[1], 1)
+}
+
+func cmdDecr(s *Server, args []string) interface{} {
+	return incrBy(s, args[1], -1)
+}
+
+func cmdIncrBy(s *Server, args []string) interface{} {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	return incrBy(s, args[1], n)
+}
+
+func cmdDecrBy(s *Server, args []string) interface{} {
+	n, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	return incrBy(s, args[1], -n)
+}
+
+// incrBy 递增整数字符串，保留原有过期时间
+func incrBy(s *Server, key string, delta int64) interface{} {
+	v, ok, errRep := lookupAs[string](s, key)
+	if errRep != nil {
+		return errRep
+	}
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errNotInteger
+		}
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return errReply("ERR increment or decrement would overflow")
+	}
+	n += delta
+	setKeepTTL(s, key, strconv.FormatInt(n, 10))
+	return n
+}
+
+func cmdIncrByFloat(s *Server, args []string) interface{} {
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return errNotFloat
+	}
+	v, ok, errRep := lookupAs[string](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	var f float64
+	if ok {
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			return errNotFloat
+		}
+	}
+	f += delta
+	setKeepTTL(s, args[1], formatFloat(f))
+	return formatFloat(f)
+}
+
+// setKeepTTL 修改字符串的值但保留过期时间
+func setKeepTTL(s *Server, key, value string) {
+	if e := s.lookup(key); e != nil {
+		e.value = value
+		s.touch(key)
+		return
+	}
+	s.put(key, value)
+}
+
+// ---------------------- 哈希 ----------------------
+
+// hashForWrite 取出哈希表，不存在时创建
+func hashForWrite(s *Server, key string) (hashValue, interface{}) {
+	h, ok, errRep := lookupAs[hashValue](s, key)
+	if errRep != nil {
+		return nil, errRep
+	}
+	if !ok {
+		h = make(hashValue)
+		s.put(key, h)
+	}
+	s.touch(key)
+	return h, nil
+}
+
+func cmdHSet(s *Server, args []string) interface{} {
+	if len(args)%2 != 0 {
+		return errArity("hset")
+	}
+	h, errRep := hashForWrite(s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	var added int64
+	for i := 2; i < len(args); i += 2 {
+		if _, ok := h[args[i]]; !ok {
+			added++
+		}
+		h[args[i]] = args[i+1]
+	}
+	return added
+}
+
+func cmdHMSet(s *Server, args []string) interface{} {
+	if r := cmdHSet(s, args); isErr(r) {
+		return r
+	}
+	return statusOK
+}
+
+func cmdHGet(s *Server, args []string) interface{} {
+	h, ok, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if !ok {
+		return nil
+	}
+	if v, ok := h[args[2]]; ok {
+		return v
+	}
+	return nil
+}
+
+func cmdHMGet(s *Server, args []string) interface{} {
+	h, _, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	values := make([]interface{}, len(args)-2)
+	for i, field := range args[2:] {
+		if v, ok := h[field]; ok {
+			values[i] = v
+		}
+	}
+	return values
+}
+
+func cmdHGetAll(s *Server, args []string) interface{} {
+	h, _, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	fields := make([]string, 0, len(h))
+	for f := range h {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	values := make([]string, 0, len(h)*2)
+	for _, f := range fields {
+		values = append(values, f, h[f])
+	}
+	return values
+}
+
+func cmdHDel(s *Server, args []string) interface{} {
+	h, ok, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil || !ok {
+		return orZero(errRep)
+	}
+	var n int64
+	for _, f := range args[2:] {
+		if _, ok := h[f]; ok {
+			delete(h, f)
+			n++
+		}
+	}
+	if len(h) == 0 {
+		s.remove(args[1])
+	} else if n > 0 {
+		s.touch(args[1])
+	}
+	return n
+}
+
+func cmdHExists(s *Server, args []string) interface{} {
+	h, _, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if _, ok := h[args[2]]; ok {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+func cmdHLen(s *Server, args []string) interface{} {
+	h, _, errRep := lookupAs[hashValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	return int64(len(h))
+}
+
+func cmdHIncrBy(s *Server, args []string) interface{} {
+	delta, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	h, errRep := hashForWrite(s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	var n int64
+	if v, ok := h[args[2]]; ok {
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errReply("ERR hash value is not an integer")
+		}
+	}
+	n += delta
+	h[args[2]] = strconv.FormatInt(n, 10)
+	return n
+}
+
+// ---------------------- 列表 ----------------------
+
+func listForWrite(s *Server, key string) (*listValue, interface{}) {
+	l, ok, errRep := lookupAs[*listValue](s, key)
+	if errRep != nil {
+		return nil, errRep
+	}
+	if !ok {
+		l = &listValue{}
+		s.put(key, l)
+	}
+	s.touch(key)
+	return l, nil
+}
+
+func cmdLPush(s *Server, args []string) interface{} {
+	l, errRep := listForWrite(s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	for _, v := range args[2:] {
+		l.items = append([]string{v}, l.items...)
+	}
+	return int64(len(l.items))
+}
+
+func cmdRPush(s *Server, args []string) interface{} {
+	l, errRep := listForWrite(s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	l.items = append(l.items, args[2:]...)
+	return int64(len(l.items))
+}
+
+func cmdLPop(s *Server, args []string) interface{} {
+	return pop(s, args, true)
+}
+
+func cmdRPop(s *Server, args []string) interface{} {
+	return pop(s, args, false)
+}
+
+// pop LPOP/RPOP key [count]，带 count 时返回数组
+func pop(s *Server, args []string, left bool) interface{} {
+	l, ok, errRep := lookupAs[*listValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	count, withCount := 1, len(args) > 2
+	if withCount {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return errNotInteger
+		}
+		count = n
+	}
+	if !ok {
+		if withCount {
+			return nilArray{}
+		}
+		return nil
+	}
+	count = min(count, len(l.items))
+	var popped []string
+	if left {
+		popped = append(popped, l.items[:count]...)
+		l.items = l.items[count:]
+	} else {
+		for i := 0; i < count; i++ {
+			popped = append(popped, l.items[len(l.items)-1-i])
+		}
+		l.items = l.items[:len(l.items)-count]
+	}
+	if len(l.items) == 0 {
+		s.remove(args[1])
+	} else {
+		s.touch(args[1])
+	}
+	if withCount {
+		return popped
+	}
+	return popped[0]
+}
+
+func cmdLLen(s *Server, args []string) interface{} {
+	l, ok, errRep := lookupAs[*listValue](s, args[1])
+	if errRep != nil || !ok {
+		return orZero(errRep)
+	}
+	return int64(len(l.items))
+}
+
+func cmdLRange(s *Server, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInteger
+	}
+	l, ok, errRep := lookupAs[*listValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if !ok {
+		return []string{}
+	}
+	lo, hi, ok := normalizeRange(start, stop, len(l.items))
+	if !ok {
+		return []string{}
+	}
+	return append([]string(nil), l.items[lo:hi+1]...)
+}
+
+// normalizeRange 把 Redis 的闭区间下标（支持负数）转换为切片下标
+func normalizeRange(start, stop, n int) (int, int, bool) {
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// ---------------------- 集合 ----------------------
+
+func cmdSAdd(s *Server, args []string) interface{} {
+	set, ok, errRep := lookupAs[setValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if !ok {
+		set = make(setValue)
+		s.put(args[1], set)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := set[m]; !ok {
+			set[m] = struct{}{}
+			n++
+		}
+	}
+	s.touch(args[1])
+	return n
+}
+
+func cmdSRem(s *Server, args []string) interface{} {
+	set, ok, errRep := lookupAs[setValue](s, args[1])
+	if errRep != nil || !ok {
+		return orZero(errRep)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := set[m]; ok {
+			delete(set, m)
+			n++
+		}
+	}
+	if len(set) == 0 {
+		s.remove(args[1])
+	} else if n > 0 {
+		s.touch(args[1])
+	}
+	return n
+}
+
+func cmdSMembers(s *Server, args []string) interface{} {
+	set, _, errRep := lookupAs[setValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	members := make([]string, 0, len(set))
+	for m := range set {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func cmdSIsMember(s *Server, args []string) interface{} {
+	set, _, errRep := lookupAs[setValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if _, ok := set[args[2]]; ok {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+func cmdSCard(s *Server, args []string) interface{} {
+	set, _, errRep := lookupAs[setValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	return int64(len(set))
+}
+
+// ---------------------- 有序集合 ----------------------
+
+type scoredMember struct {
+	member string
+	score  float64
+}
+
+// sorted 按分数升序、分数相同按成员字典序排序
+func (z zsetValue) sorted() []scoredMember {
+	members := make([]scoredMember, 0, len(z))
+	for m, score := range z {
+		members = append(members, scoredMember{m, score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].member < members[j].member
+	})
+	return members
+}
+
+// cmdZAdd ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func cmdZAdd(s *Server, args []string) interface{} {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "gt":
+			gt = true
+		case "lt":
+			lt = true
+		case "ch":
+			ch = true
+		case "incr":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (incr && len(pairs) != 2) {
+		return errSyntax
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		f, err := parseFloat(pairs[j])
+		if err != nil {
+			return errNotFloat
+		}
+		scores = append(scores, f)
+	}
+
+	z, ok, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if !ok {
+		if xx {
+			if incr {
+				return nil
+			}
+			return int64(0)
+		}
+		z = make(zsetValue)
+		s.put(args[1], z)
+	}
+
+	var added, changed int64
+	var result interface{}
+	for j := 0; j < len(pairs); j += 2 {
+		member, score := pairs[j+1], scores[j/2]
+		old, exists := z[member]
+		if (nx && exists) || (xx && !exists) {
+			continue
+		}
+		if incr && exists {
+			score += old
+		}
+		if exists && ((gt && score <= old) || (lt && score >= old)) {
+			continue
+		}
+		z[member] = score
+		result = score
+		if !exists {
+			added++
+		} else if old != score {
+			changed++
+		}
+	}
+	if len(z) == 0 {
+		s.remove(args[1])
+	} else {
+		s.touch(args[1])
+	}
+	if incr {
+		return result
+	}
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+func cmdZRem(s *Server, args []string) interface{} {
+	z, ok, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil || !ok {
+		return orZero(errRep)
+	}
+	var n int64
+	for _, m := range args[2:] {
+		if _, ok := z[m]; ok {
+			delete(z, m)
+			n++
+		}
+	}
+	if len(z) == 0 {
+		s.remove(args[1])
+	} else if n > 0 {
+		s.touch(args[1])
+	}
+	return n
+}
+
+func cmdZScore(s *Server, args []string) interface{} {
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	if score, ok := z[args[2]]; ok {
+		return score
+	}
+	return nil
+}
+
+func cmdZIncrBy(s *Server, args []string) interface{} {
+	return cmdZAdd(s, []string{"zadd", args[1], "incr", args[2], args[3]})
+}
+
+func cmdZCard(s *Server, args []string) interface{} {
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	return int64(len(z))
+}
+
+// cmdZRange ZRANGE key start stop [WITHSCORES]（仅支持按下标）
+func cmdZRange(s *Server, args []string) interface{} {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return errNotInteger
+	}
+	withScores := false
+	for _, opt := range args[4:] {
+		if !strings.EqualFold(opt, "withscores") {
+			return errSyntax
+		}
+		withScores = true
+	}
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	members := z.sorted()
+	lo, hi, ok := normalizeRange(start, stop, len(members))
+	if !ok {
+		return []string{}
+	}
+	return zsetReply(members[lo:hi+1], withScores)
+}
+
+// cmdZRangeByScore ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func cmdZRangeByScore(s *Server, args []string) interface{} {
+	minScore, minEx, err1 := parseScoreBound(args[2])
+	maxScore, maxEx, err2 := parseScoreBound(args[3])
+	if err1 != nil || err2 != nil {
+		return errReply("ERR min or max is not a float")
+	}
+	withScores, offset, count := false, 0, -1
+	for i := 4; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			var err error
+			if offset, err = strconv.Atoi(args[i+1]); err != nil {
+				return errNotInteger
+			}
+			if count, err = strconv.Atoi(args[i+2]); err != nil {
+				return errNotInteger
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	var matched []scoredMember
+	for _, m := range z.sorted() {
+		if (m.score < minScore || (minEx && m.score == minScore)) ||
+			(m.score > maxScore || (maxEx && m.score == maxScore)) {
+			continue
+		}
+		matched = append(matched, m)
+	}
+	if offset > 0 {
+		matched = matched[min(offset, len(matched)):]
+	}
+	if count >= 0 && count < len(matched) {
+		matched = matched[:count]
+	}
+	return zsetReply(matched, withScores)
+}
+
+func zsetReply(members []scoredMember, withScores bool) []string {
+	reply := make([]string, 0, len(members)*2)
+	for _, m := range members {
+		reply = append(reply, m.member)
+		if withScores {
+			reply = append(reply, formatFloat(m.score))
+		}
+	}
+	return reply
+}
+
+// parseScoreBound 解析分数区间端点：-inf、+inf、(1.5（开区间）
+func parseScoreBound(v string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(v, "(")
+	if exclusive {
+		v = v[1:]
+	}
+	f, err := parseFloat(v)
+	return f, exclusive, err
+}
+
+// ---------------------- 发布 ----------------------
+
+func cmdPublish(s *Server, args []string) interface{} {
+	return s.publish(args[1], args[2])
+}
+
+// ---------------------- 工具函数 ----------------------
+
+func parseFloat(v string) (float64, error) {
+	switch strings.ToLower(v) {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func isErr(reply interface{}) bool {
+	_, ok := reply.(errReply)
+	return ok
+}
+
+// orZero 有错误返回错误，否则返回整数0
+func orZero(errRep interface{}) interface{} {
+	if errRep != nil {
+		return errRep
+	}
+	return int64(0)
+}
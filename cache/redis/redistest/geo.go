@@ -0,0 +1,294 @@
+package redistest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+GEO 命令。与 Redis 一样把坐标编码为 52 位 geohash 作为有序集合的分数，GEOPOS 返回的是 geohash 格子的中心，
+与写入的坐标有不到 1 米的误差；距离用 Redis 相同的地球半径按半球公式计算。
+*/
+
+// Redis GEO 的坐标范围和精度
+const (
+	geoLongitudeMin = -180.0
+	geoLongitudeMax = 180.0
+	geoLatitudeMin  = -85.05112878
+	geoLatitudeMax  = 85.05112878
+	geoStep         = 26 //经度、纬度各 26 位
+	earthRadius     = 6372797.560856
+)
+
+// geoUnits 距离单位对应的米数
+var geoUnits = map[string]float64{"m": 1, "km": 1000, "ft": 0.3048, "mi": 1609.34}
+
+// geoEncode 坐标编码为 geohash：纬度占偶数位，经度占奇数位
+func geoEncode(longitude, latitude float64) uint64 {
+	lat := uint64((latitude - geoLatitudeMin) / (geoLatitudeMax - geoLatitudeMin) * (1 << geoStep))
+	lon := uint64((longitude - geoLongitudeMin) / (geoLongitudeMax - geoLongitudeMin) * (1 << geoStep))
+	lat, lon = min(lat, 1<<geoStep-1), min(lon, 1<<geoStep-1)
+	var hash uint64
+	for i := 0; i < geoStep; i++ {
+		hash |= (lat>>i&1)<<(2*i) | (lon>>i&1)<<(2*i+1)
+	}
+	return hash
+}
+
+// geoDecode geohash 解码为格子中心的坐标
+func geoDecode(hash uint64) (longitude, latitude float64) {
+	var lat, lon uint64
+	for i := 0; i < geoStep; i++ {
+		lat |= (hash >> (2 * i) & 1) << i
+		lon |= (hash >> (2*i + 1) & 1) << i
+	}
+	latUnit := (geoLatitudeMax - geoLatitudeMin) / (1 << geoStep)
+	lonUnit := (geoLongitudeMax - geoLongitudeMin) / (1 << geoStep)
+	latitude = geoLatitudeMin + (float64(lat)+0.5)*latUnit
+	longitude = geoLongitudeMin + (float64(lon)+0.5)*lonUnit
+	return max(min(longitude, geoLongitudeMax), geoLongitudeMin), max(min(latitude, geoLatitudeMax), geoLatitudeMin)
+}
+
+// geoDistance 两点间的距离（米）
+func geoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := lat1*math.Pi/180, lat2*math.Pi/180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2 - lon1) * math.Pi / 180 / 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// geoMember 有序集合中成员的坐标，成员不存在时 ok 为 false
+func geoMember(z zsetValue, member string) (longitude, latitude float64, ok bool) {
+	score, ok := z[member]
+	if !ok {
+		return 0, 0, false
+	}
+	longitude, latitude = geoDecode(uint64(score))
+	return longitude, latitude, true
+}
+
+func parseGeoUnit(v string) (float64, interface{}) {
+	unit, ok := geoUnits[strings.ToLower(v)]
+	if !ok {
+		return 0, errReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+	}
+	return unit, nil
+}
+
+// parseLonLat 解析并校验经纬度
+func parseLonLat(lonArg, latArg string) (float64, float64, interface{}) {
+	lon, err1 := strconv.ParseFloat(lonArg, 64)
+	lat, err2 := strconv.ParseFloat(latArg, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, errNotFloat
+	}
+	if lon < geoLongitudeMin || lon > geoLongitudeMax || lat < geoLatitudeMin || lat > geoLatitudeMax {
+		return 0, 0, errReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+	}
+	return lon, lat, nil
+}
+
+// cmdGeoAdd GEOADD key [NX|XX] [CH] longitude latitude member [...]，坐标编码后交给 ZADD
+func cmdGeoAdd(s *Server, args []string) interface{} {
+	zadd := []string{"zadd", args[1]}
+	i := 2
+	for ; i < len(args); i++ {
+		flag := strings.ToLower(args[i])
+		if flag != "nx" && flag != "xx" && flag != "ch" {
+			break
+		}
+		zadd = append(zadd, flag)
+	}
+	items := args[i:]
+	if len(items) == 0 || len(items)%3 != 0 {
+		return errSyntax
+	}
+	for j := 0; j < len(items); j += 3 {
+		lon, lat, errRep := parseLonLat(items[j], items[j+1])
+		if errRep != nil {
+			return errRep
+		}
+		zadd = append(zadd, strconv.FormatUint(geoEncode(lon, lat), 10), items[j+2])
+	}
+	return cmdZAdd(s, zadd)
+}
+
+// cmdGeoPos GEOPOS key member [...]，不存在的成员返回空数组
+func cmdGeoPos(s *Server, args []string) interface{} {
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	reply := make([]interface{}, 0, len(args)-2)
+	for _, member := range args[2:] {
+		lon, lat, ok := geoMember(z, member)
+		if !ok {
+			reply = append(reply, nilArray{})
+			continue
+		}
+		reply = append(reply, []string{formatFloat(lon), formatFloat(lat)})
+	}
+	return reply
+}
+
+// cmdGeoDist GEODIST key member1 member2 [unit]
+func cmdGeoDist(s *Server, args []string) interface{} {
+	if len(args) > 5 {
+		return errSyntax
+	}
+	unit := 1.0
+	if len(args) == 5 {
+		var errRep interface{}
+		if unit, errRep = parseGeoUnit(args[4]); errRep != nil {
+			return errRep
+		}
+	}
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	lon1, lat1, ok1 := geoMember(z, args[2])
+	lon2, lat2, ok2 := geoMember(z, args[3])
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return strconv.FormatFloat(geoDistance(lon1, lat1, lon2, lat2)/unit, 'f', 4, 64)
+}
+
+/*
+cmdGeoSearch GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude
+BYRADIUS radius unit|BYBOX width height unit [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+*/
+func cmdGeoSearch(s *Server, args []string) interface{} {
+	z, _, errRep := lookupAs[zsetValue](s, args[1])
+	if errRep != nil {
+		return errRep
+	}
+	var (
+		centerLon, centerLat          float64
+		hasCenter                     bool
+		radius, width, height, unit   float64
+		byRadius, byBox               bool
+		desc, sorted                  bool
+		count                         int
+		withCoord, withDist, withHash bool
+	)
+	for i := 2; i < len(args); i++ {
+		opt, rest := strings.ToLower(args[i]), len(args)-i-1
+		switch {
+		case opt == "frommember" && rest >= 1:
+			lon, lat, ok := geoMember(z, args[i+1])
+			if !ok {
+				return errReply("ERR could not decode requested zset member")
+			}
+			centerLon, centerLat, hasCenter = lon, lat, true
+			i++
+		case opt == "fromlonlat" && rest >= 2:
+			if centerLon, centerLat, errRep = parseLonLat(args[i+1], args[i+2]); errRep != nil {
+				return errRep
+			}
+			hasCenter = true
+			i += 2
+		case opt == "byradius" && rest >= 2:
+			r, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || r < 0 {
+				return errReply("ERR need numeric radius")
+			}
+			if unit, errRep = parseGeoUnit(args[i+2]); errRep != nil {
+				return errRep
+			}
+			radius, byRadius = r*unit, true
+			i += 2
+		case opt == "bybox" && rest >= 3:
+			w, err1 := strconv.ParseFloat(args[i+1], 64)
+			h, err2 := strconv.ParseFloat(args[i+2], 64)
+			if err1 != nil || err2 != nil || w < 0 || h < 0 {
+				return errReply("ERR need numeric width and height")
+			}
+			if unit, errRep = parseGeoUnit(args[i+3]); errRep != nil {
+				return errRep
+			}
+			width, height, byBox = w*unit, h*unit, true
+			i += 3
+		case opt == "asc" || opt == "desc":
+			desc, sorted = opt == "desc", true
+		case opt == "count" && rest >= 1:
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return errReply("ERR COUNT must be > 0")
+			}
+			count = n
+			i++
+			if i+1 < len(args) && strings.ToLower(args[i+1]) == "any" {
+				i++
+			}
+		case opt == "withcoord":
+			withCoord = true
+		case opt == "withdist":
+			withDist = true
+		case opt == "withhash":
+			withHash = true
+		default:
+			return errSyntax
+		}
+	}
+	if !hasCenter || byRadius == byBox {
+		return errReply("ERR exactly one of FROMMEMBER or FROMLONLAT and one of BYRADIUS or BYBOX can be specified for GEOSEARCH")
+	}
+
+	type found struct {
+		member   string
+		hash     uint64
+		lon, lat float64
+		dist     float64
+	}
+	var matched []found
+	for member, score := range z {
+		lon, lat := geoDecode(uint64(score))
+		dist := geoDistance(centerLon, centerLat, lon, lat)
+		if byRadius && dist > radius {
+			continue
+		}
+		// 矩形：纬度方向的距离不超过高度的一半，同一纬度上经度方向的距离不超过宽度的一半
+		if byBox && (earthRadius*math.Abs(lat-centerLat)*math.Pi/180 > height/2 ||
+			geoDistance(lon, lat, centerLon, lat) > width/2) {
+			continue
+		}
+		matched = append(matched, found{member, uint64(score), lon, lat, dist})
+	}
+	// 没有指定排序但指定了 COUNT 时 Redis 也按距离升序取前 count 个
+	if sorted || count > 0 {
+		sort.Slice(matched, func(i, j int) bool {
+			if desc {
+				return matched[i].dist > matched[j].dist
+			}
+			return matched[i].dist < matched[j].dist
+		})
+	}
+	if count > 0 && len(matched) > count {
+		matched = matched[:count]
+	}
+
+	reply := make([]interface{}, 0, len(matched))
+	for _, m := range matched {
+		if !withCoord && !withDist && !withHash {
+			reply = append(reply, m.member)
+			continue
+		}
+		item := []interface{}{m.member}
+		if withDist {
+			item = append(item, strconv.FormatFloat(m.dist/unit, 'f', 4, 64))
+		}
+		if withHash {
+			item = append(item, int64(m.hash))
+		}
+		if withCoord {
+			item = append(item, []string{formatFloat(m.lon), formatFloat(m.lat)})
+		}
+		reply = append(reply, item)
+	}
+	return reply
+}
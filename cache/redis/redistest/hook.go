@@ -0,0 +1,48 @@
+package redistest
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"redis/instrument"
+	"sync"
+)
+
+// TraceHook 实现 redis.Hook，按执行顺序记录每条命令及其上下文中的链路ID（格式为 "命令:链路ID"），
+// 用于断言调用方的 ctx 确实传到了 go-redis：config.RedisClient.AddHook(hook)
+type TraceHook struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+// Commands 已记录的命令
+func (h *TraceHook) Commands() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.commands...)
+}
+
+func (h *TraceHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *TraceHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(ctx, cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *TraceHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(ctx, cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func (h *TraceHook) record(ctx context.Context, cmd redis.Cmder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commands = append(h.commands, cmd.Name()+":"+instrument.TraceIDFromContext(ctx))
+}
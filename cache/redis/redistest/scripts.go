@@ -0,0 +1,133 @@
+package redistest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Call 在脚本中执行一条 Redis 命令，相当于 Lua 中的 redis.call
+// 返回值：int64 整数、string 字符串、nil（键不存在）、[]string/[]interface{} 数组；命令出错时返回 error
+type Call func(args ...string) (interface{}, error)
+
+/*
+ScriptFunc Lua 脚本的 Go 实现。假 Redis 不内置 Lua 解释器，测试需要用 RegisterScript 为被测代码用到的脚本注册等价实现。
+keys、argv 对应 Lua 中的 KEYS、ARGV；返回值规则与 Call 相同，返回 error 时客户端收到错误回复。
+脚本在服务端锁内执行，期间不会穿插其他客户端的命令，与真实 Redis 一样是原子的。
+*/
+type ScriptFunc func(call Call, keys, argv []string) (interface{}, error)
+
+type script struct {
+	src string
+	fn  ScriptFunc
+}
+
+// RegisterScript 注册脚本源码 src 的 Go 实现，之后 EVAL src 和 EVALSHA sha1(src) 都会执行 fn
+// src 需与被测代码中的脚本源码完全一致（go-redis 按源码计算 SHA1）
+func (s *Server) RegisterScript(src string, fn ScriptFunc) string {
+	sha := scriptSHA(src)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[sha] = &script{src: src, fn: fn}
+	return sha
+}
+
+func scriptSHA(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+func cmdEval(s *Server, args []string) interface{} {
+	sc, ok := s.scripts[scriptSHA(args[1])]
+	if !ok {
+		return errReply("ERR fake redis: script is not registered, call Server.RegisterScript first")
+	}
+	return s.runScript(sc, args[2:])
+}
+
+func cmdEvalSha(s *Server, args []string) interface{} {
+	sc, ok := s.scripts[strings.ToLower(args[1])]
+	if !ok {
+		return errReply("NOSCRIPT No matching script. Please use EVAL.")
+	}
+	return s.runScript(sc, args[2:])
+}
+
+// cmdScript SCRIPT LOAD/EXISTS/FLUSH，只有注册过的脚本才能加载
+func cmdScript(s *Server, args []string) interface{} {
+	switch strings.ToLower(args[1]) {
+	case "load":
+		if len(args) != 3 {
+			return errArity("script|load")
+		}
+		sha := scriptSHA(args[2])
+		if _, ok := s.scripts[sha]; !ok {
+			return errReply("ERR fake redis: script is not registered, call Server.RegisterScript first")
+		}
+		return sha
+	case "exists":
+		result := make([]interface{}, 0, len(args)-2)
+		for _, sha := range args[2:] {
+			_, ok := s.scripts[strings.ToLower(sha)]
+			result = append(result, boolToInt(ok))
+		}
+		return result
+	case "flush":
+		// 注册的脚本相当于脚本缓存的来源，FLUSH 不清除
+		return statusOK
+	}
+	return errSyntax
+}
+
+// runScript 解析 numkeys 后执行脚本，args 为 numkeys key... arg...
+func (s *Server) runScript(sc *script, args []string) interface{} {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 0 {
+		return errReply("ERR value is not an integer or out of range")
+	}
+	if numKeys > len(args)-1 {
+		return errReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys, argv := args[1:1+numKeys], args[1+numKeys:]
+
+	call := func(cmdArgs ...string) (interface{}, error) {
+		if len(cmdArgs) == 0 {
+			return nil, fmt.Errorf("ERR Please specify at least one argument for this redis lib call")
+		}
+		reply := s.call(cmdArgs)
+		if e, ok := reply.(errReply); ok {
+			return nil, fmt.Errorf("%s", string(e))
+		}
+		if _, ok := reply.(nilArray); ok {
+			return nil, nil
+		}
+		if st, ok := reply.(status); ok {
+			return string(st), nil
+		}
+		return reply, nil
+	}
+
+	result, err := sc.fn(call, keys, argv)
+	if err != nil {
+		return errReply(withErrorCode(err.Error()))
+	}
+	return result
+}
+
+// withErrorCode 错误信息没有 ERR、WRONGTYPE 这类大写错误码前缀时补上 ERR
+func withErrorCode(msg string) string {
+	code, _, _ := strings.Cut(msg, " ")
+	if code != "" && strings.ToUpper(code) == code && strings.ToLower(code) != code {
+		return msg
+	}
+	return "ERR " + msg
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,591 @@
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net"
+	"redis/config"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Server 进程内的假 Redis 服务，说 RESP2 协议，go-redis 客户端可以像连接真实 Redis 一样连接它。
+用于在没有 localhost:6379 的情况下测试 redisutil、distributed 等包，类似标准库的 httptest.Server。
+
+支持：字符串、哈希、列表、集合、有序集合、GEO、过期时间（由可控时钟 Clock 驱动）、
+MULTI/EXEC/WATCH 事务、管道、发布订阅，以及通过 RegisterScript 注册了 Go 实现的 Lua 脚本（EVAL/EVALSHA）。
+*/
+type Server struct {
+	ln    net.Listener
+	clock *Clock
+
+	mu       sync.Mutex
+	data     map[string]*entry
+	versions map[string]uint64 //键的修改版本号，WATCH 用它判断键是否被改过
+	scripts  map[string]*script
+	channels map[string]map[*conn]struct{}
+	conns    map[*conn]struct{}
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// entry 一个键的值和过期时间
+// value 的类型：string、hashValue、*listValue、setValue、zsetValue
+type entry struct {
+	value    interface{}
+	expireAt time.Time //零值表示永不过期
+}
+
+// NewServer 在 127.0.0.1 的随机端口启动假 Redis，时钟从当前时间开始并保持静止
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:       ln,
+		clock:    NewClock(time.Now()),
+		data:     make(map[string]*entry),
+		versions: make(map[string]uint64),
+		scripts:  make(map[string]*script),
+		channels: make(map[string]map[*conn]struct{}),
+		conns:    make(map[*conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// Setup 启动假 Redis 并把 config.RedisClient 指向它，测试结束时自动恢复并关闭
+// 注意：会替换全局客户端，使用它的测试不要调用 t.Parallel
+func Setup(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("start fake redis: %v", err)
+	}
+	client := s.NewClient()
+	old := config.RedisClient
+	config.RedisClient = client
+	t.Cleanup(func() {
+		config.RedisClient = old
+		_ = client.Close()
+		s.Close()
+	})
+	return s
+}
+
+// Addr 监听地址
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// NewClient 构造连接到该服务的 go-redis 客户端
+func (s *Server) NewClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:            s.Addr(),
+		Protocol:        2,
+		DisableIdentity: true,
+	})
+}
+
+// Clock 服务使用的时钟
+func (s *Server) Clock() *Clock {
+	return s.clock
+}
+
+// FastForward 时钟前进 d，到期的键随之失效
+func (s *Server) FastForward(d time.Duration) {
+	s.clock.Advance(d)
+}
+
+// Close 关闭监听和所有连接
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	_ = s.ln.Close()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// ---------------------- 测试断言辅助 ----------------------
+
+// Exists 键是否存在（已过期视为不存在）
+func (s *Server) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(key) != nil
+}
+
+// Get 读取字符串键，键不存在或不是字符串时 ok 为 false
+func (s *Server) Get(key string) (value string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok, _ = lookupAs[string](s, key)
+	return value, ok
+}
+
+// TTL 键的剩余过期时间，键不存在返回 -2，永不过期返回 -1（与 go-redis 的 TTL 结果一致）
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.lookup(key)
+	switch {
+	case e == nil:
+		return -2
+	case e.expireAt.IsZero():
+		return -1
+	default:
+		return e.expireAt.Sub(s.clock.Now())
+	}
+}
+
+// Keys 所有未过期的键，按字典序排序
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		if s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FlushAll 清空所有数据
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+}
+
+// ---------------------- 数据访问（调用方需持有 s.mu） ----------------------
+
+// lookup 查找键，已过期的键会被删除并返回 nil
+func (s *Server) lookup(key string) *entry {
+	e, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !s.clock.Now().Before(e.expireAt) {
+		s.remove(key)
+		return nil
+	}
+	return e
+}
+
+// lookupAs 查找指定类型的键，键不存在时 ok 为 false，类型不符时返回 WRONGTYPE 错误
+func lookupAs[T any](s *Server, key string) (value T, ok bool, errRep interface{}) {
+	e := s.lookup(key)
+	if e == nil {
+		return value, false, nil
+	}
+	value, ok = e.value.(T)
+	if !ok {
+		return value, false, errWrongType
+	}
+	return value, true, nil
+}
+
+// put 写入键值，清除原有过期时间
+func (s *Server) put(key string, value interface{}) {
+	s.data[key] = &entry{value: value}
+	s.touch(key)
+}
+
+// remove 删除键，返回键是否存在
+func (s *Server) remove(key string) bool {
+	if _, ok := s.data[key]; !ok {
+		return false
+	}
+	delete(s.data, key)
+	s.touch(key)
+	return true
+}
+
+// touch 标记键被修改
+func (s *Server) touch(key string) {
+	s.versions[key]++
+}
+
+func (s *Server) flush() {
+	for k := range s.data {
+		s.touch(k)
+	}
+	s.data = make(map[string]*entry)
+}
+
+// ---------------------- 连接处理 ----------------------
+
+// conn 一个客户端连接及其事务、订阅状态
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+
+	wmu sync.Mutex //发布消息会从其他连接的goroutine写入
+	w   *bufio.Writer
+
+	inMulti  bool
+	multiErr bool
+	queue    [][]string
+	watched  map[string]uint64
+	subs     map[string]struct{}
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = nc.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		s.unsubscribeAll(c)
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		reply := s.dispatch(c, args)
+		if err := c.reply(reply); err != nil {
+			return
+		}
+		if strings.EqualFold(args[0], "quit") {
+			return
+		}
+	}
+}
+
+// dispatch 处理连接级命令（事务、订阅），其余命令在持有 s.mu 时执行
+func (s *Server) dispatch(c *conn, args []string) interface{} {
+	name := strings.ToLower(args[0])
+	switch name {
+	case "multi":
+		if c.inMulti {
+			return errReply("ERR MULTI calls can not be nested")
+		}
+		c.inMulti, c.multiErr, c.queue = true, false, nil
+		return statusOK
+	case "exec":
+		if !c.inMulti {
+			return errReply("ERR EXEC without MULTI")
+		}
+		return s.exec(c)
+	case "discard":
+		if !c.inMulti {
+			return errReply("ERR DISCARD without MULTI")
+		}
+		c.inMulti, c.multiErr, c.queue, c.watched = false, false, nil, nil
+		return statusOK
+	case "watch":
+		if c.inMulti {
+			return errReply("ERR WATCH inside MULTI is not allowed")
+		}
+		if len(args) < 2 {
+			return errArity(name)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if c.watched == nil {
+			c.watched = make(map[string]uint64)
+		}
+		for _, key := range args[1:] {
+			s.lookup(key)
+			c.watched[key] = s.versions[key]
+		}
+		return statusOK
+	case "unwatch":
+		c.watched = nil
+		return statusOK
+	case "subscribe":
+		if len(args) < 2 {
+			return errArity(name)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.subscribe(c, args[1:])
+	case "unsubscribe":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.unsubscribe(c, args[1:])
+	case "quit":
+		return statusOK
+	}
+
+	if c.inMulti {
+		if _, errRep := lookupCommand(args); errRep != nil {
+			c.multiErr = true
+			return errRep
+		}
+		c.queue = append(c.queue, args)
+		return status("QUEUED")
+	}
+	if len(c.subs) > 0 {
+		if name == "ping" {
+			return []interface{}{"pong", ""}
+		}
+		return errReply(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", name))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.call(args)
+}
+
+// exec 原子执行事务队列，WATCH 的键被修改过时返回空数组（go-redis 表现为 redis.TxFailedErr）
+func (s *Server) exec(c *conn) interface{} {
+	queue, watched, failed := c.queue, c.watched, c.multiErr
+	c.inMulti, c.multiErr, c.queue, c.watched = false, false, nil, nil
+	if failed {
+		return errReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, version := range watched {
+		s.lookup(key)
+		if s.versions[key] != version {
+			return nilArray{}
+		}
+	}
+	replies := make([]interface{}, len(queue))
+	for i, args := range queue {
+		replies[i] = s.call(args)
+	}
+	return replies
+}
+
+// call 执行数据命令，调用方需持有 s.mu
+func (s *Server) call(args []string) interface{} {
+	cmd, errRep := lookupCommand(args)
+	if errRep != nil {
+		return errRep
+	}
+	return cmd.fn(s, args)
+}
+
+// ---------------------- 发布订阅 ----------------------
+
+func (s *Server) subscribe(c *conn, channels []string) interface{} {
+	if c.subs == nil {
+		c.subs = make(map[string]struct{})
+	}
+	replies := make(multiReply, 0, len(channels))
+	for _, ch := range channels {
+		c.subs[ch] = struct{}{}
+		if s.channels[ch] == nil {
+			s.channels[ch] = make(map[*conn]struct{})
+		}
+		s.channels[ch][c] = struct{}{}
+		replies = append(replies, []interface{}{"subscribe", ch, int64(len(c.subs))})
+	}
+	return replies
+}
+
+func (s *Server) unsubscribe(c *conn, channels []string) interface{} {
+	if len(channels) == 0 {
+		for ch := range c.subs {
+			channels = append(channels, ch)
+		}
+		sort.Strings(channels)
+	}
+	if len(channels) == 0 {
+		return []interface{}{"unsubscribe", nil, int64(0)}
+	}
+	replies := make(multiReply, 0, len(channels))
+	for _, ch := range channels {
+		delete(c.subs, ch)
+		delete(s.channels[ch], c)
+		if len(s.channels[ch]) == 0 {
+			delete(s.channels, ch)
+		}
+		replies = append(replies, []interface{}{"unsubscribe", ch, int64(len(c.subs))})
+	}
+	return replies
+}
+
+func (s *Server) unsubscribeAll(c *conn) {
+	for ch := range c.subs {
+		delete(s.channels[ch], c)
+		if len(s.channels[ch]) == 0 {
+			delete(s.channels, ch)
+		}
+	}
+	c.subs = nil
+}
+
+// publish 把消息推送给频道的所有订阅者，返回接收者数量
+func (s *Server) publish(channel, message string) int64 {
+	var n int64
+	for c := range s.channels[channel] {
+		if err := c.reply([]interface{}{"message", channel, message}); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// ---------------------- RESP 协议 ----------------------
+
+// 回复类型：int64 整数、string 批量字符串、nil 空批量字符串、[]interface{} 数组、float64 以字符串返回
+type (
+	status     string        //简单字符串 +OK
+	errReply   string        //错误 -ERR ...
+	nilArray   struct{}      //空数组 *-1，EXEC 被 WATCH 打断时返回
+	multiReply []interface{} //连续多个回复，SUBSCRIBE 多个频道时使用
+)
+
+const statusOK = status("OK")
+
+var errWrongType = errReply("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+func (c *conn) reply(v interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if m, ok := v.(multiReply); ok {
+		for _, r := range m {
+			writeReply(c.w, r)
+		}
+	} else {
+		writeReply(c.w, v)
+	}
+	return c.w.Flush()
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case errReply:
+		w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case bool:
+		if v {
+			w.WriteString(":1\r\n")
+		} else {
+			w.WriteString("$-1\r\n")
+		}
+	case float64:
+		writeBulk(w, formatFloat(v))
+	case string:
+		writeBulk(w, v)
+	case nilArray:
+		w.WriteString("*-1\r\n")
+	case []string:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, s := range v {
+			writeBulk(w, s)
+		}
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		w.WriteString("-ERR fake redis: unsupported reply type " + fmt.Sprintf("%T", v) + "\r\n")
+	}
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// readCommand 读取一条命令：RESP 数组或内联命令（telnet 风格）
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multibulk length %q", line)
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if header == "" || header[0] != '$' {
+			return nil, fmt.Errorf("expected bulk string, got %q", header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", header)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && line != "" {
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,221 @@
+package redisutil
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"redis/breaker"
+	"sync"
+	"time"
+)
+
+// Fallback Redis 不可用（熔断打开、网络错误、超时）时工具函数的降级策略
+type Fallback int
+
+const (
+	FailFast   Fallback = iota //直接返回错误（默认）
+	SkipCache                  //跳过缓存：读当作未命中返回 redis.Nil（批量读返回全 nil），写忽略并返回 nil
+	ServeStale                 //读返回本地保存的最近一次成功读取的值，没有时同 SkipCache；写同 SkipCache
+)
+
+func (f Fallback) String() string {
+	switch f {
+	case FailFast:
+		return "fail-fast"
+	case SkipCache:
+		return "skip-cache"
+	case ServeStale:
+		return "serve-stale"
+	}
+	return "unknown"
+}
+
+// 支持降级的工具函数，值表示是否支持 ServeStale
+// Incr、Eval、事务、管道、GEO 等结果无法伪造的函数始终 FailFast
+var fallbackHelpers = map[string]bool{
+	"Get": true, "GetByte": true, "MGet": true, "HGet": true, "HMGet": true,
+	"LRange": false, "SMembers": false, "ZRangeByScore": false,
+	"Set": false, "MSet": false, "HSet": false, "HMSet": false, "LPush": false,
+	"SAdd": false, "ZAdd": false, "ZRem": false, "Del": false, "Expire": false, "Publish": false,
+}
+
+// StaleMaxAge 本地旧值的最长保存时间，超过后不再用于降级，需在使用 ServeStale 前修改
+var StaleMaxAge = 10 * time.Minute
+
+// 本地旧值最多保存的条目数，超过后不再新增
+const staleMaxEntries = 10000
+
+var (
+	fallbackMu sync.RWMutex
+	fallbacks  = make(map[string]Fallback)
+)
+
+// SetFallback 设置工具函数（按函数名，如 "Get"、"HGet"、"Set"）的降级策略
+func SetFallback(helper string, f Fallback) error {
+	stale, ok := fallbackHelpers[helper]
+	if !ok {
+		return fmt.Errorf("redisutil: %s does not support fallback", helper)
+	}
+	if f == ServeStale && !stale {
+		return fmt.Errorf("redisutil: %s does not support %s", helper, f)
+	}
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbacks[helper] = f
+	return nil
+}
+
+func fallbackOf(helper string) Fallback {
+	fallbackMu.RLock()
+	defer fallbackMu.RUnlock()
+	return fallbacks[helper]
+}
+
+// unavailable Redis 不可用类错误才触发降级，redis.Nil、WRONGTYPE 等正常返回原错误
+func unavailable(err error) bool {
+	return errors.Is(err, breaker.ErrOpen) || breaker.IsFailure(err)
+}
+
+// readString 执行单值读取并按策略降级，key/field 标识本地旧值
+func readString(helper, key, field string, fn func() (string, error)) (string, error) {
+	v, err := fn()
+	policy := fallbackOf(helper)
+	switch {
+	case err == nil:
+		if policy == ServeStale {
+			stale.put(key, field, v)
+		}
+		return v, nil
+	case !unavailable(err):
+		if errors.Is(err, redis.Nil) {
+			stale.remove(key, field)
+		}
+		return v, err
+	case policy == SkipCache:
+		return "", redis.Nil
+	case policy == ServeStale:
+		if old, ok := stale.get(key, field); ok {
+			return old, nil
+		}
+		return "", redis.Nil
+	}
+	return v, err
+}
+
+// readMulti 执行批量读取（MGet/HMGet）并按策略降级，keyAt 返回第 i 个结果对应的本地旧值标识
+func readMulti(helper string, n int, keyAt func(i int) (string, string), fn func() ([]interface{}, error)) ([]interface{}, error) {
+	values, err := fn()
+	policy := fallbackOf(helper)
+	switch {
+	case err == nil:
+		if policy == ServeStale {
+			for i, v := range values {
+				key, field := keyAt(i)
+				if s, ok := v.(string); ok {
+					stale.put(key, field, s)
+				} else {
+					stale.remove(key, field)
+				}
+			}
+		}
+		return values, nil
+	case !unavailable(err) || policy == FailFast:
+		return values, err
+	}
+	values = make([]interface{}, n)
+	if policy == ServeStale {
+		for i := range values {
+			if old, ok := stale.get(keyAt(i)); ok {
+				values[i] = old
+			}
+		}
+	}
+	return values, nil
+}
+
+// readList 执行列表类读取，SkipCache 时返回空结果
+func readList(helper string, fn func() ([]string, error)) ([]string, error) {
+	values, err := fn()
+	if err != nil && unavailable(err) && fallbackOf(helper) == SkipCache {
+		return []string{}, nil
+	}
+	return values, err
+}
+
+// write 按策略处理写操作的错误，key 非空时使写入的键对应的本地旧值失效
+func write(helper string, err error, keys ...string) error {
+	for _, key := range keys {
+		stale.removeKey(key)
+	}
+	if err != nil && unavailable(err) && fallbackOf(helper) != FailFast {
+		return nil
+	}
+	return err
+}
+
+// ---------------------- 本地旧值 ----------------------
+
+type staleEntry struct {
+	value   string
+	savedAt time.Time
+}
+
+// staleStore 最近一次成功读取的值，key -> field -> 值（字符串键的 field 为空字符串）
+type staleStore struct {
+	mu      sync.Mutex
+	entries map[string]map[string]staleEntry
+	size    int
+}
+
+var stale = &staleStore{entries: make(map[string]map[string]staleEntry)}
+
+func (s *staleStore) put(key, field, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields := s.entries[key]
+	if _, exists := fields[field]; !exists {
+		if s.size >= staleMaxEntries {
+			return
+		}
+		s.size++
+	}
+	if fields == nil {
+		fields = make(map[string]staleEntry)
+		s.entries[key] = fields
+	}
+	fields[field] = staleEntry{value: value, savedAt: time.Now()}
+}
+
+func (s *staleStore) get(key, field string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key][field]
+	if !ok || time.Since(e.savedAt) > StaleMaxAge {
+		return "", false
+	}
+	return e.value, true
+}
+
+func (s *staleStore) remove(key, field string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if _, exists := fields[field]; exists {
+		delete(fields, field)
+		s.size--
+	}
+	if len(fields) == 0 {
+		delete(s.entries, key)
+	}
+}
+
+// removeKey 删除键的所有本地旧值（包括哈希的所有字段）
+func (s *staleStore) removeKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size -= len(s.entries[key])
+	delete(s.entries, key)
+}
@@ -0,0 +1,61 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"redis/redistest"
+	"testing"
+)
+
+// setFallback 设置降级策略，测试结束时恢复默认
+func setFallback(t *testing.T, helper string, f Fallback) {
+	t.Helper()
+	if err := SetFallback(helper, f); err != nil {
+		t.Fatalf("SetFallback(%s, %s): %v", helper, f, err)
+	}
+	t.Cleanup(func() { _ = SetFallback(helper, FailFast) })
+}
+
+func TestFallbackWhenRedisDown(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+	setFallback(t, "Get", ServeStale)
+	setFallback(t, "HGet", SkipCache)
+	setFallback(t, "Set", SkipCache)
+
+	_ = Set(ctx, "fb:name", "Meta", 0)
+	if v, err := Get(ctx, "fb:name"); err != nil || v != "Meta" {
+		t.Fatalf("Get = %q, %v; want Meta", v, err)
+	}
+	srv.Close()
+
+	if v, err := Get(ctx, "fb:name"); err != nil || v != "Meta" {
+		t.Errorf("Get with ServeStale = %q, %v; want stale Meta", v, err)
+	}
+	if _, err := Get(ctx, "fb:never-read"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get without stale value err = %v, want redis.Nil", err)
+	}
+	if _, err := HGet(ctx, "fb:hash", "f"); !errors.Is(err, redis.Nil) {
+		t.Errorf("HGet with SkipCache err = %v, want redis.Nil", err)
+	}
+	if err := Set(ctx, "fb:name", "new", 0); err != nil {
+		t.Errorf("Set with SkipCache = %v, want nil", err)
+	}
+	// 写入被跳过后本地旧值失效，避免返回与数据源不一致的值
+	if _, err := Get(ctx, "fb:name"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get after skipped Set err = %v, want redis.Nil", err)
+	}
+	if _, err := MGet(ctx, "fb:name"); err == nil {
+		t.Error("MGet with FailFast should return the connection error")
+	}
+}
+
+func TestSetFallbackValidation(t *testing.T) {
+	if err := SetFallback("Incr", SkipCache); err == nil {
+		t.Error("Incr must not support fallback")
+	}
+	if err := SetFallback("Set", ServeStale); err == nil {
+		t.Error("Set must not support ServeStale")
+	}
+}
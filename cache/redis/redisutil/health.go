@@ -0,0 +1,49 @@
+package redisutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"redis/breaker"
+	"redis/config"
+	"time"
+)
+
+// HealthStatus Redis 健康状态
+type HealthStatus struct {
+	Healthy     bool      `json:"healthy"`
+	State       string    `json:"state"` //熔断器状态：closed、open、half-open
+	Requests    int       `json:"requests"`
+	Failures    int       `json:"failures"`
+	FailureRate float64   `json:"failureRate"`
+	OpenedAt    time.Time `json:"openedAt,omitzero"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Health 根据熔断器状态报告 Redis 健康状况，熔断打开时为不健康
+func Health() HealthStatus {
+	if config.RedisBreaker == nil {
+		return HealthStatus{State: "uninitialized"}
+	}
+	stats := config.RedisBreaker.Stats()
+	return HealthStatus{
+		Healthy:     stats.State != breaker.Open,
+		State:       stats.State.String(),
+		Requests:    stats.Requests,
+		Failures:    stats.Failures,
+		FailureRate: stats.FailureRate,
+		OpenedAt:    stats.OpenedAt,
+		LastError:   stats.LastError,
+	}
+}
+
+// HealthHandler 以JSON输出 Health，不健康时状态码为 503，可挂到 /health/redis
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
@@ -8,141 +8,232 @@ import (
 )
 
 //redis工具类，缺少的函数在这添加，不要单独操作。
+//所有函数的 ctx 原样传给 go-redis：超时、取消以及 instrument.WithTraceID 放入的链路ID都随命令生效。
+//Redis 不可用时的降级策略见 SetFallback，默认直接返回错误。
 
 // ---------------------- 字符串（String）操作 ----------------------
 
 // Set 设置字符串键值，过期时间支持0表示永不过期（原子操作）
-func Set(key string, value interface{}, expiration time.Duration) error {
-	return config.RedisClient.Set(context.Background(), key, value, expiration).Err()
+func Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return write("Set", config.RedisClient.Set(ctx, key, value, expiration).Err(), key)
 }
 
 // Get 获取字符串键值（原子操作）
-func Get(key string) (string, error) {
-	return config.RedisClient.Get(context.Background(), key).Result()
+func Get(ctx context.Context, key string) (string, error) {
+	return readString("Get", key, "", func() (string, error) {
+		return config.RedisClient.Get(ctx, key).Result()
+	})
 }
 
-func GetByte(key string) ([]byte, error) {
-	return config.RedisClient.Get(context.Background(), key).Bytes()
+// GetByte 获取字符串键值的字节切片，常用于读取序列化后的JSON（原子操作）
+func GetByte(ctx context.Context, key string) ([]byte, error) {
+	v, err := readString("GetByte", key, "", func() (string, error) {
+		return config.RedisClient.Get(ctx, key).Result()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v), nil
 }
 
 // MSet 批量设置多个字符串键值（原子操作，单命令执行）
-func MSet(kv map[string]interface{}) error {
-	return config.RedisClient.MSet(context.Background(), kv).Err()
+func MSet(ctx context.Context, kv map[string]interface{}) error {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	return write("MSet", config.RedisClient.MSet(ctx, kv).Err(), keys...)
 }
 
 // MGet 批量获取多个字符串键值（原子操作，单命令执行）
-func MGet(keys ...string) ([]interface{}, error) {
-	return config.RedisClient.MGet(context.Background(), keys...).Result()
+func MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
+	return readMulti("MGet", len(keys), func(i int) (string, string) { return keys[i], "" }, func() ([]interface{}, error) {
+		return config.RedisClient.MGet(ctx, keys...).Result()
+	})
 }
 
 // Incr 原子递增计数器（原子操作）
-func Incr(key string) (int64, error) {
-	return config.RedisClient.Incr(context.Background(), key).Result()
+func Incr(ctx context.Context, key string) (int64, error) {
+	return config.RedisClient.Incr(ctx, key).Result()
 }
 
 // ---------------------- 哈希（Hash）操作 ----------------------
 
 // HSet 设置哈希表单个字段（原子操作）
-func HSet(key string, field string, value interface{}) error {
-	return config.RedisClient.HSet(context.Background(), key, field, value).Err()
+func HSet(ctx context.Context, key string, field string, value interface{}) error {
+	return write("HSet", config.RedisClient.HSet(ctx, key, field, value).Err(), key)
 }
 
 // HGet 获取哈希表单个字段（原子操作）
-func HGet(key string, field string) (string, error) {
-	return config.RedisClient.HGet(context.Background(), key, field).Result()
+func HGet(ctx context.Context, key string, field string) (string, error) {
+	return readString("HGet", key, field, func() (string, error) {
+		return config.RedisClient.HGet(ctx, key, field).Result()
+	})
 }
 
 // HMSet 批量设置哈希表多个字段（原子操作，单命令执行）
-func HMSet(key string, fields map[string]interface{}) error {
-	return config.RedisClient.HMSet(context.Background(), key, fields).Err()
+func HMSet(ctx context.Context, key string, fields map[string]interface{}) error {
+	return write("HMSet", config.RedisClient.HMSet(ctx, key, fields).Err(), key)
 }
 
 // HMGet 批量获取哈希表多个字段（原子操作，单命令执行）
-func HMGet(key string, fields ...string) ([]interface{}, error) {
-	return config.RedisClient.HMGet(context.Background(), key, fields...).Result()
+func HMGet(ctx context.Context, key string, fields ...string) ([]interface{}, error) {
+	return readMulti("HMGet", len(fields), func(i int) (string, string) { return key, fields[i] }, func() ([]interface{}, error) {
+		return config.RedisClient.HMGet(ctx, key, fields...).Result()
+	})
 }
 
 // ---------------------- 列表（List）操作 ----------------------
 
 // LPush 向列表左端插入一个或多个元素（原子操作，单命令执行）
-func LPush(key string, values ...interface{}) error {
-	return config.RedisClient.LPush(context.Background(), key, values...).Err()
+func LPush(ctx context.Context, key string, values ...interface{}) error {
+	return write("LPush", config.RedisClient.LPush(ctx, key, values...).Err())
 }
 
 // LRange 获取列表指定范围的元素（原子操作）
-func LRange(key string, start, stop int64) ([]string, error) {
-	return config.RedisClient.LRange(context.Background(), key, start, stop).Result()
+func LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return readList("LRange", func() ([]string, error) {
+		return config.RedisClient.LRange(ctx, key, start, stop).Result()
+	})
 }
 
 // ---------------------- 集合（Set）操作 ----------------------
 
 // SAdd 向集合添加一个或多个成员（原子操作，单命令执行）
-func SAdd(key string, members ...interface{}) error {
-	return config.RedisClient.SAdd(context.Background(), key, members...).Err()
+func SAdd(ctx context.Context, key string, members ...interface{}) error {
+	return write("SAdd", config.RedisClient.SAdd(ctx, key, members...).Err())
 }
 
 // SMembers 获取集合所有成员（原子操作）
-func SMembers(key string) ([]string, error) {
-	return config.RedisClient.SMembers(context.Background(), key).Result()
+func SMembers(ctx context.Context, key string) ([]string, error) {
+	return readList("SMembers", func() ([]string, error) {
+		return config.RedisClient.SMembers(ctx, key).Result()
+	})
 }
 
 // ---------------------- 有序集合（ZSet）操作 ----------------------
 
 // ZAdd 向有序集合添加一个或多个成员（原子操作，单命令执行）
-func ZAdd(key string, members ...redis.Z) error {
-	return config.RedisClient.ZAdd(context.Background(), key, members...).Err()
+func ZAdd(ctx context.Context, key string, members ...redis.Z) error {
+	return write("ZAdd", config.RedisClient.ZAdd(ctx, key, members...).Err())
 }
 
 // ZRangeByScore 按分数范围获取有序集合成员（原子操作）
-func ZRangeByScore(key string, min, max string) ([]string, error) {
-	return config.RedisClient.ZRangeByScore(context.Background(), key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+func ZRangeByScore(ctx context.Context, key string, min, max string) ([]string, error) {
+	return readList("ZRangeByScore", func() ([]string, error) {
+		return config.RedisClient.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+	})
+}
+
+// ZRem 从有序集合移除一个或多个成员（原子操作，单命令执行）
+func ZRem(ctx context.Context, key string, members ...interface{}) error {
+	return write("ZRem", config.RedisClient.ZRem(ctx, key, members...).Err())
+}
+
+// ---------------------- 地理位置（GEO）操作 ----------------------
+// GEO 底层是有序集合，成员移除使用 ZRem。unit 支持 m、km、ft、mi，传空字符串默认 km。
+
+// GeoAdd 添加一个或多个地理位置成员，成员已存在时覆盖坐标（原子操作，单命令执行）
+func GeoAdd(ctx context.Context, key string, locations ...*redis.GeoLocation) error {
+	return config.RedisClient.GeoAdd(ctx, key, locations...).Err()
+}
+
+// GeoSearchRadius 以经纬度为圆心按半径搜索成员，结果带距离和坐标并按距离由近到远排序，count 为0表示不限制数量（原子操作）
+func GeoSearchRadius(ctx context.Context, key string, longitude, latitude, radius float64, unit string, count int) ([]redis.GeoLocation, error) {
+	return geoSearchLocation(ctx, key, redis.GeoSearchQuery{
+		Longitude:  longitude,
+		Latitude:   latitude,
+		Radius:     radius,
+		RadiusUnit: geoUnit(unit),
+		Count:      count,
+	})
+}
+
+// GeoSearchBox 以经纬度为中心按矩形（宽、高）搜索成员，结果带距离和坐标并按距离由近到远排序，count 为0表示不限制数量（原子操作）
+func GeoSearchBox(ctx context.Context, key string, longitude, latitude, width, height float64, unit string, count int) ([]redis.GeoLocation, error) {
+	return geoSearchLocation(ctx, key, redis.GeoSearchQuery{
+		Longitude: longitude,
+		Latitude:  latitude,
+		BoxWidth:  width,
+		BoxHeight: height,
+		BoxUnit:   geoUnit(unit),
+		Count:     count,
+	})
+}
+
+// GeoDist 计算两个成员之间的距离，任一成员不存在时返回 redis.Nil（原子操作）
+func GeoDist(ctx context.Context, key, member1, member2, unit string) (float64, error) {
+	return config.RedisClient.GeoDist(ctx, key, member1, member2, geoUnit(unit)).Result()
+}
+
+// GeoPos 获取一个或多个成员的坐标，不存在的成员对应位置为 nil（原子操作）
+func GeoPos(ctx context.Context, key string, members ...string) ([]*redis.GeoPos, error) {
+	return config.RedisClient.GeoPos(ctx, key, members...).Result()
+}
+
+// geoSearchLocation 执行 GEOSEARCH 并固定返回距离和坐标，按距离升序
+func geoSearchLocation(ctx context.Context, key string, q redis.GeoSearchQuery) ([]redis.GeoLocation, error) {
+	q.Sort = "ASC"
+	return config.RedisClient.GeoSearchLocation(ctx, key, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: q,
+		WithCoord:      true,
+		WithDist:       true,
+	}).Result()
+}
+
+// geoUnit 距离单位为空时默认使用 km
+func geoUnit(unit string) string {
+	if unit == "" {
+		return "km"
+	}
+	return unit
 }
 
 // ---------------------- 事务（Transaction） ----------------------
 
 // TxPipelined 开启事务，返回的事务对象在调用Exec()时原子执行
 // 原子性说明：事务内所有命令在Exec()调用时原子执行
-func TxPipelined(fn func(redis.Pipeliner) error) error {
-	_, err := config.RedisClient.TxPipelined(context.Background(), fn)
+func TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) error {
+	_, err := config.RedisClient.TxPipelined(ctx, fn)
 	return err
 }
 
 // ---------------------- Lua脚本 ----------------------
 
 // Eval 执行Lua脚本（原子操作，脚本整体原子执行）
-func Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
-	return config.RedisClient.Eval(context.Background(), script, keys, args...).Result()
+func Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return config.RedisClient.Eval(ctx, script, keys, args...).Result()
 }
 
 // ---------------------- 管道（Pipeline） ----------------------
 
 // Pipelined 开启管道（非原子操作，用于批量命令发送）
 // 原子性说明：管道中的命令独立执行，不保证原子性
-func Pipelined(fn func(redis.Pipeliner) error) error {
-	_, err := config.RedisClient.Pipelined(context.Background(), fn)
+func Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) error {
+	_, err := config.RedisClient.Pipelined(ctx, fn)
 	return err
 }
 
 // ---------------------- 键管理 ----------------------
 
 // Del 删除一个或多个键（原子操作，单命令执行）
-func Del(keys ...string) error {
-	return config.RedisClient.Del(context.Background(), keys...).Err()
+func Del(ctx context.Context, keys ...string) error {
+	return write("Del", config.RedisClient.Del(ctx, keys...).Err(), keys...)
 }
 
 // Expire 设置键的过期时间（原子操作）
-func Expire(key string, expiration time.Duration) error {
-	return config.RedisClient.Expire(context.Background(), key, expiration).Err()
+func Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return write("Expire", config.RedisClient.Expire(ctx, key, expiration).Err())
 }
 
 // ---------------------- Pub/Sub ----------------------
 
 // Publish 向频道发布消息（非数据操作，无原子性要求）
-func Publish(channel string, message interface{}) error {
-	return config.RedisClient.Publish(context.Background(), channel, message).Err()
+func Publish(ctx context.Context, channel string, message interface{}) error {
+	return write("Publish", config.RedisClient.Publish(ctx, channel, message).Err())
 }
 
 // Subscribe 订阅一个或多个频道（非数据操作，无原子性要求）
-func Subscribe(channels ...string) *redis.PubSub {
-	return config.RedisClient.Subscribe(context.Background(), channels...)
+func Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return config.RedisClient.Subscribe(ctx, channels...)
 }
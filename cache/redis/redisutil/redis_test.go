@@ -0,0 +1,260 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"redis/config"
+	"redis/instrument"
+	"redis/redistest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+
+	if err := Set(ctx, "s:name", "Meta", 10*time.Second); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if v, err := Get(ctx, "s:name"); err != nil || v != "Meta" {
+		t.Errorf("Get = %q, %v; want Meta", v, err)
+	}
+	if b, err := GetByte(ctx, "s:name"); err != nil || string(b) != "Meta" {
+		t.Errorf("GetByte = %q, %v; want Meta", b, err)
+	}
+
+	srv.FastForward(11 * time.Second)
+	if _, err := Get(ctx, "s:name"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get after expiry err = %v, want redis.Nil", err)
+	}
+
+	if err := MSet(ctx, map[string]interface{}{"s:a": "1", "s:b": "2"}); err != nil {
+		t.Fatalf("MSet: %v", err)
+	}
+	values, err := MGet(ctx, "s:a", "s:missing", "s:b")
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if want := []interface{}{"1", nil, "2"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("MGet = %v, want %v", values, want)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if n, err := Incr(ctx, "s:counter"); err != nil || n != i {
+			t.Errorf("Incr = %d, %v; want %d", n, err, i)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	ctx := context.Background()
+	redistest.Setup(t)
+
+	if err := HSet(ctx, "h:user", "name", "Meta"); err != nil {
+		t.Fatalf("HSet: %v", err)
+	}
+	if err := HMSet(ctx, "h:user", map[string]interface{}{"age": 18, "city": "GZ"}); err != nil {
+		t.Fatalf("HMSet: %v", err)
+	}
+	if v, err := HGet(ctx, "h:user", "name"); err != nil || v != "Meta" {
+		t.Errorf("HGet = %q, %v; want Meta", v, err)
+	}
+	values, err := HMGet(ctx, "h:user", "age", "missing", "city")
+	if err != nil {
+		t.Fatalf("HMGet: %v", err)
+	}
+	if want := []interface{}{"18", nil, "GZ"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("HMGet = %v, want %v", values, want)
+	}
+}
+
+func TestListAndSet(t *testing.T) {
+	ctx := context.Background()
+	redistest.Setup(t)
+
+	if err := LPush(ctx, "l:queue", "a", "b", "c"); err != nil {
+		t.Fatalf("LPush: %v", err)
+	}
+	if items, err := LRange(ctx, "l:queue", 0, -1); err != nil || !reflect.DeepEqual(items, []string{"c", "b", "a"}) {
+		t.Errorf("LRange = %v, %v; want [c b a]", items, err)
+	}
+	if items, _ := LRange(ctx, "l:queue", 1, 1); !reflect.DeepEqual(items, []string{"b"}) {
+		t.Errorf("LRange(ctx, 1, 1) = %v, want [b]", items)
+	}
+
+	if err := SAdd(ctx, "set:tags", "go", "redis", "go"); err != nil {
+		t.Fatalf("SAdd: %v", err)
+	}
+	if members, err := SMembers(ctx, "set:tags"); err != nil || !reflect.DeepEqual(members, []string{"go", "redis"}) {
+		t.Errorf("SMembers = %v, %v; want [go redis]", members, err)
+	}
+
+	// 类型不符返回 WRONGTYPE
+	if _, err := Get(ctx, "l:queue"); err == nil {
+		t.Error("Get on a list should fail with WRONGTYPE")
+	}
+}
+
+func TestZSet(t *testing.T) {
+	ctx := context.Background()
+	redistest.Setup(t)
+
+	err := ZAdd(ctx, "z:rank", redis.Z{Score: 90, Member: "a"}, redis.Z{Score: 60, Member: "b"}, redis.Z{Score: 75, Member: "c"})
+	if err != nil {
+		t.Fatalf("ZAdd: %v", err)
+	}
+	if members, err := ZRangeByScore(ctx, "z:rank", "70", "+inf"); err != nil || !reflect.DeepEqual(members, []string{"c", "a"}) {
+		t.Errorf("ZRangeByScore = %v, %v; want [c a]", members, err)
+	}
+	if members, _ := ZRangeByScore(ctx, "z:rank", "(60", "(90"); !reflect.DeepEqual(members, []string{"c"}) {
+		t.Errorf("ZRangeByScore exclusive = %v, want [c]", members)
+	}
+	if err := ZRem(ctx, "z:rank", "c"); err != nil {
+		t.Fatalf("ZRem: %v", err)
+	}
+	if members, _ := ZRangeByScore(ctx, "z:rank", "-inf", "+inf"); !reflect.DeepEqual(members, []string{"b", "a"}) {
+		t.Errorf("ZRangeByScore after ZRem = %v, want [b a]", members)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+
+	_ = Set(ctx, "k:1", "v", 0)
+	_ = Set(ctx, "k:2", "v", 0)
+	if err := Expire(ctx, "k:1", 5*time.Second); err != nil {
+		t.Fatalf("Expire: %v", err)
+	}
+	if ttl := srv.TTL("k:1"); ttl != 5*time.Second {
+		t.Errorf("TTL = %v, want 5s", ttl)
+	}
+	if ttl := srv.TTL("k:2"); ttl != -1 {
+		t.Errorf("TTL of persistent key = %v, want -1", ttl)
+	}
+	if err := Del(ctx, "k:1", "k:2"); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Errorf("keys after Del = %v", keys)
+	}
+}
+
+func TestGeo(t *testing.T) {
+	ctx := context.Background()
+	redistest.Setup(t)
+
+	err := GeoAdd(ctx, "g:cities",
+		&redis.GeoLocation{Name: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		&redis.GeoLocation{Name: "Catania", Longitude: 15.087269, Latitude: 37.502669})
+	if err != nil {
+		t.Fatalf("GeoAdd: %v", err)
+	}
+	// Redis 文档中的示例：两地相距 166274.1516 米
+	if d, err := GeoDist(ctx, "g:cities", "Palermo", "Catania", "m"); err != nil || d != 166274.1516 {
+		t.Errorf("GeoDist = %v, %v; want 166274.1516", d, err)
+	}
+	positions, err := GeoPos(ctx, "g:cities", "Palermo", "missing")
+	if err != nil || len(positions) != 2 || positions[0] == nil || positions[1] != nil {
+		t.Fatalf("GeoPos = %v, %v; want a position and nil", positions, err)
+	}
+	if math.Abs(positions[0].Longitude-13.361389) > 1e-5 || math.Abs(positions[0].Latitude-38.115556) > 1e-5 {
+		t.Errorf("GeoPos(Palermo) = %+v", *positions[0])
+	}
+	locations, err := GeoSearchRadius(ctx, "g:cities", 15, 37, 200, "", 1)
+	if err != nil || len(locations) != 1 || locations[0].Name != "Catania" || locations[0].Dist != 56.4413 {
+		t.Errorf("GeoSearchRadius = %+v, %v; want Catania at 56.4413km", locations, err)
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	srv := redistest.Setup(t)
+	ctx := context.Background()
+
+	err := TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "tx:1", "a", 0)
+		pipe.Incr(ctx, "tx:n")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TxPipelined: %v", err)
+	}
+	if v, _ := srv.Get("tx:1"); v != "a" {
+		t.Errorf("tx:1 = %q, want a", v)
+	}
+
+	var get *redis.StringCmd
+	err = Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "p:1", "b", time.Minute)
+		get = pipe.Get(ctx, "p:1")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Pipelined: %v", err)
+	}
+	if get.Val() != "b" {
+		t.Errorf("pipelined Get = %q, want b", get.Val())
+	}
+}
+
+func TestEval(t *testing.T) {
+	ctx := context.Background()
+	srv := redistest.Setup(t)
+	const script = `return redis.call("incrby", KEYS[1], ARGV[1])`
+	srv.RegisterScript(script, func(call redistest.Call, keys, argv []string) (interface{}, error) {
+		return call("incrby", keys[0], argv[0])
+	})
+
+	if v, err := Eval(ctx, script, []string{"e:n"}, 5); err != nil || v != int64(5) {
+		t.Errorf("Eval = %v, %v; want 5", v, err)
+	}
+	if _, err := Eval(ctx, `return 1`, nil); err == nil {
+		t.Error("Eval of an unregistered script should fail")
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	ctx := context.Background()
+	redistest.Setup(t)
+
+	sub := Subscribe(ctx, "news")
+	defer sub.Close()
+	// 等待订阅确认，避免消息在订阅生效前发布
+	if _, err := sub.Receive(context.Background()); err != nil {
+		t.Fatalf("Receive subscription: %v", err)
+	}
+	if err := Publish(ctx, "news", "hello"); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case msg := <-sub.Channel():
+		if msg.Channel != "news" || msg.Payload != "hello" {
+			t.Errorf("message = %s/%s, want news/hello", msg.Channel, msg.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestTraceIDReachesHook(t *testing.T) {
+	redistest.Setup(t)
+	hook := &redistest.TraceHook{}
+	config.RedisClient.AddHook(hook)
+	ctx := instrument.WithTraceID(context.Background(), "req-1")
+
+	_ = Set(ctx, "t:1", "v", 0)
+	_, _ = Get(ctx, "t:1")
+	_, _ = HMGet(ctx, "t:h", "f")
+	_ = Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, "t:n")
+		return nil
+	})
+	want := []string{"set:req-1", "get:req-1", "hmget:req-1", "incr:req-1"}
+	if got := hook.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands seen by hook = %v, want %v", got, want)
+	}
+}
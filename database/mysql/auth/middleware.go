@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"mysql/customerror"
+	"mysql/respond"
+	"net/http"
+	"strings"
+)
+
+// AdminRole 管理员角色，拥有所有权限
+const AdminRole = "admin"
+
+type claimsKey struct{}
+
+// WithClaims 把当前用户的令牌信息放入上下文
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext 取出当前用户的令牌信息，未登录时返回 false
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+/*
+RequireRoles 认证和授权中间件：
+1.从请求头 Authorization: Bearer <token> 读取并校验令牌，失败返回 401（customerror.Unauthorized）
+2.roles 不为空时当前用户必须拥有其中一个角色（admin 总是允许），否则返回 403（customerror.Forbidden）
+3.通过后令牌信息放入请求上下文，处理函数用 ClaimsFromContext 获取
+*/
+func RequireRoles(roles []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := authenticate(r)
+		if err != nil {
+			respond.Error(w, r, err)
+			return
+		}
+		if len(roles) > 0 && !claims.HasRole(AdminRole) && !claims.HasRole(roles...) {
+			respond.Error(w, r, customerror.Forbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	})
+}
+
+// authenticate 校验请求中的令牌，返回的错误都包装了 customerror.Unauthorized
+func authenticate(r *http.Request) (*Claims, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, fmt.Errorf("%w: missing bearer token", customerror.Unauthorized)
+	}
+	claims, err := ParseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", customerror.Unauthorized, err)
+	}
+	return claims, nil
+}
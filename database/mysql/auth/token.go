@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// 签名密钥的环境变量，多个实例必须配置相同的密钥
+const secretEnv = "AUTH_SECRET"
+
+// TokenTTL 令牌有效期
+var TokenTTL = 2 * time.Hour
+
+var (
+	ErrInvalidToken = errors.New("invalid token") //令牌格式或签名错误
+	ErrTokenExpired = errors.New("token expired") //令牌已过期
+)
+
+var secret = loadSecret()
+
+// loadSecret 读取签名密钥，未配置时随机生成（重启后之前签发的令牌全部失效，仅用于本地开发）
+func loadSecret() []byte {
+	if s := os.Getenv(secretEnv); s != "" {
+		return []byte(s)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	log.Printf("未设置 %s，使用随机密钥签发令牌", secretEnv)
+	return b
+}
+
+// Claims 令牌中的信息
+type Claims struct {
+	UserID    int      `json:"sub"`
+	Username  string   `json:"name"`
+	Roles     []string `json:"roles"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// 固定的 JWT 头，只支持 HS256
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+/*
+IssueToken 签发 JWT 格式（HS256）的令牌：base64url(头).base64url(载荷).base64url(HMAC-SHA256签名)
+令牌无状态，服务端不保存，过期前无法单独吊销，所以有效期不宜过长
+*/
+func IssueToken(userID int, username string, roles []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(TokenTTL)
+	payload, err := json.Marshal(Claims{
+		UserID:    userID,
+		Username:  username,
+		Roles:     roles,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned), expiresAt, nil
+}
+
+// ParseToken 校验签名和有效期并返回令牌中的信息
+func ParseToken(token string) (*Claims, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	if !ok || header != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	payload, signature, ok := strings.Cut(rest, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(header+"."+payload))) {
+		return nil, ErrInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// HasRole 是否拥有任意一个角色
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, want := range roles {
+		for _, have := range c.Roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sign(unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -1,12 +1,19 @@
 package config
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/go-sql-driver/mysql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"mysql/replica"
+	"mysql/sqltrace"
+	"net/http"
+	"time"
 )
 
 /*
-DB 定义一个全局对象
+DB 定义一个全局对象，指向名为 default 的数据库
 sql.DB是表示连接的数据库对象（结构体实例），它保存了连接数据库相关的所有信息。
 它内部维护着一个具有零到多个底层连接的连接池，它可以安全地被多个goroutine同时使用。
 SetMaxOpenConns
@@ -16,27 +23,123 @@ SetMaxOpenConns设置与数据库建立连接的最大数目。 如果n大于0
 SetMaxIdleConns
 func (db *DB) SetMaxIdleConns(n int)
 SetMaxIdleConns设置连接池中的最大闲置连接数。 如果n大于最大开启连接数，则新的最大闲置连接数会减小到匹配最大开启连接数的限制。 如果n<=0，不会保留闲置连接。
+
+SetConnMaxLifetime
+func (db *DB) SetConnMaxLifetime(d time.Duration)
+SetConnMaxLifetime设置连接可被复用的最长时间，应小于MySQL的wait_timeout，避免使用被服务端关闭的连接。
+
+SetConnMaxIdleTime
+func (db *DB) SetConnMaxIdleTime(d time.Duration)
+SetConnMaxIdleTime设置连接最长空闲时间，流量低谷时释放多余的空闲连接。
 */
 var DB *sql.DB
 
+// DBs 所有已打开的数据库，键为配置文件中的名称
+var DBs = map[string]*sql.DB{}
+
+// Cluster 读写分离集群（主库为 DB），没有配置 replication 时为 nil，读写都使用 DB
+var Cluster *replica.Cluster
+
 // InitDB 定义一个初始化数据库的函数
+// 数据库配置见 LoadSettings：config/database.json + MYSQL_<名称>_<字段> 环境变量覆盖
 func InitDB() (err error) {
-	//数据库建表语句：go_mysql_demo.sql
-	dsn := "root:123456@tcp(localhost:3306)/go_mysql_demo?parseTime=true"
-	db, err := sql.Open("mysql", dsn)
+	settings, err := LoadSettings()
 	if err != nil {
 		return err
 	}
 
-	// 连接池配置
-	db.SetMaxOpenConns(20)
-	db.SetMaxIdleConns(10)
+	dbs := make(map[string]*sql.DB, len(settings.Databases))
+	for name, s := range settings.Databases {
+		db, err := openDB(name, s)
+		if err != nil {
+			closeAll(dbs)
+			return fmt.Errorf("open database %q: %w", name, err)
+		}
+		dbs[name] = db
+	}
+	DBs = dbs
+	DB = dbs[DefaultDBName]
 
-	// 尝试与数据库建立连接（校验dsn是否正确）
-	err = db.Ping()
-	if err != nil {
-		return err
+	if r := settings.Replication; r != nil && len(r.Replicas) > 0 {
+		replicas := make([]*replica.Replica, len(r.Replicas))
+		for i, name := range r.Replicas {
+			replicas[i] = &replica.Replica{Name: name, DB: dbs[name]}
+		}
+		Cluster = replica.New(DB, replicas, replica.Options{
+			Balancer:            replica.Balancer(r.Balancer),
+			HealthCheckInterval: time.Duration(r.HealthCheckInterval),
+			ReadYourWrites:      time.Duration(r.ReadYourWrites),
+		})
 	}
-	DB = db
 	return nil
 }
+
+// Reader 读操作使用的数据库：配置了读写分离时由 Cluster 选择从库（见 replica.Cluster.Reader），否则为 DB
+func Reader(ctx context.Context) *sql.DB {
+	if Cluster == nil {
+		return DB
+	}
+	return Cluster.Reader(ctx)
+}
+
+// Session 读你所写中间件（见 replica.Session），没有配置读写分离时直接返回 next
+func Session(next http.Handler) http.Handler {
+	if Cluster == nil {
+		return next
+	}
+	return replica.Session(Cluster.Options().ReadYourWrites)(next)
+}
+
+// GetDB 按名称获取数据库，不存在时返回 nil
+func GetDB(name string) *sql.DB {
+	return DBs[name]
+}
+
+// CloseDB 停止从库健康检查，关闭所有数据库连接池
+func CloseDB() error {
+	if Cluster != nil {
+		Cluster.Close()
+		Cluster = nil
+	}
+	return closeAll(DBs)
+}
+
+func openDB(name string, s *DatabaseSettings) (*sql.DB, error) {
+	cfg, err := s.driverConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// 包装连接器，记录语句的耗时和错误次数，按配置打印语句和慢查询
+	db := sql.OpenDB(sqltrace.Wrap(name, connector, sqltrace.Options{
+		LogQueries:         s.LogQueries,
+		SlowQueryThreshold: time.Duration(s.SlowQueryThreshold),
+		ExplainSlowQueries: s.ExplainSlowQueries,
+	}))
+
+	// 连接池配置
+	db.SetMaxOpenConns(s.MaxOpenConns)
+	db.SetMaxIdleConns(s.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(s.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(s.ConnMaxIdleTime))
+
+	// 尝试与数据库建立连接（校验配置是否正确）
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func closeAll(dbs map[string]*sql.DB) error {
+	var errs []error
+	for _, db := range dbs {
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"mysql/replica"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDBName 默认数据库的名称，config.DB 指向它
+const DefaultDBName = "default"
+
+// 配置文件路径的环境变量，未设置时读取 defaultConfigFile
+const (
+	configFileEnv     = "MYSQL_CONFIG"
+	defaultConfigFile = "config/database.json"
+)
+
+// Duration 支持在 JSON 中用 "30s"、"5m" 这样的字符串表示时长
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+/*
+DatabaseSettings 一个数据库的连接和连接池配置
+每个字段都可以用环境变量覆盖：MYSQL_<名称>_<字段>，名称和字段均为大写下划线形式，
+例如 MYSQL_DEFAULT_PASSWORD、MYSQL_DEFAULT_MAX_OPEN_CONNS、MYSQL_REPORT_HOST。
+*/
+type DatabaseSettings struct {
+	Host     string            `json:"host" env:"HOST"`
+	Port     int               `json:"port" env:"PORT"`
+	User     string            `json:"user" env:"USER"`
+	Password string            `json:"password" env:"PASSWORD"`
+	Database string            `json:"database" env:"DATABASE"`
+	Params   map[string]string `json:"params"` //额外的DSN参数，例如 {"collation": "utf8mb4_unicode_ci", "time_zone": "'+08:00'"}，规则见 driverParams
+
+	// TLS：空或 false 不加密；true 校验证书；skip-verify 不校验证书；preferred 服务端支持时加密；custom 使用 TLSCA 等自定义证书
+	TLS     string `json:"tls" env:"TLS"`
+	TLSCA   string `json:"tlsCA" env:"TLS_CA"`     //CA证书文件
+	TLSCert string `json:"tlsCert" env:"TLS_CERT"` //客户端证书文件（双向认证时需要）
+	TLSKey  string `json:"tlsKey" env:"TLS_KEY"`   //客户端私钥文件（双向认证时需要）
+
+	Timeout      Duration `json:"timeout" env:"TIMEOUT"`            //建立连接超时
+	ReadTimeout  Duration `json:"readTimeout" env:"READ_TIMEOUT"`   //读超时
+	WriteTimeout Duration `json:"writeTimeout" env:"WRITE_TIMEOUT"` //写超时
+
+	MaxOpenConns    int      `json:"maxOpenConns" env:"MAX_OPEN_CONNS"`        //最大连接数，0表示不限制
+	MaxIdleConns    int      `json:"maxIdleConns" env:"MAX_IDLE_CONNS"`        //最大空闲连接数
+	ConnMaxLifetime Duration `json:"connMaxLifetime" env:"CONN_MAX_LIFETIME"`  //连接最长使用时间，应小于MySQL的wait_timeout
+	ConnMaxIdleTime Duration `json:"connMaxIdleTime" env:"CONN_MAX_IDLE_TIME"` //连接最长空闲时间
+
+	// 语句跟踪（见 sqltrace.Wrap），耗时和错误次数的指标始终记录
+	LogQueries         bool     `json:"logQueries" env:"LOG_QUERIES"`                  //打印每条语句，参数只打印类型
+	SlowQueryThreshold Duration `json:"slowQueryThreshold" env:"SLOW_QUERY_THRESHOLD"` //慢查询阈值，0 表示不检测
+	ExplainSlowQueries bool     `json:"explainSlowQueries" env:"EXPLAIN_SLOW_QUERIES"` //慢查询日志附带 EXPLAIN 执行计划
+}
+
+// Settings 配置文件的结构，databases 的键为数据库名称，必须包含 default
+type Settings struct {
+	Databases   map[string]*DatabaseSettings `json:"databases"`
+	Replication *ReplicationSettings         `json:"replication"` //读写分离，不配置时读写都使用 default
+}
+
+/*
+ReplicationSettings 读写分离配置：default 为主库，replicas 为从库在 databases 中的名称
+1.balancer 从库选择策略：round-robin（默认）轮询，least-conn 使用中连接数最少
+2.healthCheckInterval 从库健康检查间隔，Ping 失败的从库移出读负载，恢复后重新加入，默认 5s
+3.readYourWrites 写入后同一客户端的读请求走主库的时长（通过 Cookie 记录），应大于复制延迟；为 0 时只在写入的请求内走主库
+*/
+type ReplicationSettings struct {
+	Replicas            []string `json:"replicas"`
+	Balancer            string   `json:"balancer"`
+	HealthCheckInterval Duration `json:"healthCheckInterval"`
+	ReadYourWrites      Duration `json:"readYourWrites"`
+}
+
+// defaultSettings 没有配置文件时使用的默认配置（本地开发环境）
+func defaultSettings() *DatabaseSettings {
+	return &DatabaseSettings{
+		Host:               "localhost",
+		Port:               3306,
+		User:               "root",
+		Password:           "123456",
+		Database:           "go_mysql_demo",
+		Timeout:            Duration(5 * time.Second),
+		ReadTimeout:        Duration(30 * time.Second),
+		WriteTimeout:       Duration(30 * time.Second),
+		MaxOpenConns:       20,
+		MaxIdleConns:       10,
+		ConnMaxLifetime:    Duration(30 * time.Minute),
+		ConnMaxIdleTime:    Duration(5 * time.Minute),
+		SlowQueryThreshold: Duration(time.Second),
+	}
+}
+
+// LoadSettings 读取配置文件（路径取环境变量 MYSQL_CONFIG，默认 config/database.json）并应用环境变量覆盖，最后校验
+// 配置文件不存在时使用默认配置
+func LoadSettings() (*Settings, error) {
+	path := os.Getenv(configFileEnv)
+	if path == "" {
+		path = defaultConfigFile
+	}
+	s := &Settings{}
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && os.Getenv(configFileEnv) == "":
+		// 未指定配置文件且默认文件不存在
+	default:
+		return nil, err
+	}
+	if s.Databases == nil {
+		s.Databases = make(map[string]*DatabaseSettings)
+	}
+	if s.Databases[DefaultDBName] == nil {
+		s.Databases[DefaultDBName] = defaultSettings()
+	}
+
+	for name, db := range s.Databases {
+		if db == nil {
+			return nil, fmt.Errorf("database %q: empty settings", name)
+		}
+		if err := db.applyEnv(name); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// applyEnv 用 MYSQL_<名称>_<字段> 环境变量覆盖配置
+func (d *DatabaseSettings) applyEnv(name string) error {
+	prefix := "MYSQL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	v := reflect.ValueOf(d).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(prefix + key)
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(raw)
+		case int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", prefix, key, err)
+			}
+			field.SetInt(int64(n))
+		case bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", prefix, key, err)
+			}
+			field.SetBool(b)
+		case Duration:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", prefix, key, err)
+			}
+			field.SetInt(int64(d))
+		}
+	}
+	return nil
+}
+
+// Validate 校验所有数据库配置，返回所有错误
+func (s *Settings) Validate() error {
+	if s.Databases[DefaultDBName] == nil {
+		return fmt.Errorf("database %q is required", DefaultDBName)
+	}
+	var errs []error
+	for name, db := range s.Databases {
+		if err := db.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("database %q: %w", name, err))
+		}
+	}
+	if s.Replication != nil {
+		if err := s.Replication.validate(s.Databases); err != nil {
+			errs = append(errs, fmt.Errorf("replication: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *ReplicationSettings) validate(databases map[string]*DatabaseSettings) error {
+	var errs []error
+	seen := make(map[string]bool, len(r.Replicas))
+	for _, name := range r.Replicas {
+		switch {
+		case name == DefaultDBName:
+			errs = append(errs, fmt.Errorf("primary %q cannot be a replica", name))
+		case databases[name] == nil:
+			errs = append(errs, fmt.Errorf("unknown replica %q", name))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("duplicate replica %q", name))
+		}
+		seen[name] = true
+	}
+	switch replica.Balancer(r.Balancer) {
+	case "", replica.RoundRobin, replica.LeastConn:
+	default:
+		errs = append(errs, fmt.Errorf("invalid balancer %q", r.Balancer))
+	}
+	if r.HealthCheckInterval < 0 || r.ReadYourWrites < 0 {
+		errs = append(errs, errors.New("healthCheckInterval and readYourWrites must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+func (d *DatabaseSettings) validate() error {
+	var errs []error
+	if d.Host == "" {
+		errs = append(errs, errors.New("host is required"))
+	}
+	if d.Port <= 0 || d.Port > 65535 {
+		errs = append(errs, fmt.Errorf("invalid port %d", d.Port))
+	}
+	if d.User == "" {
+		errs = append(errs, errors.New("user is required"))
+	}
+	if d.Database == "" {
+		errs = append(errs, errors.New("database is required"))
+	}
+	switch d.TLS {
+	case "", "false", "true", "skip-verify", "preferred":
+	case "custom":
+		if d.TLSCA == "" {
+			errs = append(errs, errors.New("tlsCA is required when tls is custom"))
+		}
+		if (d.TLSCert == "") != (d.TLSKey == "") {
+			errs = append(errs, errors.New("tlsCert and tlsKey must be set together"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid tls %q", d.TLS))
+	}
+	if d.Timeout < 0 || d.ReadTimeout < 0 || d.WriteTimeout < 0 || d.ConnMaxLifetime < 0 || d.ConnMaxIdleTime < 0 || d.SlowQueryThreshold < 0 {
+		errs = append(errs, errors.New("timeouts and lifetimes must not be negative"))
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("pool sizes must not be negative"))
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("maxIdleConns %d exceeds maxOpenConns %d", d.MaxIdleConns, d.MaxOpenConns))
+	}
+	for key := range d.Params {
+		if reason, ok := reservedParams[key]; ok {
+			errs = append(errs, fmt.Errorf("params.%s is not allowed: %s", key, reason))
+		}
+	}
+	if _, err := driverParams(d.Params); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// reservedParams 由 DatabaseSettings 字段或代码决定的 DSN 参数，写在 Params 中会被覆盖，直接报错
+var reservedParams = map[string]string{
+	"tls":          "use the tls field",
+	"timeout":      "use the timeout field",
+	"readTimeout":  "use the readTimeout field",
+	"writeTimeout": "use the writeTimeout field",
+	"parseTime":    "parseTime is always enabled",
+}
+
+/*
+driverParams 用驱动自己的 DSN 解析处理 Params：
+charset、collation、loc 这类驱动参数设置到 mysql.Config 对应的配置上，驱动不认识的参数才留在 Config.Params 中，
+连接时作为会话系统变量执行 SET <参数> = <值>（例如 time_zone、sql_mode，字符串值需要带引号）。
+直接把 charset 放进 Config.Params 会变成 SET charset = utf8mb4，MySQL 不认识这个系统变量，连接会失败。
+*/
+func driverParams(params map[string]string) (*mysql.Config, error) {
+	if len(params) == 0 {
+		return mysql.NewConfig(), nil
+	}
+	values := make(url.Values, len(params))
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	cfg, err := mysql.ParseDSN("/?" + values.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return cfg, nil
+}
+
+// driverConfig 转换为 go-sql-driver/mysql 的配置，name 用于注册自定义TLS配置
+func (d *DatabaseSettings) driverConfig(name string) (*mysql.Config, error) {
+	cfg, err := driverParams(d.Params)
+	if err != nil {
+		return nil, err
+	}
+	cfg.User = d.User
+	cfg.Passwd = d.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", d.Host, d.Port)
+	cfg.DBName = d.Database
+	cfg.ParseTime = true
+	cfg.Timeout = time.Duration(d.Timeout)
+	cfg.ReadTimeout = time.Duration(d.ReadTimeout)
+	cfg.WriteTimeout = time.Duration(d.WriteTimeout)
+
+	switch d.TLS {
+	case "", "false":
+	case "custom":
+		tlsConfig, err := d.loadTLS()
+		if err != nil {
+			return nil, err
+		}
+		key := "custom-" + name
+		if err := mysql.RegisterTLSConfig(key, tlsConfig); err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = key
+	default:
+		cfg.TLSConfig = d.TLS
+	}
+	return cfg, nil
+}
+
+func (d *DatabaseSettings) loadTLS() (*tls.Config, error) {
+	ca, err := os.ReadFile(d.TLSCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %s", d.TLSCA)
+	}
+	tlsConfig := &tls.Config{RootCAs: pool, ServerName: d.Host}
+	if d.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(d.TLSCert, d.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
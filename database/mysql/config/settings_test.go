@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDriverConfigParams(t *testing.T) {
+	d := &DatabaseSettings{
+		Host: "localhost", Port: 3306, User: "root", Database: "demo",
+		Params: map[string]string{"charset": "utf8mb4", "collation": "utf8mb4_unicode_ci", "time_zone": "'+08:00'"},
+	}
+	if err := d.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	cfg, err := d.driverConfig("test")
+	if err != nil {
+		t.Fatalf("driverConfig: %v", err)
+	}
+	// 驱动参数不能变成 SET charset = ...，只有系统变量留在 Params 中
+	if want := map[string]string{"time_zone": "'+08:00'"}; !reflect.DeepEqual(cfg.Params, want) {
+		t.Errorf("Params = %v, want %v", cfg.Params, want)
+	}
+	if cfg.Collation != "utf8mb4_unicode_ci" {
+		t.Errorf("Collation = %q, want utf8mb4_unicode_ci", cfg.Collation)
+	}
+	if dsn := cfg.FormatDSN(); !strings.Contains(dsn, "charset=utf8mb4") {
+		t.Errorf("DSN %q does not set the connection charset", dsn)
+	}
+	if cfg.User != "root" || cfg.Addr != "localhost:3306" || cfg.DBName != "demo" || !cfg.ParseTime {
+		t.Errorf("settings fields not applied: %+v", cfg)
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		params  map[string]string
+		wantErr string
+	}{
+		{params: nil},
+		{params: map[string]string{"sql_mode": "'STRICT_ALL_TABLES'"}},
+		{params: map[string]string{"tls": "true"}, wantErr: "params.tls is not allowed"},
+		{params: map[string]string{"parseTime": "false"}, wantErr: "params.parseTime is not allowed"},
+		{params: map[string]string{"interpolateParams": "yes"}, wantErr: "invalid params"},
+	}
+	for _, tt := range tests {
+		d := &DatabaseSettings{Host: "localhost", Port: 3306, User: "root", Database: "demo", Params: tt.params}
+		err := d.validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validate(%v) = %v", tt.params, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validate(%v) = %v, want %q", tt.params, err, tt.wantErr)
+		}
+	}
+}
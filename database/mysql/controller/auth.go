@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"mysql/auth"
+	"mysql/model"
+	"mysql/respond"
+	"net/http"
+	"time"
+)
+
+// loginRequest 登录请求体
+type loginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// loginResponse 登录响应，请求其他接口时放在请求头 Authorization: Bearer <token>
+type loginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Login 用户名密码登录，返回令牌：POST /login
+func Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decodeBody(r, &req); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	user, err := model.Authenticate(r.Context(), req.Username, req.Password)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	roles, err := model.UserRoles(r.Context(), user.Id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	token, expiresAt, err := auth.IssueToken(user.Id, user.Username, roles)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	respond.JSON(w, http.StatusOK, loginResponse{Token: token, ExpiresAt: expiresAt})
+}
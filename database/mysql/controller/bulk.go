@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mysql/customerror"
+	"mysql/model"
+	"mysql/respond"
+	"mysql/validate"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 批量接口的限制
+const (
+	maxBulkBodySize = 32 << 20
+	maxBulkItems    = 10000
+)
+
+/*
+批量接口的读写超时，覆盖服务器默认的 ReadTimeout/WriteTimeout：
+1.bulkReadTimeout 读取请求体的时间，32MB 的请求体在慢速网络下会超过默认的 30 秒
+2.bulkWriteTimeout 从读取请求体开始到写完响应的时间，批量创建要计算最多 10000 个 argon2id 哈希再提交事务，
+超过写超时的话事务已经提交，客户端却收不到结果
+*/
+const (
+	bulkReadTimeout  = 2 * time.Minute
+	bulkWriteTimeout = 10 * time.Minute
+)
+
+// NDJSON（每行一个 JSON）的 Content-Type
+var ndjsonTypes = map[string]bool{"application/x-ndjson": true, "application/ndjson": true, "application/jsonl": true}
+
+// bulkItemResult 批量操作中一项的结果
+type bulkItemResult struct {
+	Index  int                `json:"index"` //该项在请求中的下标（NDJSON 为第几个非空行，从 0 开始）
+	Id     int                `json:"id,omitempty"`
+	Status model.BulkStatus   `json:"status"`
+	Error  *respond.ErrorBody `json:"error,omitempty"`
+}
+
+// bulkResponse 批量操作的响应，全部成功时为 200，有失败项时为 207
+type bulkResponse struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []bulkItemResult `json:"results"`
+}
+
+/*
+BulkCreate 批量创建用户：POST /users/bulk?upsert=true&chunkSize=500
+请求体为 createUserRequest 数组，或者 Content-Type 为 application/x-ndjson 时每行一个
+校验失败和用户名冲突的项记录在结果中，其余项在同一个事务中分批插入；upsert=true 时用户名已存在则更新
+*/
+func (h *UserHandler) BulkCreate(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseBulkOptions(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	reqs, err := decodeBulk[createUserRequest](w, r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	results := make([]bulkItemResult, len(reqs))
+	var users []*model.User
+	var passwords []string
+	var indexes []int //users 中每一项在请求中的下标
+	for i := range reqs {
+		results[i].Index = i
+		if err := validate.Struct(&reqs[i]); err != nil {
+			results[i].fail(err)
+			continue
+		}
+		user := reqs[i].User
+		users = append(users, &user)
+		passwords = append(passwords, reqs[i].Password)
+		indexes = append(indexes, i)
+	}
+	hashes, err := model.HashPasswords(r.Context(), passwords)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	for i, hash := range hashes {
+		users[i].Password = hash
+	}
+
+	var created []model.BulkResult
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		created, err = model.CreateUsers(ctx, users, opts)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	for _, result := range created {
+		results[indexes[result.Index]].set(result)
+	}
+
+	writeBulk(w, results)
+}
+
+// BulkUpdate 批量更新用户：PUT /users/bulk，请求体为用户数组（或 NDJSON），每个用户都要带上 id 和 version
+func (h *UserHandler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	items, err := decodeBulk[model.User](w, r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	results := make([]bulkItemResult, len(items))
+	var users []*model.User
+	var indexes []int
+	for i := range items {
+		results[i] = bulkItemResult{Index: i, Id: items[i].Id}
+		if items[i].Id <= 0 {
+			results[i].fail(errInvalidID)
+			continue
+		}
+		if err := validate.Struct(&items[i]); err != nil {
+			results[i].fail(err)
+			continue
+		}
+		users = append(users, &items[i])
+		indexes = append(indexes, i)
+	}
+
+	var updated []model.BulkResult
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		updated, err = model.UpdateUsers(ctx, users)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	for _, result := range updated {
+		results[indexes[result.Index]].set(result)
+	}
+
+	writeBulk(w, results)
+}
+
+// BulkDelete 批量软删除用户：DELETE /users/bulk?chunkSize=500，请求体为用户ID数组（或 NDJSON，每行一个ID）
+func (h *UserHandler) BulkDelete(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseBulkOptions(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	ids, err := decodeBulk[int](w, r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	var deleted []model.BulkResult
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		deleted, err = model.DeleteUsers(ctx, ids, opts.ChunkSize)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	results := make([]bulkItemResult, len(deleted))
+	for i, result := range deleted {
+		results[i].Index = result.Index
+		results[i].set(result)
+	}
+
+	writeBulk(w, results)
+}
+
+// parseBulkOptions 解析批量接口的查询参数 upsert 和 chunkSize
+func parseBulkOptions(r *http.Request) (model.BulkOptions, error) {
+	var opts model.BulkOptions
+	var err error
+	params := r.URL.Query()
+	if v := params.Get("upsert"); v != "" {
+		if opts.Upsert, err = strconv.ParseBool(v); err != nil {
+			return opts, customerror.BadRequest(fmt.Sprintf("invalid upsert %q", v))
+		}
+	}
+	if v := params.Get("chunkSize"); v != "" {
+		if opts.ChunkSize, err = strconv.Atoi(v); err != nil || opts.ChunkSize < 1 || opts.ChunkSize > model.MaxBulkChunkSize {
+			return opts, customerror.BadRequest(fmt.Sprintf("invalid chunkSize %q, must be between 1 and %d", v, model.MaxBulkChunkSize))
+		}
+	}
+	return opts, nil
+}
+
+/*
+decodeBulk 解析批量请求体：默认为 JSON 数组，Content-Type 为 NDJSON 时每行一个 JSON（跳过空行）
+格式错误时返回 400，NDJSON 的错误信息带行号；不做 validate 校验，由调用方逐项校验
+读取前先延长读写超时（见 bulkReadTimeout、bulkWriteTimeout），所有批量接口都经过这里
+*/
+func decodeBulk[T any](w http.ResponseWriter, r *http.Request) ([]T, error) {
+	rc := http.NewResponseController(w)
+	extendReadDeadline(rc, bulkReadTimeout)
+	extendWriteDeadline(rc, bulkWriteTimeout)
+	body := http.MaxBytesReader(w, r.Body, maxBulkBodySize)
+	var items []T
+	if ndjsonTypes[mediaType(r)] {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), maxBodySize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var item T
+			if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+				return nil, customerror.BadRequest(fmt.Sprintf("invalid request body: line %d: %v", line, err))
+			}
+			if items = append(items, item); len(items) > maxBulkItems {
+				return nil, errTooManyItems
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, customerror.BadRequest("invalid request body: " + err.Error())
+		}
+	} else if err := json.NewDecoder(body).Decode(&items); err != nil {
+		return nil, customerror.BadRequest("invalid request body: " + err.Error())
+	}
+	if len(items) == 0 {
+		return nil, customerror.BadRequest("request body must contain at least one item")
+	}
+	if len(items) > maxBulkItems {
+		return nil, errTooManyItems
+	}
+	return items, nil
+}
+
+var errTooManyItems = customerror.BadRequest(fmt.Sprintf("too many items, at most %d per request", maxBulkItems))
+
+func (res *bulkItemResult) set(result model.BulkResult) {
+	res.Id = result.Id
+	res.Status = result.Status
+	if result.Err != nil {
+		res.fail(result.Err)
+	}
+}
+
+func (res *bulkItemResult) fail(err error) {
+	body := respond.ErrorBodyOf(err)
+	res.Status = model.BulkFailed
+	res.Error = &body
+}
+
+// writeBulk 统计结果并写响应
+func writeBulk(w http.ResponseWriter, results []bulkItemResult) {
+	resp := bulkResponse{Results: results}
+	for _, result := range results {
+		if result.Status == model.BulkFailed {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond.JSON(w, status, resp)
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"mysql/model"
+	"mysql/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wantBulk 检查批量操作每一项的状态
+func wantBulk(statuses ...model.BulkStatus) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		resp := decode[bulkResponse](t, rec)
+		if len(resp.Results) != len(statuses) {
+			t.Fatalf("results = %+v, want %d items", resp.Results, len(statuses))
+		}
+		for i, result := range resp.Results {
+			if result.Index != i || result.Status != statuses[i] {
+				t.Errorf("results[%d] = %+v, want status %s", i, result, statuses[i])
+			}
+		}
+	}
+}
+
+func TestUserHandler_BulkCreate(t *testing.T) {
+	insert := "INSERT INTO users (created_at, created_by, update_at, update_by, username, password, name, version, deleted_at) VALUES "
+	two := `[{"username":"alice","name":"Alice","password":"secret-password"},{"username":"bob","name":"Bob","password":"secret-password"}]`
+	runCases(t, http.MethodPost, func(h *UserHandler) http.HandlerFunc { return h.BulkCreate }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/bulk",
+			body:   two,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert+"(?, ?, ?, ?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?, ?, ?, ?)").WillReturnResult(1, 2)
+				db.ExpectExec("RELEASE SAVEPOINT sp_1")
+				db.ExpectQuery("SELECT id, username FROM users WHERE username IN (?, ?)").WithArgs("alice", "bob").
+					WillReturnRows(sqltest.NewRows("id", "username").AddRow(1, "alice").AddRow(2, "bob"))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantBulk(model.BulkCreated, model.BulkCreated),
+		},
+		{
+			name:   "ndjson with an invalid item",
+			target: "/users/bulk",
+			header: map[string]string{"Content-Type": "application/x-ndjson"},
+			body:   "{\"username\":\"alice\",\"name\":\"Alice\",\"password\":\"secret-password\"}\n\n{\"username\":\"bob\",\"name\":\"Bob\",\"password\":\"short\"}\n",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert+"(?, ?, ?, ?, ?, ?, ?, ?, ?) ").WillReturnResult(1, 1)
+				db.ExpectExec("RELEASE SAVEPOINT sp_1")
+				db.ExpectQuery("SELECT id, username FROM users WHERE username IN (?)").
+					WillReturnRows(sqltest.NewRows("id", "username").AddRow(1, "alice"))
+				db.ExpectCommit()
+			},
+			status: http.StatusMultiStatus,
+			check:  wantBulk(model.BulkCreated, model.BulkFailed),
+		},
+		{
+			name:   "duplicate falls back to one by one",
+			target: "/users/bulk",
+			body:   two,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert).WillReturnError(errDup)
+				db.ExpectExec("ROLLBACK TO SAVEPOINT sp_1")
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert).WithArgs(sqltest.Any, "system", sqltest.Any, "system", "alice", sqltest.Any, "Alice", 1, nil).
+					WillReturnError(errDup)
+				db.ExpectExec("ROLLBACK TO SAVEPOINT sp_1")
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert).WithArgs(sqltest.Any, "system", sqltest.Any, "system", "bob", sqltest.Any, "Bob", 1, nil).
+					WillReturnResult(2, 1)
+				db.ExpectExec("RELEASE SAVEPOINT sp_1")
+				db.ExpectCommit()
+			},
+			status: http.StatusMultiStatus,
+			check:  wantBulk(model.BulkFailed, model.BulkCreated),
+		},
+		{
+			name:   "upsert",
+			target: "/users/bulk?upsert=true",
+			body:   two,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery("SELECT id, username FROM users WHERE username IN (?, ?)").
+					WillReturnRows(sqltest.NewRows("id", "username").AddRow(1, "alice"))
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec("ON DUPLICATE KEY UPDATE update_at = VALUES(update_at), update_by = VALUES(update_by), username = VALUES(username)").WillReturnResult(2, 3)
+				db.ExpectExec("RELEASE SAVEPOINT sp_1")
+				db.ExpectQuery("SELECT id, username FROM users WHERE username IN (?, ?)").
+					WillReturnRows(sqltest.NewRows("id", "username").AddRow(1, "alice").AddRow(2, "bob"))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantBulk(model.BulkUpdated, model.BulkCreated),
+		},
+		{
+			name:   "database error rolls back",
+			target: "/users/bulk",
+			body:   two,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec(insert).WillReturnError(errDBDown)
+				db.ExpectExec("ROLLBACK TO SAVEPOINT sp_1")
+				db.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "empty body",
+			target: "/users/bulk",
+			body:   `[]`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid chunk size",
+			target: "/users/bulk?chunkSize=0",
+			body:   two,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestUserHandler_BulkUpdate(t *testing.T) {
+	update := "UPDATE users SET update_at = ?, update_by = ?, username = ?, name = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND version = ?"
+	runCases(t, http.MethodPut, func(h *UserHandler) http.HandlerFunc { return h.BulkUpdate }, []handlerCase{
+		{
+			name:   "partial failure",
+			target: "/users/bulk",
+			body:   `[{"id":1,"username":"alice","name":"Alice","version":1},{"id":2,"username":"bob","name":"Bob","version":3},{"username":"carol","name":"Carol"},{"id":4,"username":"","name":"Dave"}]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WithArgs(sqltest.Any, "system", "alice", "Alice", 1, 1).WillReturnResult(0, 1)
+				db.ExpectExec(update).WithArgs(sqltest.Any, "system", "bob", "Bob", 2, 3).WillReturnResult(0, 0)
+				db.ExpectQuery(userExists).WithArgs(2).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectCommit()
+			},
+			status: http.StatusMultiStatus,
+			check:  wantBulk(model.BulkUpdated, model.BulkFailed, model.BulkFailed, model.BulkFailed),
+		},
+		{
+			name:   "database error rolls back",
+			target: "/users/bulk",
+			body:   `[{"id":1,"username":"alice","name":"Alice","version":1}]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnError(errDBDown)
+				db.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "invalid json",
+			target: "/users/bulk",
+			body:   `{"id":1}`,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestUserHandler_BulkDelete(t *testing.T) {
+	lock := "SELECT id FROM users WHERE id IN (?, ?, ?) AND deleted_at IS NULL FOR UPDATE"
+	runCases(t, http.MethodDelete, func(h *UserHandler) http.HandlerFunc { return h.BulkDelete }, []handlerCase{
+		{
+			name:   "partial failure",
+			target: "/users/bulk",
+			body:   `[1, 2, 1]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(lock).WithArgs(1, 2, 1).WillReturnRows(sqltest.NewRows("id").AddRow(1))
+				db.ExpectExec("UPDATE users SET deleted_at = ?, update_at = ?, update_by = ?, version = version + 1 WHERE id IN (?)").
+					WithArgs(sqltest.Any, sqltest.Any, "system", 1).
+					WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusMultiStatus,
+			check:  wantBulk(model.BulkDeleted, model.BulkFailed, model.BulkFailed),
+		},
+		{
+			name:   "database error rolls back",
+			target: "/users/bulk",
+			body:   `[1, 2, 3]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(lock).WillReturnError(errDBDown)
+				db.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "too many items",
+			target: "/users/bulk",
+			body:   "[" + strings.Repeat("1,", maxBulkItems) + "1]",
+			status: http.StatusBadRequest,
+		},
+	})
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"mysql/auth"
+	"mysql/customerror"
+	"mysql/model"
+	"mysql/respond"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 导出时每写这么多行刷新一次响应，让客户端尽快收到数据
+const exportFlushEvery = 1000
+
+// 导出时每次刷新后把写超时延长这么久，大表导出不受 http.Server.WriteTimeout 限制，客户端停止读取时仍然会超时
+const exportWriteTimeout = time.Minute
+
+/*
+Export 流式导出用户：GET /users/export?format=csv|ndjson（默认 csv），管理员可以加 withDeleted=true 包括已删除的用户
+按ID顺序逐行读取（见 model.AllUsers）、逐行写出，不把整张表读入内存
+开始写响应之后再出错时无法改成错误响应，只能中断连接（客户端会收到不完整的响应而不是被截断的文件）
+服务器的写超时对整个响应生效，导出过程中每次刷新都会延长写超时（见 exportWriteTimeout）
+*/
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		respond.Error(w, r, customerror.BadRequest(fmt.Sprintf("invalid format %q, want csv or ndjson", format)))
+		return
+	}
+	var withDeleted bool
+	if v := r.URL.Query().Get("withDeleted"); v != "" {
+		var err error
+		if withDeleted, err = strconv.ParseBool(v); err != nil {
+			respond.Error(w, r, customerror.BadRequest(fmt.Sprintf("invalid withDeleted %q", v)))
+			return
+		}
+	}
+	if claims, ok := auth.ClaimsFromContext(r.Context()); withDeleted && (!ok || !claims.HasRole(auth.AdminRole)) {
+		respond.Error(w, r, customerror.Forbidden)
+		return
+	}
+
+	// 先取第一行，查询失败时还可以返回正常的错误响应
+	next, stop := iter.Pull2(model.AllUsers(r.Context(), withDeleted))
+	defer stop()
+	user, err, ok := next()
+	if ok && err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`, time.Now().Format("20060102150405"), format))
+	var enc userEncoder
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		enc = &csvUserEncoder{w: csv.NewWriter(w)}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = &ndjsonUserEncoder{enc: json.NewEncoder(w)}
+	}
+	rc := http.NewResponseController(w)
+	extendWriteDeadline(rc, exportWriteTimeout)
+	w.WriteHeader(http.StatusOK)
+
+	n := 0
+	for err = enc.Begin(); ok && err == nil; user, err, ok = next() {
+		if err = enc.Encode(&user); err != nil {
+			break
+		}
+		if n++; n%exportFlushEvery == 0 {
+			if err = enc.Flush(); err == nil {
+				err = rc.Flush()
+				extendWriteDeadline(rc, exportWriteTimeout)
+			}
+		}
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		log.Printf("导出用户失败，已写出 %d 行，request_id=%s：%v", n, respond.RequestIDFromContext(r.Context()), err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// extendWriteDeadline 把写超时延长到 d 之后，不支持设置超时的 ResponseWriter（例如 httptest.ResponseRecorder）忽略
+func extendWriteDeadline(rc *http.ResponseController, d time.Duration) {
+	if err := rc.SetWriteDeadline(time.Now().Add(d)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("延长写超时失败：%v", err)
+	}
+}
+
+// extendReadDeadline 把读超时延长到 d 之后，规则同 extendWriteDeadline
+func extendReadDeadline(rc *http.ResponseController, d time.Duration) {
+	if err := rc.SetReadDeadline(time.Now().Add(d)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("延长读超时失败：%v", err)
+	}
+}
+
+// userEncoder 导出格式
+type userEncoder interface {
+	Begin() error //写文件头（CSV 表头）
+	Encode(u *model.User) error
+	Flush() error //把缓冲的数据写入响应
+}
+
+type csvUserEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvUserEncoder) Begin() error {
+	return e.w.Write(model.UserCSVHeader)
+}
+
+func (e *csvUserEncoder) Encode(u *model.User) error {
+	return e.w.Write(u.CSVRecord())
+}
+
+func (e *csvUserEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonUserEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonUserEncoder) Begin() error {
+	return nil
+}
+
+func (e *ndjsonUserEncoder) Encode(u *model.User) error {
+	return e.enc.Encode(u)
+}
+
+func (e *ndjsonUserEncoder) Flush() error {
+	return nil //json.Encoder 每次直接写入响应，没有缓冲
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"encoding/csv"
+	"mysql/auth"
+	"mysql/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserHandler_Export(t *testing.T) {
+	selectAll := "SELECT id, created_at, created_by, update_at, update_by, username, password, name, version, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id"
+	bob := alice()
+	bob.Id, bob.Username, bob.Name = 2, "bob", "Bob"
+	runCases(t, http.MethodGet, func(h *UserHandler) http.HandlerFunc { return h.Export }, []handlerCase{
+		{
+			name:   "csv",
+			target: "/users/export",
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectAll).WillReturnRows(userRows(alice(), bob))
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				records, err := csv.NewReader(rec.Body).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(records) != 3 || records[0][0] != "id" || records[1][1] != "alice" || records[2][1] != "bob" {
+					t.Errorf("records = %v", records)
+				}
+				if strings.Contains(rec.Body.String(), "hash") {
+					t.Error("export must not contain the password")
+				}
+			},
+		},
+		{
+			name:   "ndjson with deleted",
+			target: "/users/export?format=ndjson&withDeleted=true",
+			claims: admin,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery("FROM users ORDER BY id").WillReturnRows(userRows(alice()))
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"username":"alice"`) {
+					t.Errorf("body = %q", rec.Body)
+				}
+			},
+		},
+		{
+			name:   "empty table",
+			target: "/users/export",
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectAll)
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if got := strings.TrimSpace(rec.Body.String()); !strings.HasPrefix(got, "id,username") || strings.Contains(got, "\n") {
+					t.Errorf("body = %q, want only the header", got)
+				}
+			},
+		},
+		{
+			name:   "invalid format",
+			target: "/users/export?format=xml",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "with deleted requires admin",
+			target: "/users/export?withDeleted=true",
+			claims: &auth.Claims{UserID: 1, Username: "alice"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "query error",
+			target: "/users/export",
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectAll).WillReturnError(errDBDown)
+			},
+			status: http.StatusInternalServerError,
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"mysql/respond"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 就绪检查 Ping 数据库的超时时间
+const readinessTimeout = 2 * time.Second
+
+/*
+Health 存活和就绪检查，供负载均衡和容器编排使用：
+1.GET /healthz 存活检查，进程能处理请求就返回 200，不访问数据库（数据库故障时重启进程没有帮助）
+2.GET /readyz 就绪检查，Ping 所有数据库，全部可用时返回 200，否则返回 503 和每个数据库的状态
+3.开始关闭服务后（见 Drain）就绪检查始终返回 503，让负载均衡在连接断开前把流量切走
+*/
+type Health struct {
+	DBs map[string]*sql.DB
+
+	draining atomic.Bool
+}
+
+// HealthStatus 检查结果，Databases 为每个数据库的状态（ok 或错误信息）
+type HealthStatus struct {
+	Status    string            `json:"status"` //ok、unavailable 或 shutting down
+	Databases map[string]string `json:"databases,omitempty"`
+}
+
+// Drain 标记服务正在关闭，之后的就绪检查返回 503
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Live 存活检查：GET /healthz
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// Ready 就绪检查：GET /readyz，并发 Ping 所有数据库
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		respond.JSON(w, http.StatusServiceUnavailable, HealthStatus{Status: "shutting down"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	resp := HealthStatus{Status: "ok", Databases: make(map[string]string, len(h.DBs))}
+	for name, db := range h.DBs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.PingContext(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				resp.Status = "unavailable"
+				resp.Databases[name] = err.Error()
+				return
+			}
+			resp.Databases[name] = "ok"
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	respond.JSON(w, status, resp)
+}
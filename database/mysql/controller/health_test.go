@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"database/sql"
+	"mysql/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	h := &Health{DBs: map[string]*sql.DB{"default": sqltest.New(t).DB}}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		drain   bool
+		status  int
+		want    string
+	}{
+		{name: "live", handler: h.Live, status: http.StatusOK, want: "ok"},
+		{name: "ready", handler: h.Ready, status: http.StatusOK, want: "ok"},
+		{name: "live while draining", handler: h.Live, drain: true, status: http.StatusOK, want: "ok"},
+		{name: "ready while draining", handler: h.Ready, drain: true, status: http.StatusServiceUnavailable, want: "shutting down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.drain {
+				h.Drain()
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := decode[HealthStatus](t, rec); got.Status != tt.want {
+				t.Errorf("status = %q, want %q", got.Status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"mysql/model"
+	"mysql/respond"
+	"mysql/router"
+	"net/http"
+)
+
+// PermissionHandler 实现 ResourceHandler 接口
+type PermissionHandler struct {
+	DB *sql.DB
+}
+
+// Create 创建权限
+func (h *PermissionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var permission model.Permission
+	if err := decodeBody(r, &permission); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err := model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		_, err := permission.Create(ctx)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, permission)
+}
+
+// FindByID 查找权限
+func (h *PermissionHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	permission, err := model.FindPermissionByID(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, permission)
+}
+
+// List 分页查询权限：GET /permissions?page=&size=&sort=name&name=
+func (h *PermissionHandler) List(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, model.PermissionSortable, model.PermissionFilterable)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	page, err := model.ListPermissions(r.Context(), q)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, page)
+}
+
+// Update 更新权限，请求体必须带上读取到的 version
+func (h *PermissionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var permission model.Permission
+	if err := decodeBody(r, &permission); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	if err := bindPathID(r, &permission.Id); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	var updated *model.Permission
+	err := model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		if err := permission.Update(ctx); err != nil {
+			return err
+		}
+		var err error
+		updated, err = model.FindPermissionByID(ctx, permission.Id)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// Patch 部分更新权限：PATCH /permissions/{id}（补丁格式见 readPatch）
+func (h *PermissionHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	patch, err := readPatch(w, r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	var updated *model.Permission
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		updated, err = model.PatchPermission(ctx, id, patch)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// Delete 删除权限，同时从所有角色中收回
+func (h *PermissionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return model.DeletePermission(ctx, id)
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
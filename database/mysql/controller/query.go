@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"fmt"
+	"mysql/customerror"
+	"mysql/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 列表查询的保留参数，其余参数都视为过滤条件
+var reservedListParams = map[string]bool{"page": true, "size": true, "sort": true, "cursor": true, "withDeleted": true}
+
+/*
+parseListQuery 解析列表查询参数：?page=1&size=20&sort=name,-createdAt&username=meta
+1.sort 多个字段用逗号分隔，字段前加 - 表示降序
+2.带 cursor 参数（第一页传空值 cursor=）时使用游标分页，否则使用 page/size 偏移分页
+3.withDeleted=true 包含已软删除的数据，调用方需要校验权限
+4.排序和过滤字段必须在白名单中（JSON字段名 -> 列名），否则返回错误，防止SQL注入
+*/
+func parseListQuery(r *http.Request, sortable, filterable map[string]string) (model.ListQuery, error) {
+	params := r.URL.Query()
+	q := model.ListQuery{Filters: make(map[string]interface{})}
+
+	var err error
+	if v := params.Get("page"); v != "" {
+		if q.Page, err = strconv.Atoi(v); err != nil || q.Page < 1 {
+			return q, customerror.BadRequest(fmt.Sprintf("invalid page %q", v))
+		}
+	}
+	if v := params.Get("size"); v != "" {
+		if q.Size, err = strconv.Atoi(v); err != nil || q.Size < 1 || q.Size > model.MaxPageSize {
+			return q, customerror.BadRequest(fmt.Sprintf("invalid size %q, must be between 1 and %d", v, model.MaxPageSize))
+		}
+	}
+	if v := params.Get("sort"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			column, ok := sortable[field]
+			if !ok {
+				return q, customerror.BadRequest(fmt.Sprintf("cannot sort by %q", field))
+			}
+			q.Sort = append(q.Sort, model.SortField{Column: column, Desc: desc})
+		}
+	}
+	if v := params.Get("withDeleted"); v != "" {
+		if q.WithDeleted, err = strconv.ParseBool(v); err != nil {
+			return q, customerror.BadRequest(fmt.Sprintf("invalid withDeleted %q", v))
+		}
+	}
+	if params.Has("cursor") {
+		q.Keyset = true
+		q.Cursor = params.Get("cursor")
+	}
+
+	for name, values := range params {
+		if reservedListParams[name] {
+			continue
+		}
+		column, ok := filterable[name]
+		if !ok {
+			return q, customerror.BadRequest(fmt.Sprintf("cannot filter by %q", name))
+		}
+		q.Filters[column] = values[0]
+	}
+	return q, nil
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mysql/customerror"
+	"mysql/jsonpatch"
+	"mysql/router"
+	"mysql/validate"
+	"net/http"
+)
+
+// 请求体大小上限
+const maxBodySize = 1 << 20
+
+// mediaType 请求的 Content-Type（不含参数）
+func mediaType(r *http.Request) string {
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return t
+}
+
+// decodeBody 解析 JSON 请求体并按 validate 标签校验，返回的错误可以直接交给 respond.Error
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(v); err != nil {
+		return customerror.BadRequest("invalid request body: " + err.Error())
+	}
+	return validate.Struct(v)
+}
+
+var errIDMismatch = customerror.BadRequest("ID in body does not match the path")
+
+/*
+bindPathID PUT /xxx/{id}：请求体中的 id 可以省略（使用路径中的ID），填了必须与路径一致
+PUT /xxx（旧接口，路径中没有ID）时使用请求体中的 id
+*/
+func bindPathID(r *http.Request, id *int) error {
+	pathID, err := router.ID(r)
+	if errors.Is(err, router.ErrMissingID) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if *id != 0 && *id != pathID {
+		return errIDMismatch
+	}
+	*id = pathID
+	return nil
+}
+
+/*
+readPatch 读取 PATCH 请求体，返回应用补丁的函数（交给 model 的 PatchXxx）：
+Content-Type 为 application/json-patch+json 时按 JSON Patch（RFC 6902）处理，否则按 JSON Merge Patch（RFC 7396）处理
+补丁本身的错误（格式错误、test 失败、路径不存在）都转换为 400
+*/
+func readPatch(w http.ResponseWriter, r *http.Request) (func(doc []byte) ([]byte, error), error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return nil, customerror.BadRequest("invalid request body: " + err.Error())
+	}
+	apply := jsonpatch.MergePatch
+	if mediaType(r) == jsonpatch.JSONPatchType {
+		apply = jsonpatch.ApplyPatch
+	}
+	return func(doc []byte) ([]byte, error) {
+		patched, err := apply(doc, body)
+		if err != nil {
+			return nil, customerror.BadRequest(err.Error())
+		}
+		return patched, nil
+	}, nil
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"mysql/model"
+	"mysql/respond"
+	"mysql/router"
+	"net/http"
+)
+
+// RoleHandler 实现 ResourceHandler 接口
+type RoleHandler struct {
+	DB *sql.DB
+}
+
+// Create 创建角色
+func (h *RoleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var role model.Role
+	if err := decodeBody(r, &role); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err := model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		_, err := role.Create(ctx)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, role)
+}
+
+// FindByID 查找角色
+func (h *RoleHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	role, err := model.FindRoleByID(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, role)
+}
+
+// List 分页查询角色：GET /roles?page=&size=&sort=name&name=
+func (h *RoleHandler) List(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, model.RoleSortable, model.RoleFilterable)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	page, err := model.ListRoles(r.Context(), q)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, page)
+}
+
+// Update 更新角色，请求体必须带上读取到的 version；管理员角色不能改名
+func (h *RoleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var role model.Role
+	if err := decodeBody(r, &role); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	if err := bindPathID(r, &role.Id); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	var updated *model.Role
+	err := model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		if err := role.Update(ctx); err != nil {
+			return err
+		}
+		var err error
+		updated, err = model.FindRoleByID(ctx, role.Id)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// Patch 部分更新角色：PATCH /roles/{id}（补丁格式见 readPatch）
+func (h *RoleHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	patch, err := readPatch(w, r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	var updated *model.Role
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		updated, err = model.PatchRole(ctx, id, patch)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// Delete 删除角色，同时删除用户和权限的关联；管理员角色不能删除
+func (h *RoleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return model.DeleteRole(ctx, id)
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Permissions 角色的权限：GET /roles/{id}/permissions
+func (h *RoleHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	permissions, err := model.PermissionsOfRole(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, permissions)
+}
+
+// Grant 给角色授予权限：PUT /roles/{id}/permissions/{permissionId}，重复授予不报错
+func (h *RoleHandler) Grant(w http.ResponseWriter, r *http.Request) {
+	h.changePermission(w, r, model.GrantPermission)
+}
+
+// Revoke 收回角色的权限：DELETE /roles/{id}/permissions/{permissionId}
+func (h *RoleHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	h.changePermission(w, r, model.RevokePermission)
+}
+
+func (h *RoleHandler) changePermission(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, roleID, permissionID int) error) {
+	roleID, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	permissionID, err := router.IntParam(r, "permissionId")
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return change(ctx, roleID, permissionID)
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
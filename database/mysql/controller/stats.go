@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"database/sql"
+	"mysql/customerror"
+	"mysql/respond"
+	"net/http"
+)
+
+// PoolStats 连接池统计，对应 sql.DBStats
+type PoolStats struct {
+	MaxOpenConnections int `json:"maxOpenConnections"` //最大连接数
+	OpenConnections    int `json:"openConnections"`    //当前连接数（使用中+空闲）
+	InUse              int `json:"inUse"`              //使用中的连接数
+	Idle               int `json:"idle"`               //空闲连接数
+
+	WaitCount         int64   `json:"waitCount"`           //等待连接的总次数，持续增长说明连接池太小
+	WaitDuration      float64 `json:"waitDurationSeconds"` //等待连接的总时长（秒）
+	MaxIdleClosed     int64   `json:"maxIdleClosed"`       //因超过 MaxIdleConns 关闭的连接数
+	MaxIdleTimeClosed int64   `json:"maxIdleTimeClosed"`   //因超过 ConnMaxIdleTime 关闭的连接数
+	MaxLifetimeClosed int64   `json:"maxLifetimeClosed"`   //因超过 ConnMaxLifetime 关闭的连接数
+}
+
+// DBStats 返回每个数据库连接池的统计信息：GET /debug/db/stats
+func DBStats(dbs map[string]*sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respond.Error(w, r, customerror.NewError(customerror.CodeMethodNotAllowed, "method not allowed"))
+			return
+		}
+		stats := make(map[string]PoolStats, len(dbs))
+		for name, db := range dbs {
+			s := db.Stats()
+			stats[name] = PoolStats{
+				MaxOpenConnections: s.MaxOpenConnections,
+				OpenConnections:    s.OpenConnections,
+				InUse:              s.InUse,
+				Idle:               s.Idle,
+				WaitCount:          s.WaitCount,
+				WaitDuration:       s.WaitDuration.Seconds(),
+				MaxIdleClosed:      s.MaxIdleClosed,
+				MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+				MaxLifetimeClosed:  s.MaxLifetimeClosed,
+			}
+		}
+		respond.JSON(w, http.StatusOK, stats)
+	}
+}
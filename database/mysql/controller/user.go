@@ -1,133 +1,244 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"mysql/auth"
+	"mysql/customerror"
 	"mysql/model"
+	"mysql/replica"
+	"mysql/respond"
 	"mysql/router"
 	"net/http"
 )
 
+var (
+	errInvalidID     = customerror.BadRequest("invalid user ID")
+	errWrongPassword = customerror.NewError(customerror.CodeForbidden, "old password is incorrect")
+)
+
 // UserHandler 实现 ResourceHandler 接口
 type UserHandler struct {
 	DB *sql.DB
 }
 
-// Create 创建用户（包含事务控制）
+// createUserRequest 创建用户的请求体，model.User 的密码字段不参与JSON序列化，单独接收明文密码
+type createUserRequest struct {
+	model.User
+	Password string `json:"password" validate:"required,min=8,max=128"`
+}
+
+// Create 创建用户（事务见 model.WithTxDB）
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
-	//开启事务
-	tx, err := h.DB.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	//先解析和校验请求，不合法的请求不开启事务
+	var req createUserRequest
+	if err := decodeBody(r, &req); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	user := req.User
+	var err error
+	if user.Password, err = model.HashPassword(req.Password); err != nil {
+		respond.Error(w, r, err)
 		return
 	}
-	//报错回滚事务
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	//在事务中创建，返回错误时自动回滚，成功时提交（Create 会回填新增用户的Id值返回给前端）
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		_, err := user.Create(ctx)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
-	id, err := user.Create(tx) //返回新增的id值和错误
+	respond.JSON(w, http.StatusCreated, user)
+}
+
+// FindByID 查找用户
+func (h *UserHandler) FindByID(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respond.Error(w, r, errInvalidID)
 		return
 	}
 
-	//提交事务
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	user, err := model.FindByID(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
-	user.Id = int(id) //赋值新增用户的Id值返回给前端
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	respond.JSON(w, http.StatusOK, user)
 }
 
-// FindByID 查找用户
-func (h *UserHandler) FindByID(w http.ResponseWriter, r *http.Request) {
-	id, err := router.ExtractIDFromPath(r.URL.Path, "users")
+// List 分页查询用户：GET /users?page=&size=&sort=name,-createdAt&username=，管理员可以加 withDeleted=true 查询已删除的用户
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, model.UserSortable, model.UserFilterable)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		respond.Error(w, r, err)
+		return
+	}
+	if claims, ok := auth.ClaimsFromContext(r.Context()); q.WithDeleted && (!ok || !claims.HasRole(auth.AdminRole)) {
+		respond.Error(w, r, customerror.Forbidden)
 		return
 	}
 
-	user, err := model.FindByID(id)
+	page, err := model.ListUsers(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		respond.Error(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	respond.JSON(w, http.StatusOK, page)
 }
 
-// Update 更新用户（包含事务控制）
+// Update 更新用户（事务见 model.WithTxDB），请求体必须带上读取到的 version，被其他请求修改过时返回 409
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
-	tx, err := h.DB.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var user model.User
+	if err := decodeBody(r, &user); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	if err := bindPathID(r, &user.Id); err != nil {
+		respond.Error(w, r, err)
 		return
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	//更新后重新查询，返回数据库中的创建信息和新的版本号
+	var updated *model.User
+	err := model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		if err := user.Update(ctx); err != nil {
+			return err
+		}
+		var err error
+		updated, err = model.FindByID(ctx, user.Id)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
-	if err = user.Update(tx); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	respond.JSON(w, http.StatusOK, updated)
+}
+
+// Patch 部分更新用户：PATCH /users/{id}，只修改请求中提供的字段（补丁格式见 readPatch）
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, errInvalidID)
+		return
+	}
+	patch, err := readPatch(w, r)
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var updated *model.User
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		var err error
+		updated, err = model.PatchUser(ctx, id, patch)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	respond.JSON(w, http.StatusOK, updated)
 }
 
-// Delete 删除用户（包含事务控制）
+// Delete 删除用户（软删除，可以恢复，见 Restore；事务见 model.WithTxDB）
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	tx, err := h.DB.Begin()
+	id, err := router.ID(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respond.Error(w, r, errInvalidID)
 		return
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
-	id, err := router.ExtractIDFromPath(r.URL.Path, "users")
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return model.Delete(ctx, id)
+	})
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		respond.Error(w, r, err)
 		return
 	}
 
-	if err = model.Delete(tx, id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Restore 恢复已删除的用户：POST /users/{id}/restore
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, errInvalidID)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var restored *model.User
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		if err := model.Restore(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		restored, err = model.FindByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, restored)
+}
+
+// changePasswordRequest 修改密码的请求体
+type changePasswordRequest struct {
+	OldPassword string `json:"oldPassword" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=128"`
+}
+
+// ChangePassword 修改密码，只能修改自己的密码（管理员除外），必须提供正确的旧密码：POST /users/{id}/password
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := selfOrAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req changePasswordRequest
+	if err := decodeBody(r, &req); err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	//从主库读取当前密码，避免从库复制延迟时旧密码仍然有效
+	user, err := model.FindByID(replica.WithPrimary(r.Context()), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	match, _, err := model.VerifyPassword(user.Password, req.OldPassword)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+	if !match {
+		respond.Error(w, r, errWrongPassword)
+		return
+	}
+	hash, err := model.HashPassword(req.NewPassword)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return model.UpdatePassword(ctx, id, hash)
+	})
+	if err != nil {
+		respond.Error(w, r, err)
 		return
 	}
 
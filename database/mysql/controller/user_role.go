@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"mysql/auth"
+	"mysql/customerror"
+	"mysql/model"
+	"mysql/respond"
+	"mysql/router"
+	"net/http"
+)
+
+// Roles 用户的角色：GET /users/{id}/roles，只能查询自己的（管理员除外）
+func (h *UserHandler) Roles(w http.ResponseWriter, r *http.Request) {
+	id, ok := selfOrAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := model.RolesOfUser(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, roles)
+}
+
+// Permissions 用户的有效权限（所有角色的权限去重）：GET /users/{id}/permissions，只能查询自己的（管理员除外）
+func (h *UserHandler) Permissions(w http.ResponseWriter, r *http.Request) {
+	id, ok := selfOrAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	permissions, err := model.UserPermissions(r.Context(), id)
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, permissions)
+}
+
+// AssignRole 给用户分配角色：PUT /users/{id}/roles/{roleId}，重复分配不报错，新角色在用户重新登录后生效
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, model.AssignRole)
+}
+
+// UnassignRole 取消用户的角色：DELETE /users/{id}/roles/{roleId}，已签发的令牌在过期前仍然带有该角色
+func (h *UserHandler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, model.UnassignRole)
+}
+
+func (h *UserHandler) changeRole(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID, roleID int) error) {
+	userID, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, errInvalidID)
+		return
+	}
+	roleID, err := router.IntParam(r, "roleId")
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	err = model.WithTxDB(r.Context(), h.DB, nil, func(ctx context.Context) error {
+		return change(ctx, userID, roleID)
+	})
+	if err != nil {
+		respond.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// selfOrAdmin 读取路径中的用户ID，当前用户不是该用户也不是管理员时返回 403
+func selfOrAdmin(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := router.ID(r)
+	if err != nil {
+		respond.Error(w, r, errInvalidID)
+		return 0, false
+	}
+	if claims, ok := auth.ClaimsFromContext(r.Context()); !ok || (claims.UserID != id && !claims.HasRole(auth.AdminRole)) {
+		respond.Error(w, r, customerror.Forbidden)
+		return 0, false
+	}
+	return id, true
+}
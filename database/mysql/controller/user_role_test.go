@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"mysql/auth"
+	"mysql/model"
+	"mysql/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const userExists = "SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL"
+
+func TestUserHandler_Roles(t *testing.T) {
+	self := &auth.Claims{UserID: 1, Username: "alice"}
+	runCases(t, http.MethodGet, func(h *UserHandler) http.HandlerFunc { return h.Roles }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/roles",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(userExists).WithArgs(1).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectQuery("FROM roles r JOIN user_roles ur ON ur.role_id = r.id WHERE ur.user_id = ? ORDER BY r.name").
+					WithArgs(1).
+					WillReturnRows(sqltest.NewRows("id", "created_at", "created_by", "update_at", "update_by", "name", "description", "version").
+						AddRow(2, testTime, "system", testTime, "system", "user", "普通用户", 1))
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if roles := decode[[]model.Role](t, rec); len(roles) != 1 || roles[0].Name != "user" {
+					t.Errorf("roles = %+v", roles)
+				}
+			},
+		},
+		{
+			name:   "not found",
+			target: "/users/1/roles",
+			params: map[string]string{"id": "1"},
+			claims: admin,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(userExists).WillReturnRows(sqltest.NewRows("1"))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "other user",
+			target: "/users/2/roles",
+			params: map[string]string{"id": "2"},
+			claims: self,
+			status: http.StatusForbidden,
+		},
+	})
+}
+
+func TestUserHandler_Permissions(t *testing.T) {
+	runCases(t, http.MethodGet, func(h *UserHandler) http.HandlerFunc { return h.Permissions }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/permissions",
+			params: map[string]string{"id": "1"},
+			claims: admin,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(userExists).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectQuery("SELECT DISTINCT p.id").WithArgs(1).
+					WillReturnRows(sqltest.NewRows("id", "created_at", "created_by", "update_at", "update_by", "name", "description", "version").
+						AddRow(1, testTime, "system", testTime, "system", "users:read", "", 1))
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if permissions := decode[[]model.Permission](t, rec); len(permissions) != 1 || permissions[0].Name != "users:read" {
+					t.Errorf("permissions = %+v", permissions)
+				}
+			},
+		},
+		{
+			name:   "not found",
+			target: "/users/1/permissions",
+			params: map[string]string{"id": "1"},
+			claims: admin,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(userExists).WillReturnRows(sqltest.NewRows("1"))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "not logged in",
+			target: "/users/1/permissions",
+			params: map[string]string{"id": "1"},
+			status: http.StatusForbidden,
+		},
+	})
+}
+
+func TestUserHandler_AssignRole(t *testing.T) {
+	runCases(t, http.MethodPut, func(h *UserHandler) http.HandlerFunc { return h.AssignRole }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/roles/2",
+			params: map[string]string{"id": "1", "roleId": "2"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(userExists).WithArgs(1).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectQuery("SELECT 1 FROM roles WHERE id = ?").WithArgs(2).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectExec("INSERT IGNORE INTO user_roles").WithArgs(1, 2, sqltest.Any, "system").WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "role not found rolls back",
+			target: "/users/1/roles/9",
+			params: map[string]string{"id": "1", "roleId": "9"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(userExists).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectQuery("SELECT 1 FROM roles WHERE id = ?").WithArgs(9).WillReturnRows(sqltest.NewRows("1"))
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid role id",
+			target: "/users/1/roles/x",
+			params: map[string]string{"id": "1", "roleId": "x"},
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestUserHandler_UnassignRole(t *testing.T) {
+	unassign := "DELETE FROM user_roles WHERE user_id = ? AND role_id = ?"
+	runCases(t, http.MethodDelete, func(h *UserHandler) http.HandlerFunc { return h.UnassignRole }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/roles/2",
+			params: map[string]string{"id": "1", "roleId": "2"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(unassign).WithArgs(1, 2).WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "not assigned rolls back",
+			target: "/users/1/roles/2",
+			params: map[string]string{"id": "1", "roleId": "2"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(unassign).WillReturnResult(0, 0)
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "missing role id",
+			target: "/users/1/roles/",
+			params: map[string]string{"id": "1"},
+			status: http.StatusBadRequest,
+		},
+	})
+}
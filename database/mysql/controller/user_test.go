@@ -0,0 +1,691 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"mysql/auth"
+	"mysql/config"
+	"mysql/customerror"
+	"mysql/model"
+	"mysql/respond"
+	"mysql/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// 测试中使用最低的哈希参数，argon2id 默认参数每次要几十毫秒、64MB 内存
+	model.DefaultPasswordParams = model.PasswordParams{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	os.Exit(m.Run())
+}
+
+// userColumns users 表的列，顺序与 model.User 的 db 标签一致
+var userColumns = []string{"id", "created_at", "created_by", "update_at", "update_by", "username", "password", "name", "version", "deleted_at"}
+
+const selectUser = "SELECT id, created_at, created_by, update_at, update_by, username, password, name, version, deleted_at FROM users WHERE id = ? AND deleted_at IS NULL LIMIT 1"
+
+var (
+	testTime  = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	admin     = &auth.Claims{UserID: 100, Username: "root", Roles: []string{auth.AdminRole}}
+	errDup    = &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice' for key 'users.username'"}
+	errDBDown = errors.New("connection refused")
+)
+
+// userRows users 表的结果集
+func userRows(users ...model.User) *sqltest.Rows {
+	rows := sqltest.NewRows(userColumns...)
+	for _, u := range users {
+		rows.AddRow(u.Id, u.CreatedAt, u.CreatedBy, u.UpdateAt, u.UpdateBy, u.Username, u.Password, u.Name, u.Version, u.DeletedAt)
+	}
+	return rows
+}
+
+func alice() model.User {
+	return model.User{Id: 1, CreatedAt: testTime, CreatedBy: "system", UpdateAt: testTime, UpdateBy: "system",
+		Username: "alice", Password: "hash", Name: "Alice", Version: 1}
+}
+
+// handlerCase 一个处理函数的测试用例
+type handlerCase struct {
+	name   string
+	target string            //请求路径和查询参数
+	params map[string]string //路径参数
+	body   string
+	header map[string]string
+	claims *auth.Claims //当前登录用户，nil 表示未登录
+	expect func(db *sqltest.DB)
+	status int
+	check  func(t *testing.T, rec *httptest.ResponseRecorder)
+}
+
+/*
+runCases 对每个用例创建新的内存数据库并替换 config.DB（model 在事务外使用它），
+编排预期的语句后调用处理函数，检查状态码；未执行的预期在用例结束时报错
+*/
+func runCases(t *testing.T, method string, handle func(h *UserHandler) http.HandlerFunc, cases []handlerCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := sqltest.New(t)
+			old := config.DB
+			config.DB = db.DB
+			t.Cleanup(func() { config.DB = old })
+			if tc.expect != nil {
+				tc.expect(db)
+			}
+
+			r := httptest.NewRequest(method, tc.target, strings.NewReader(tc.body))
+			for k, v := range tc.params {
+				r.SetPathValue(k, v)
+			}
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			if tc.claims != nil {
+				r = r.WithContext(auth.WithClaims(r.Context(), tc.claims))
+			}
+			rec := httptest.NewRecorder()
+			handle(&UserHandler{DB: db.DB})(rec, r)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.check != nil {
+				tc.check(t, rec)
+			}
+		})
+	}
+}
+
+// decode 解析 JSON 响应体
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body, err)
+	}
+	return v
+}
+
+// wantCode 检查错误响应的错误码
+func wantCode(code customerror.Code) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		if body := decode[respond.ErrorBody](t, rec); body.Code != code {
+			t.Errorf("error code = %q, want %q (%s)", body.Code, code, body.Message)
+		}
+	}
+}
+
+// wantUser 检查响应中的用户
+func wantUser(id int, name string, version int) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		u := decode[map[string]any](t, rec)
+		if u["id"] != float64(id) || u["name"] != name || u["version"] != float64(version) {
+			t.Errorf("user = %v, want id %d, name %q, version %d", u, id, name, version)
+		}
+		if _, ok := u["password"]; ok {
+			t.Error("response must not contain the password")
+		}
+	}
+}
+
+func TestUserHandler_Create(t *testing.T) {
+	insert := "INSERT INTO users (created_at, created_by, update_at, update_by, username, password, name, version, deleted_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	runCases(t, http.MethodPost, func(h *UserHandler) http.HandlerFunc { return h.Create }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users",
+			body:   `{"username":"alice","name":"Alice","password":"secret-password"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(insert).
+					WithArgs(sqltest.Any, "system", sqltest.Any, "system", "alice", sqltest.Any, "Alice", 1, nil).
+					WillReturnResult(7, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusCreated,
+			check:  wantUser(7, "Alice", 1),
+		},
+		{
+			name:   "validation",
+			target: "/users",
+			body:   `{"username":"a!","name":"","password":"short"}`,
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				body := decode[respond.ErrorBody](t, rec)
+				if body.Code != customerror.CodeValidation {
+					t.Errorf("error code = %q, want %q", body.Code, customerror.CodeValidation)
+				}
+				if fields, _ := body.Details.([]any); len(fields) != 3 {
+					t.Errorf("details = %v, want 3 field errors", body.Details)
+				}
+			},
+		},
+		{
+			name:   "invalid json",
+			target: "/users",
+			body:   `{"username":`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "duplicate username rolls back",
+			target: "/users",
+			body:   `{"username":"alice","name":"Alice","password":"secret-password"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(insert).WillReturnError(errDup)
+				db.ExpectRollback()
+			},
+			status: http.StatusConflict,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				// 只返回 AppError 自身的信息，不包括包装的哨兵错误和驱动错误
+				if body := decode[respond.ErrorBody](t, rec); body.Message != `username "alice" already exists` {
+					t.Errorf("message = %q", body.Message)
+				}
+			},
+		},
+		{
+			name:   "commit failure",
+			target: "/users",
+			body:   `{"username":"alice","name":"Alice","password":"secret-password"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(insert).WillReturnResult(7, 1)
+				db.ExpectCommit().WillReturnError(errDBDown)
+			},
+			status: http.StatusInternalServerError,
+			check:  wantCode(customerror.CodeInternal),
+		},
+	})
+}
+
+func TestUserHandler_FindByID(t *testing.T) {
+	runCases(t, http.MethodGet, func(h *UserHandler) http.HandlerFunc { return h.FindByID }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(alice()))
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Alice", 1),
+		},
+		{
+			name:   "not found",
+			target: "/users/2",
+			params: map[string]string{"id": "2"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WithArgs(2).WillReturnRows(userRows())
+			},
+			status: http.StatusNotFound,
+			check:  wantCode(customerror.CodeNotFound),
+		},
+		{
+			name:   "invalid id",
+			target: "/users/abc",
+			params: map[string]string{"id": "abc"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "database error",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WillReturnError(errDBDown)
+			},
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if strings.Contains(rec.Body.String(), errDBDown.Error()) {
+					t.Errorf("response leaks the database error: %s", rec.Body)
+				}
+			},
+		},
+	})
+}
+
+func TestUserHandler_List(t *testing.T) {
+	runCases(t, http.MethodGet, func(h *UserHandler) http.HandlerFunc { return h.List }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users?username=alice&sort=-name",
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery("SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND username = ?").
+					WithArgs("alice").
+					WillReturnRows(sqltest.NewRows("count").AddRow(1))
+				db.ExpectQuery("FROM users WHERE deleted_at IS NULL AND username = ? ORDER BY name DESC, id ASC LIMIT ? OFFSET ?").
+					WithArgs("alice", model.DefaultPageSize, 0).
+					WillReturnRows(userRows(alice()))
+			},
+			status: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				page := decode[model.Page[model.User]](t, rec)
+				if page.Total != 1 || len(page.Items) != 1 || page.Items[0].Username != "alice" {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name:   "admin with deleted",
+			target: "/users?withDeleted=true&page=2&size=10",
+			claims: admin,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery("SELECT COUNT(*) FROM users").WithArgs().WillReturnRows(sqltest.NewRows("count").AddRow(11))
+				db.ExpectQuery("FROM users ORDER BY id ASC LIMIT ? OFFSET ?").WithArgs(10, 10).WillReturnRows(userRows(alice()))
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "invalid size",
+			target: "/users?size=1000",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown sort field",
+			target: "/users?sort=password",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "with deleted requires admin",
+			target: "/users?withDeleted=true",
+			claims: &auth.Claims{UserID: 1, Username: "alice"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "database error",
+			target: "/users",
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery("SELECT COUNT(*) FROM users").WillReturnError(errDBDown)
+			},
+			status: http.StatusInternalServerError,
+		},
+	})
+}
+
+func TestUserHandler_Update(t *testing.T) {
+	update := "UPDATE users SET update_at = ?, update_by = ?, username = ?, name = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND version = ?"
+	exists := "SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL"
+	body := `{"username":"alice","name":"Alice Liddell","version":1}`
+	updated := alice()
+	updated.Name, updated.Version = "Alice Liddell", 2
+	runCases(t, http.MethodPut, func(h *UserHandler) http.HandlerFunc { return h.Update }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WithArgs(sqltest.Any, "system", "alice", "Alice Liddell", 1, 1).WillReturnResult(0, 1)
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(updated))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Alice Liddell", 2),
+		},
+		{
+			name:   "validation",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"username":"","name":"Alice","version":1}`,
+			status: http.StatusBadRequest,
+			check:  wantCode(customerror.CodeValidation),
+		},
+		{
+			name:   "id mismatch",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"id":2,"username":"alice","name":"Alice","version":1}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "not found rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnResult(0, 0)
+				db.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqltest.NewRows("1"))
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "stale version rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnResult(0, 0)
+				db.ExpectQuery(exists).WithArgs(1).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectRollback()
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "duplicate username rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnError(errDup)
+				db.ExpectRollback()
+			},
+			status: http.StatusConflict,
+		},
+	})
+}
+
+func TestUserHandler_Patch(t *testing.T) {
+	update := "UPDATE users SET name = ?, update_at = ?, update_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND version = ?"
+	patched := alice()
+	patched.Name, patched.Version = "Bob", 2
+	// 连接参数 loc 不是 UTC 时读出的时间带有该时区，JSON 往返后 Location 不同但时刻相同
+	cst := alice()
+	cst.CreatedAt = testTime.In(time.FixedZone("CST", 8*3600))
+	cst.UpdateAt = cst.CreatedAt
+	runCases(t, http.MethodPatch, func(h *UserHandler) http.HandlerFunc { return h.Patch }, []handlerCase{
+		{
+			name:   "merge patch",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"name":"Bob"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(alice()))
+				db.ExpectExec(update).WithArgs("Bob", sqltest.Any, "system", 1, 1).WillReturnResult(0, 1)
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(patched))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Bob", 2),
+		},
+		{
+			name:   "json patch",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			header: map[string]string{"Content-Type": "application/json-patch+json"},
+			body:   `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/name","value":"Bob"}]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(alice()))
+				db.ExpectExec(update).WithArgs("Bob", sqltest.Any, "system", 1, 1).WillReturnResult(0, 1)
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(patched))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Bob", 2),
+		},
+		{
+			name:   "non-UTC timestamps are not changed",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"name":"Bob"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(cst))
+				db.ExpectExec(update).WithArgs("Bob", sqltest.Any, "system", 1, 1).WillReturnResult(0, 1)
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(patched))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Bob", 2),
+		},
+		{
+			name:   "no changes with non-UTC timestamps",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"name":"Alice"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(cst))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Alice", 1),
+		},
+		{
+			name:   "read-only field rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"createdAt":"2000-01-01T00:00:00Z"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(cst))
+				db.ExpectRollback()
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "validation rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			body:   `{"name":""}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(alice()))
+				db.ExpectRollback()
+			},
+			status: http.StatusBadRequest,
+			check:  wantCode(customerror.CodeValidation),
+		},
+		{
+			name:   "failed test operation rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			header: map[string]string{"Content-Type": "application/json-patch+json"},
+			body:   `[{"op":"test","path":"/version","value":5}]`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(alice()))
+				db.ExpectRollback()
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "not found rolls back",
+			target: "/users/2",
+			params: map[string]string{"id": "2"},
+			body:   `{"name":"Bob"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs(2).WillReturnRows(userRows())
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			target: "/users/0",
+			params: map[string]string{"id": "0"},
+			body:   `{"name":"Bob"}`,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestUserHandler_Delete(t *testing.T) {
+	softDelete := "UPDATE users SET deleted_at = ?, update_at = ?, update_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	runCases(t, http.MethodDelete, func(h *UserHandler) http.HandlerFunc { return h.Delete }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(softDelete).WithArgs(sqltest.Any, sqltest.Any, "system", 1).WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "not found rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(softDelete).WillReturnResult(0, 0)
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			target: "/users/x",
+			params: map[string]string{"id": "x"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "database error rolls back",
+			target: "/users/1",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(softDelete).WillReturnError(errDBDown)
+				db.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+		},
+	})
+}
+
+func TestUserHandler_Restore(t *testing.T) {
+	restore := "UPDATE users SET deleted_at = NULL, update_at = ?, update_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL"
+	runCases(t, http.MethodPost, func(h *UserHandler) http.HandlerFunc { return h.Restore }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/restore",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(restore).WithArgs(sqltest.Any, "system", 1).WillReturnResult(0, 1)
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(alice()))
+				db.ExpectCommit()
+			},
+			status: http.StatusOK,
+			check:  wantUser(1, "Alice", 1),
+		},
+		{
+			name:   "not deleted rolls back",
+			target: "/users/1/restore",
+			params: map[string]string{"id": "1"},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec(restore).WillReturnResult(0, 0)
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			target: "/users/-1/restore",
+			params: map[string]string{"id": "-1"},
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	hash, err := model.HashPassword("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := alice()
+	current.Password = hash
+	update := "UPDATE users SET password = ?, update_at = ?, update_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	self := &auth.Claims{UserID: 1, Username: "alice"}
+	body := `{"oldPassword":"old-password","newPassword":"new-password"}`
+	runCases(t, http.MethodPost, func(h *UserHandler) http.HandlerFunc { return h.ChangePassword }, []handlerCase{
+		{
+			name:   "success",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WithArgs(1).WillReturnRows(userRows(current))
+				db.ExpectBegin()
+				db.ExpectExec(update).WithArgs(sqltest.Any, sqltest.Any, "system", 1).WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "admin changes another user's password",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: admin,
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(current))
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "wrong old password",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			body:   `{"oldPassword":"guess","newPassword":"new-password"}`,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(current))
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "validation",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			body:   `{"oldPassword":"old-password","newPassword":"short"}`,
+			status: http.StatusBadRequest,
+			check:  wantCode(customerror.CodeValidation),
+		},
+		{
+			name:   "other user",
+			target: "/users/2/password",
+			params: map[string]string{"id": "2"},
+			claims: self,
+			body:   body,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "not found",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WillReturnRows(userRows())
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "deleted meanwhile rolls back",
+			target: "/users/1/password",
+			params: map[string]string{"id": "1"},
+			claims: self,
+			body:   body,
+			expect: func(db *sqltest.DB) {
+				db.ExpectQuery(selectUser).WillReturnRows(userRows(current))
+				db.ExpectBegin()
+				db.ExpectExec(update).WillReturnResult(0, 0)
+				db.ExpectRollback()
+			},
+			status: http.StatusNotFound,
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package customerror
+
+import "net/http"
+
+// Code 应用错误码，返回给前端用于区分错误类型
+type Code string
+
+const (
+	CodeBadRequest       Code = "BAD_REQUEST"
+	CodeValidation       Code = "VALIDATION_FAILED"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeConflict         Code = "CONFLICT"
+	CodeInternal         Code = "INTERNAL"
+)
+
+// Status 错误码对应的HTTP状态码
+func (c Code) Status() int {
+	switch c {
+	case CodeBadRequest, CodeValidation:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeConflict:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+/*
+AppError 带错误码的应用错误
+1.可以作为哨兵错误：var ErrXxx = customerror.NotFound("xxx not found")，用 errors.Is 判断
+2.可以用 fmt.Errorf("%w: ...", ErrXxx) 补充信息，errors.As 仍然能取到错误码；补充的信息只记录在日志中，不返回给前端
+3.Err 保存底层错误（不返回给前端，只用于日志）
+*/
+type AppError struct {
+	Code    Code
+	Message string
+	Details interface{} //附加信息，例如校验失败的字段列表
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// NewError 构造应用错误
+func NewError(code Code, message string) *AppError {
+	return &AppError{Code: code, Message: message}
+}
+
+// BadRequest 请求参数错误
+func BadRequest(message string) *AppError {
+	return NewError(CodeBadRequest, message)
+}
+
+// Validation 请求体校验失败，details 为字段错误列表
+func Validation(details interface{}) *AppError {
+	return &AppError{Code: CodeValidation, Message: "validation failed", Details: details}
+}
+
+// NotFound 资源不存在
+func NotFound(message string) *AppError {
+	return NewError(CodeNotFound, message)
+}
+
+// Conflict 与当前状态冲突（唯一约束、并发修改）
+func Conflict(message string) *AppError {
+	return NewError(CodeConflict, message)
+}
+
+// Internal 内部错误，err 只记录日志，不返回给前端
+func Internal(err error) *AppError {
+	return &AppError{Code: CodeInternal, Message: "internal server error", Err: err}
+}
@@ -2,7 +2,12 @@ module mysql
 
 go 1.24.1
 
+require (
+	github.com/go-sql-driver/mysql v1.9.1
+	golang.org/x/crypto v0.40.0
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.9.1 // indirect
+	golang.org/x/sys v0.34.0 // indirect
 )
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"mysql/model"
+	"mysql/validate"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importUser 导入文件中的一行，字段和校验规则与创建用户的请求体相同
+type importUser struct {
+	model.User
+	Password string `json:"password" validate:"required,min=8,max=128"`
+}
+
+// importRow 解析后的一行，err 不为空时表示该行格式错误
+type importRow struct {
+	user importUser
+	err  error
+}
+
+/*
+runImport 从 HR 系统导出的文件导入用户：go run . import [-format csv|ndjson] [-batch 500] [-upsert] users.csv
+1.CSV 第一行为表头，必须包含 username、name、password 列（顺序任意，其余列忽略）；NDJSON 每行一个 JSON 对象
+2.逐行解析和校验，有问题的行打印行号和原因后跳过
+3.每 batch 行在一个事务中提交（见 model.CreateUsers），某一批失败不影响已经提交的批次
+4.-upsert 时用户名已存在则更新姓名，否则该行失败
+*/
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "文件格式 csv|ndjson，默认按扩展名判断")
+	batch := fs.Int("batch", model.DefaultBulkChunkSize, "每个事务提交的行数")
+	upsert := fs.Bool("upsert", false, "用户名已存在时更新")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-format csv|ndjson] [-batch 500] [-upsert] FILE")
+	}
+	if *batch < 1 || *batch > model.MaxBulkChunkSize {
+		return fmt.Errorf("batch must be between 1 and %d", model.MaxBulkChunkSize)
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = "csv"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".ndjson" || ext == ".jsonl" {
+			*format = "ndjson"
+		}
+	}
+	if *format != "csv" && *format != "ndjson" {
+		return fmt.Errorf("unknown format %q, want csv or ndjson", *format)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	var imported, failed int
+	var lines []int
+	var users []importUser
+	commit := func() error {
+		if len(users) == 0 {
+			return nil
+		}
+		n, err := importBatch(ctx, lines, users, *upsert)
+		if err != nil {
+			return fmt.Errorf("lines %d-%d: %w (%d rows imported before)", lines[0], lines[len(lines)-1], err, imported)
+		}
+		imported += n
+		failed += len(users) - n
+		lines, users = lines[:0], users[:0]
+		return nil
+	}
+
+	for line, row := range readImport(f, *format) {
+		if row.err == nil {
+			row.err = validate.Struct(&row.user)
+		}
+		if row.err != nil {
+			log.Printf("第 %d 行：%v", line, row.err)
+			failed++
+			continue
+		}
+		lines = append(lines, line)
+		users = append(users, row.user)
+		if len(users) == *batch {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+
+	log.Printf("导入完成：成功 %d 行，失败 %d 行", imported, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d rows failed", failed)
+	}
+	return nil
+}
+
+// importBatch 在一个事务中导入一批用户，返回成功的行数，失败的行打印行号和原因
+func importBatch(ctx context.Context, lines []int, rows []importUser, upsert bool) (int, error) {
+	passwords := make([]string, len(rows))
+	for i := range rows {
+		passwords[i] = rows[i].Password
+	}
+	hashes, err := model.HashPasswords(ctx, passwords)
+	if err != nil {
+		return 0, err
+	}
+	users := make([]*model.User, len(rows))
+	for i := range rows {
+		user := rows[i].User
+		user.Password = hashes[i]
+		users[i] = &user
+	}
+
+	var results []model.BulkResult
+	err = model.WithTx(ctx, nil, func(ctx context.Context) error {
+		var err error
+		results, err = model.CreateUsers(ctx, users, model.BulkOptions{ChunkSize: len(users), Upsert: upsert})
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, result := range results {
+		if result.Status == model.BulkFailed {
+			log.Printf("第 %d 行：%v", lines[result.Index], result.Err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+/*
+readImport 逐行读取导入文件，yield(行号, 解析结果)，行号从 1 开始（CSV 的表头是第 1 行）
+单行格式错误时继续读取下一行；文件本身读取失败（或 CSV 缺少必需的列）时 yield 一次错误后结束
+*/
+func readImport(r io.Reader, format string) iter.Seq2[int, importRow] {
+	if format == "ndjson" {
+		return readNDJSON(r)
+	}
+	return readCSV(r)
+}
+
+func readNDJSON(r io.Reader) iter.Seq2[int, importRow] {
+	return func(yield func(int, importRow) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var row importRow
+			row.err = json.Unmarshal(scanner.Bytes(), &row.user)
+			if !yield(line, row) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(line+1, importRow{err: err})
+		}
+	}
+}
+
+func readCSV(r io.Reader) iter.Seq2[int, importRow] {
+	return func(yield func(int, importRow) bool) {
+		cr := csv.NewReader(r)
+		cr.TrimLeadingSpace = true
+		header, err := cr.Read()
+		if err != nil {
+			yield(1, importRow{err: fmt.Errorf("read header: %w", err)})
+			return
+		}
+		index := make(map[string]int, len(header))
+		for i, name := range header {
+			index[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, name := range []string{"username", "name", "password"} {
+			if _, ok := index[name]; !ok {
+				yield(1, importRow{err: fmt.Errorf("missing column %q in header", name)})
+				return
+			}
+		}
+
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			var line int
+			var row importRow
+			var parseErr *csv.ParseError
+			switch {
+			case errors.As(err, &parseErr):
+				line, row.err = parseErr.Line, parseErr.Err
+			case err != nil:
+				yield(line, importRow{err: err})
+				return
+			default:
+				line, _ = cr.FieldPos(0)
+				row.user.Username = record[index["username"]]
+				row.user.Name = record[index["name"]]
+				row.user.Password = record[index["password"]]
+			}
+			if !yield(line, row) {
+				return
+			}
+		}
+	}
+}
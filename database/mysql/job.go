@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"mysql/model"
+	"time"
+)
+
+// 软删除数据的保留时间和清理间隔
+const (
+	deletedRetention = 30 * 24 * time.Hour
+	purgeInterval    = time.Hour
+)
+
+// startPurgeJob 定时物理删除超过保留时间的软删除数据，ctx 取消时停止；返回的通道在任务退出（当前批次完成）后关闭
+func startPurgeJob(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for {
+			n, err := model.PurgeDeletedUsers(ctx, deletedRetention)
+			if err != nil {
+				log.Printf("清理已删除用户失败：%v", err)
+			} else if n > 0 {
+				log.Printf("清理了 %d 个已删除超过 %s 的用户", n, deletedRetention)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,95 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const doc = `{"name":"Meta","tags":["a","b"],"profile":{"age":18,"a/b":1,"m~n":2}}`
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{name: "add field", patch: `[{"op":"add","path":"/email","value":"m@example.com"}]`,
+			want: `{"email":"m@example.com","name":"Meta","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b"]}`},
+		{name: "add replaces existing field", patch: `[{"op":"add","path":"/name","value":"X"}]`,
+			want: `{"name":"X","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b"]}`},
+		{name: "insert into array", patch: `[{"op":"add","path":"/tags/1","value":"x"}]`,
+			want: `{"name":"Meta","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","x","b"]}`},
+		{name: "append to array", patch: `[{"op":"add","path":"/tags/-","value":"c"}]`,
+			want: `{"name":"Meta","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b","c"]}`},
+		{name: "remove array element", patch: `[{"op":"remove","path":"/tags/0"}]`,
+			want: `{"name":"Meta","profile":{"a/b":1,"age":18,"m~n":2},"tags":["b"]}`},
+		{name: "replace with escaped pointer", patch: `[{"op":"replace","path":"/profile/a~1b","value":3},{"op":"remove","path":"/profile/m~0n"}]`,
+			want: `{"name":"Meta","profile":{"a/b":3,"age":18},"tags":["a","b"]}`},
+		{name: "move", patch: `[{"op":"move","from":"/profile/age","path":"/age"}]`,
+			want: `{"age":18,"name":"Meta","profile":{"a/b":1,"m~n":2},"tags":["a","b"]}`},
+		{name: "copy is deep", patch: `[{"op":"copy","from":"/tags","path":"/old"},{"op":"add","path":"/tags/-","value":"c"}]`,
+			want: `{"name":"Meta","old":["a","b"],"profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b","c"]}`},
+		{name: "test passes", patch: `[{"op":"test","path":"/profile/age","value":18},{"op":"replace","path":"/name","value":"Y"}]`,
+			want: `{"name":"Y","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b"]}`},
+		{name: "replace whole document", patch: `[{"op":"replace","path":"","value":{"id":1}}]`, want: `{"id":1}`},
+		{name: "large integers keep precision", patch: `[{"op":"add","path":"/id","value":9007199254740993}]`,
+			want: `{"id":9007199254740993,"name":"Meta","profile":{"a/b":1,"age":18,"m~n":2},"tags":["a","b"]}`},
+
+		{name: "test fails", patch: `[{"op":"test","path":"/name","value":"other"}]`, wantErr: ErrTestFailed},
+		{name: "remove missing field", patch: `[{"op":"remove","path":"/missing"}]`, wantErr: ErrPathNotFound},
+		{name: "index out of range", patch: `[{"op":"add","path":"/tags/3","value":"x"}]`, wantErr: ErrPathNotFound},
+		{name: "leading zero index", patch: `[{"op":"remove","path":"/tags/01"}]`, wantErr: ErrPathNotFound},
+		{name: "move into own child", patch: `[{"op":"move","from":"/profile","path":"/profile/x"}]`, wantErr: ErrInvalidPatch},
+		{name: "missing value", patch: `[{"op":"add","path":"/x"}]`, wantErr: ErrInvalidPatch},
+		{name: "unknown op", patch: `[{"op":"merge","path":"/x","value":1}]`, wantErr: ErrInvalidPatch},
+		{name: "path without slash", patch: `[{"op":"remove","path":"name"}]`, wantErr: ErrInvalidPatch},
+		{name: "not an array", patch: `{"op":"remove","path":"/name"}`, wantErr: ErrInvalidPatch},
+		// 后面的操作失败时，前面的操作也不生效
+		{name: "atomic", patch: `[{"op":"remove","path":"/name"},{"op":"test","path":"/name","value":"Meta"}]`, wantErr: ErrPathNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyPatch([]byte(doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ApplyPatch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyPatch() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ApplyPatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":["c","d"]}`, want: `{"a":["c","d"]}`},
+		{doc: `{"a":{"b":"c","d":"e"}}`, patch: `{"a":{"d":null,"f":"g"}}`, want: `{"a":{"b":"c","f":"g"}}`},
+		{doc: `{"a":"b"}`, patch: `{"a":{"c":null}}`, want: `{"a":{}}`},
+		{doc: `["a"]`, patch: `{"a":"b"}`, want: `{"a":"b"}`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+	}
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s) error = %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("MergePatch with broken patch = %v, want ErrInvalidPatch", err)
+	}
+}
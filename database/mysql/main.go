@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"mysql/auth"
 	"mysql/config"
 	"mysql/controller"
+	"mysql/migrate"
+	"mysql/model"
+	"mysql/respond"
 	"mysql/router"
+	"mysql/sqltrace"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 /*
@@ -23,14 +33,162 @@ func main() {
 		fmt.Printf("init db failed,err:%v\n", err)
 		return
 	}
-	defer config.DB.Close()
-	// 注册用户资源
-	router.RegisterResource("users", &controller.UserHandler{DB: config.DB})
+	defer config.CloseDB()
 
-	// 后续添加其他资源（例如角色）
-	// RegisterResource("roles", &controller.RoleHandler{})
+	// 数据库迁移：go run . migrate up|down|status|redo，不带参数启动服务时自动执行 up
+	migrator, err := migrate.New(config.DB)
+	if err != nil {
+		fmt.Printf("load migrations failed,err:%v\n", err)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(migrator, os.Args[2:]); err != nil {
+			fmt.Printf("migrate failed,err:%v\n", err)
+		}
+		return
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		fmt.Printf("migrate failed,err:%v\n", err)
+		return
+	}
+	// 导入用户：go run . import [-format csv|ndjson] [-batch 500] [-upsert] users.csv
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Printf("import failed,err:%v\n", err)
+		}
+		return
+	}
+
+	// 指定管理员：go run . grant-admin alice。新库没有管理员，而创建用户等写操作都需要管理员，
+	// 所以第一个管理员需要先用 import 命令导入用户，再用这个命令分配 admin 角色
+	if len(os.Args) > 1 && os.Args[1] == "grant-admin" {
+		if err := runGrantAdmin(os.Args[2:]); err != nil {
+			fmt.Printf("grant-admin failed,err:%v\n", err)
+		}
+		return
+	}
+
+	// 创建者、修改者取当前登录用户
+	model.Principal = func(ctx context.Context) string {
+		if claims, ok := auth.ClaimsFromContext(ctx); ok {
+			return claims.Username
+		}
+		return ""
+	}
+
+	// 注册路由：所有路由打印请求日志，认证和授权按路由配置
+	rt := router.New()
+	rt.Use(router.Logger)
+	admin := []string{auth.AdminRole}
+	requireRoles := func(roles []string) router.Middleware {
+		return func(next http.Handler) http.Handler { return auth.RequireRoles(roles, next) }
+	}
+
+	// 资源的查询只要求登录，增删改需要管理员
+	adminWrites := router.Access{
+		http.MethodPost:   admin,
+		http.MethodPut:    admin,
+		http.MethodPatch:  admin,
+		http.MethodDelete: admin,
+	}
+
+	// 用户资源
+	userHandler := &controller.UserHandler{DB: config.DB}
+	rt.HandleFunc(http.MethodPost, "/users/bulk", userHandler.BulkCreate, requireRoles(admin))
+	rt.HandleFunc(http.MethodPut, "/users/bulk", userHandler.BulkUpdate, requireRoles(admin))
+	rt.HandleFunc(http.MethodDelete, "/users/bulk", userHandler.BulkDelete, requireRoles(admin))
+	rt.HandleFunc(http.MethodGet, "/users/export", userHandler.Export, requireRoles(nil))
+	user := rt.Resource("users", "id", userHandler, adminWrites)
+	user.HandleFunc(http.MethodPost, "/password", userHandler.ChangePassword, requireRoles(nil))
+	user.HandleFunc(http.MethodPost, "/restore", userHandler.Restore, requireRoles(admin))
+	user.HandleFunc(http.MethodGet, "/roles", userHandler.Roles, requireRoles(nil))
+	user.HandleFunc(http.MethodPut, "/roles/{roleId}", userHandler.AssignRole, requireRoles(admin))
+	user.HandleFunc(http.MethodDelete, "/roles/{roleId}", userHandler.UnassignRole, requireRoles(admin))
+	user.HandleFunc(http.MethodGet, "/permissions", userHandler.Permissions, requireRoles(nil))
+
+	// 角色和权限：查询只要求登录，增删改和授权需要管理员
+	roleHandler := &controller.RoleHandler{DB: config.DB}
+	role := rt.Resource("roles", "id", roleHandler, adminWrites)
+	role.HandleFunc(http.MethodGet, "/permissions", roleHandler.Permissions, requireRoles(nil))
+	role.HandleFunc(http.MethodPut, "/permissions/{permissionId}", roleHandler.Grant, requireRoles(admin))
+	role.HandleFunc(http.MethodDelete, "/permissions/{permissionId}", roleHandler.Revoke, requireRoles(admin))
+	rt.Resource("permissions", "id", &controller.PermissionHandler{DB: config.DB}, adminWrites)
+
+	// 登录（不需要令牌）
+	rt.HandleFunc(http.MethodPost, "/login", controller.Login)
+	// 连接池统计（内部信息，需要管理员）
+	rt.HandleFunc(http.MethodGet, "/debug/db/stats", controller.DBStats(config.DBs), requireRoles(admin))
+	// SQL 语句耗时、错误次数和慢查询次数（Prometheus 文本格式），指标中有归一化的 SQL，需要管理员
+	rt.Handle(http.MethodGet, "/metrics", sqltrace.Handler(), requireRoles(admin))
+	// 存活和就绪检查（不需要令牌）
+	health := &controller.Health{DBs: config.DBs}
+	rt.HandleFunc(http.MethodGet, "/healthz", health.Live)
+	rt.HandleFunc(http.MethodGet, "/readyz", health.Ready)
+
+	// 收到 SIGINT/SIGTERM 时 ctx 取消：停止定时任务，优雅关闭服务器，最后由 defer 关闭数据库连接池
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 定时清理软删除的数据
+	purgeDone := startPurgeJob(ctx)
 
 	// 启动服务
-	log.Println("服务器运行在 :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if err := serve(ctx, respond.RequestID(config.Session(rt)), health, loadDrainDelay()); err != nil {
+		fmt.Printf("server failed,err:%v\n", err)
+	}
+	// 服务器启动失败时 ctx 还没有取消，先停止定时任务，等正在执行的清理结束后才能关闭连接池
+	stop()
+	<-purgeDone
+}
+
+// runMigrate 执行迁移命令
+func runMigrate(m *migrate.Migrator, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate up|down|status|redo")
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err == nil {
+			log.Printf("执行了 %d 个迁移", n)
+		}
+		return err
+	case "down":
+		done, err := m.Down(ctx)
+		if err == nil && !done {
+			log.Println("没有可回滚的迁移")
+		}
+		return err
+	case "redo":
+		done, err := m.Redo(ctx)
+		if err == nil && !done {
+			log.Println("没有可重新执行的迁移")
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	}
+	return fmt.Errorf("unknown migrate command %q, want up|down|status|redo", args[0])
+}
+
+// runGrantAdmin 给用户分配 admin 角色，已经是管理员时不报错
+func runGrantAdmin(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: grant-admin USERNAME")
+	}
+	err := model.WithTx(context.Background(), nil, func(ctx context.Context) error {
+		return model.AssignRoleByName(ctx, args[0], auth.AdminRole)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("已给 %s 分配 %s 角色，重新登录后生效", args[0], auth.AdminRole)
+	return nil
 }
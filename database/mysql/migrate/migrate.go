@@ -0,0 +1,387 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+迁移文件放在 migrations 目录，编译时通过 embed 打包进二进制，文件名格式：
+<版本号>_<名称>.up.sql    升级
+<版本号>_<名称>.down.sql  回滚
+例如 0001_create_users.up.sql。版本号必须递增，已发布的迁移文件不要再修改，改表结构请新增迁移。
+注意：MySQL 的 DDL 会隐式提交事务，迁移无法整体回滚，一个迁移文件尽量只做一件事。
+*/
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// 记录已执行迁移的表
+const migrationsTable = "schema_migrations"
+
+// 咨询锁（GET_LOCK）的名称和等待时间，保证多个实例同时启动时只有一个执行迁移
+const (
+	lockName    = "mysql_demo_schema_migrations"
+	lockTimeout = 30 * time.Second
+)
+
+var ErrLockTimeout = errors.New("timeout waiting for migration lock") //等待迁移锁超时
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 迁移状态，AppliedAt 为 nil 表示未执行
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New 使用内置的迁移文件构造迁移执行器
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return NewFromFS(db, sub)
+}
+
+// NewFromFS 使用 fsys 根目录下的迁移文件构造迁移执行器
+func NewFromFS(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load 读取并按版本号排序迁移文件，每个版本必须有 up 文件，down 文件可选
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		version, name, direction, err := parseFileName(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseFileName 解析 0001_create_users.up.sql
+func parseFileName(file string) (version int64, name, direction string, err error) {
+	base := strings.TrimSuffix(file, ".sql")
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration file %s must end with .up.sql or .down.sql", file)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+	v, name, _ := strings.Cut(base, "_")
+	version, err = strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration file %s must start with a positive version", file)
+	}
+	return version, name, direction, nil
+}
+
+// Up 执行所有未执行的迁移，返回执行的个数
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	n := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := up(ctx, conn, mg); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Down 回滚最后一个已执行的迁移，没有可回滚的迁移时返回 false
+func (m *Migrator) Down(ctx context.Context) (bool, error) {
+	done := false
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		mg, err := m.last(ctx, conn)
+		if err != nil || mg == nil {
+			return err
+		}
+		if err := down(ctx, conn, *mg); err != nil {
+			return err
+		}
+		done = true
+		return nil
+	})
+	return done, err
+}
+
+// Redo 回滚并重新执行最后一个已执行的迁移，用于开发时修改迁移文件后重跑
+func (m *Migrator) Redo(ctx context.Context) (bool, error) {
+	done := false
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		mg, err := m.last(ctx, conn)
+		if err != nil || mg == nil {
+			return err
+		}
+		if err := down(ctx, conn, *mg); err != nil {
+			return err
+		}
+		if err := up(ctx, conn, *mg); err != nil {
+			return err
+		}
+		done = true
+		return nil
+	})
+	return done, err
+}
+
+// Status 所有迁移的执行状态，按版本号排序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mg := range m.migrations {
+		statuses[i] = Status{Version: mg.Version, Name: mg.Name}
+		if t, ok := applied[mg.Version]; ok {
+			statuses[i].AppliedAt = &t
+		}
+	}
+	return statuses, nil
+}
+
+// last 最后一个已执行且存在迁移文件的迁移，没有时返回 nil
+func (m *Migrator) last(ctx context.Context, conn *sql.Conn) (*Migration, error) {
+	var version int64
+	err := conn.QueryRowContext(ctx, "SELECT version FROM "+migrationsTable+" ORDER BY version DESC LIMIT 1").Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("applied migration %d has no migration file", version)
+}
+
+/*
+withLock 在同一个连接上获取咨询锁后执行 fn
+GET_LOCK 的锁属于连接（会话），所以加锁、迁移、释放锁都必须使用同一个 *sql.Conn，不能直接用连接池
+*/
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout/time.Second)).Scan(&got); err != nil {
+		return err
+	}
+	if got.Int64 != 1 {
+		return ErrLockTimeout
+	}
+	defer func() {
+		// 连接关闭时锁也会释放，这里主动释放，避免连接回到连接池后仍持有锁
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			log.Printf("释放迁移锁失败：%v", err)
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationsTable+` (
+		version BIGINT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL
+	) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func up(ctx context.Context, conn *sql.Conn, mg Migration) error {
+	if err := execScript(ctx, conn, mg.Up); err != nil {
+		return fmt.Errorf("migrate up %d_%s: %w", mg.Version, mg.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (version, name, applied_at) VALUES (?, ?, ?)",
+		mg.Version, mg.Name, time.Now())
+	if err == nil {
+		log.Printf("迁移 %d_%s 已执行", mg.Version, mg.Name)
+	}
+	return err
+}
+
+func down(ctx context.Context, conn *sql.Conn, mg Migration) error {
+	if strings.TrimSpace(mg.Down) == "" {
+		return fmt.Errorf("migration %d_%s has no down file", mg.Version, mg.Name)
+	}
+	if err := execScript(ctx, conn, mg.Down); err != nil {
+		return fmt.Errorf("migrate down %d_%s: %w", mg.Version, mg.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, "DELETE FROM "+migrationsTable+" WHERE version = ?", mg.Version)
+	if err == nil {
+		log.Printf("迁移 %d_%s 已回滚", mg.Version, mg.Name)
+	}
+	return err
+}
+
+// execScript 逐条执行脚本中的语句（驱动默认不开启 multiStatements）
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+splitStatements 按分号拆分SQL语句，忽略引号（'、"、`）内的分号和注释（-- 、# 行注释和块注释）
+不支持 DELIMITER（存储过程、触发器），这类迁移请一个文件只写一条语句且不带结尾分号
+*/
+func splitStatements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(script) && script[j] != c {
+				if script[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			j = min(j, len(script)-1)
+			b.WriteString(script[i : j+1])
+			i = j
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+				b.WriteByte('\n')
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case c == ';':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
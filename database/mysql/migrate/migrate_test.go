@@ -0,0 +1,32 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "empty", script: " \n-- only a comment\n", want: nil},
+		{name: "two statements", script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want: []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{name: "no trailing semicolon", script: "SELECT 1", want: []string{"SELECT 1"}},
+		{name: "semicolons in quotes", script: "INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`);SELECT 2",
+			want: []string{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)", "SELECT 2"}},
+		{name: "escaped quote", script: `INSERT INTO t VALUES ('it\'s;');`, want: []string{`INSERT INTO t VALUES ('it\'s;')`}},
+		{name: "comments", script: "-- drop; not executed\nSELECT 1; # trailing; comment\n/* block; comment */SELECT 2;",
+			want: []string{"SELECT 1", "SELECT 2"}},
+		{name: "double dash without space is an operator", script: "SELECT 1--1;", want: []string{"SELECT 1--1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
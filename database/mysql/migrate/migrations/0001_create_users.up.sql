@@ -1,9 +1,4 @@
-
-SET NAMES utf8mb4;
-SET FOREIGN_KEY_CHECKS = 0;
-
-DROP TABLE IF EXISTS `users`;
-CREATE TABLE `users`  (
+CREATE TABLE IF NOT EXISTS `users`  (
   `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'ID',
   `created_at` datetime NULL DEFAULT NULL COMMENT '创建时间',
   `created_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '创建者',
@@ -14,5 +9,3 @@ CREATE TABLE `users`  (
   `name` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '姓名',
   PRIMARY KEY (`id`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '用户表' ROW_FORMAT = Dynamic;
-
-SET FOREIGN_KEY_CHECKS = 1;
@@ -0,0 +1,3 @@
+-- 用户的角色，多个角色用逗号分隔，例如 admin,user
+-- 该列已由 0006 迁移到 user_roles 表并删除；新库没有管理员，用 go run . grant-admin <用户名> 指定
+ALTER TABLE `users` ADD COLUMN `roles` varchar(255) NOT NULL DEFAULT 'user' COMMENT '角色' AFTER `name`;
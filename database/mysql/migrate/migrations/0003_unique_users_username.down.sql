@@ -0,0 +1 @@
+ALTER TABLE `users` DROP INDEX `uk_users_username`;
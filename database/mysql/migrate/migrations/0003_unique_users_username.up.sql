@@ -0,0 +1,2 @@
+-- 用户名唯一，执行前需要先清理重复的用户名
+ALTER TABLE `users` ADD UNIQUE INDEX `uk_users_username` (`username`);
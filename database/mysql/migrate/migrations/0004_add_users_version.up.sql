@@ -0,0 +1,2 @@
+-- 乐观锁版本号，已有数据从 1 开始
+ALTER TABLE `users` ADD COLUMN `version` int UNSIGNED NOT NULL DEFAULT 1 COMMENT '版本号' AFTER `roles`;
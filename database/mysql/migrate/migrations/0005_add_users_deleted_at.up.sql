@@ -0,0 +1,4 @@
+-- 软删除：deleted_at 不为 NULL 表示已删除
+-- 注意用户名唯一索引包括已删除的用户，删除的用户名在清理（purge）之前不能被重新使用
+ALTER TABLE `users` ADD COLUMN `deleted_at` datetime NULL DEFAULT NULL COMMENT '删除时间' AFTER `version`,
+  ADD INDEX `idx_users_deleted_at` (`deleted_at`);
@@ -0,0 +1,8 @@
+ALTER TABLE `users` ADD COLUMN `roles` varchar(255) NOT NULL DEFAULT 'user' COMMENT '角色' AFTER `name`;
+UPDATE `users` u SET u.`roles` = COALESCE((
+  SELECT GROUP_CONCAT(r.`name` ORDER BY r.`name`) FROM `user_roles` ur JOIN `roles` r ON r.`id` = ur.`role_id` WHERE ur.`user_id` = u.`id`
+), 'user');
+DROP TABLE IF EXISTS `role_permissions`;
+DROP TABLE IF EXISTS `user_roles`;
+DROP TABLE IF EXISTS `permissions`;
+DROP TABLE IF EXISTS `roles`;
@@ -0,0 +1,61 @@
+-- 角色和权限：用户 - 角色、角色 - 权限都是多对多，通过关联表保存，替代 users.roles 列
+CREATE TABLE IF NOT EXISTS `roles`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `created_at` datetime NULL DEFAULT NULL COMMENT '创建时间',
+  `created_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '创建者',
+  `update_at` datetime NULL DEFAULT NULL COMMENT '更新时间',
+  `update_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '更新者',
+  `name` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '角色名',
+  `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '描述',
+  `version` int UNSIGNED NOT NULL DEFAULT 1 COMMENT '版本号',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uk_roles_name` (`name`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '角色表' ROW_FORMAT = Dynamic;
+
+-- 权限名为 资源:操作，例如 users:read、users:write
+CREATE TABLE IF NOT EXISTS `permissions`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `created_at` datetime NULL DEFAULT NULL COMMENT '创建时间',
+  `created_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '创建者',
+  `update_at` datetime NULL DEFAULT NULL COMMENT '更新时间',
+  `update_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '更新者',
+  `name` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '权限名',
+  `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '描述',
+  `version` int UNSIGNED NOT NULL DEFAULT 1 COMMENT '版本号',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uk_permissions_name` (`name`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '权限表' ROW_FORMAT = Dynamic;
+
+-- 删除用户（清理软删除的用户时为物理删除）、角色、权限时自动删除关联
+CREATE TABLE IF NOT EXISTS `user_roles`  (
+  `user_id` bigint UNSIGNED NOT NULL COMMENT '用户ID',
+  `role_id` bigint UNSIGNED NOT NULL COMMENT '角色ID',
+  `created_at` datetime NULL DEFAULT NULL COMMENT '分配时间',
+  `created_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '分配者',
+  PRIMARY KEY (`user_id`, `role_id`) USING BTREE,
+  INDEX `idx_user_roles_role_id` (`role_id`),
+  CONSTRAINT `fk_user_roles_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_user_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '用户角色关联表' ROW_FORMAT = Dynamic;
+
+CREATE TABLE IF NOT EXISTS `role_permissions`  (
+  `role_id` bigint UNSIGNED NOT NULL COMMENT '角色ID',
+  `permission_id` bigint UNSIGNED NOT NULL COMMENT '权限ID',
+  `created_at` datetime NULL DEFAULT NULL COMMENT '授权时间',
+  `created_by` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '授权者',
+  PRIMARY KEY (`role_id`, `permission_id`) USING BTREE,
+  INDEX `idx_role_permissions_permission_id` (`permission_id`),
+  CONSTRAINT `fk_role_permissions_role` FOREIGN KEY (`role_id`) REFERENCES `roles` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_role_permissions_permission` FOREIGN KEY (`permission_id`) REFERENCES `permissions` (`id`) ON DELETE CASCADE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '角色权限关联表' ROW_FORMAT = Dynamic;
+
+-- 新库没有用户拥有 admin 角色，用 go run . grant-admin <用户名> 指定第一个管理员
+INSERT INTO `roles` (`created_at`, `created_by`, `update_at`, `update_by`, `name`, `description`) VALUES
+  (NOW(), 'system', NOW(), 'system', 'admin', '管理员，拥有所有权限'),
+  (NOW(), 'system', NOW(), 'system', 'user', '普通用户');
+
+-- 迁移 users.roles 列中的角色（只有 admin 和 user，其他角色需要迁移前先手动插入 roles 表）
+INSERT INTO `user_roles` (`user_id`, `role_id`, `created_at`, `created_by`)
+SELECT u.`id`, r.`id`, NOW(), 'system' FROM `users` u JOIN `roles` r ON FIND_IN_SET(r.`name`, REPLACE(u.`roles`, ' ', '')) > 0;
+
+ALTER TABLE `users` DROP COLUMN `roles`;
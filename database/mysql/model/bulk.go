@@ -0,0 +1,222 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mysql/customerror"
+)
+
+// 批量操作每条 SQL 处理的行数
+const (
+	DefaultBulkChunkSize = 500
+	MaxBulkChunkSize     = 1000 //users 有 10 列，1000 行约 1 万个占位符，远低于 MySQL 的 65535 上限
+)
+
+// BulkStatus 批量操作中一项的处理结果
+type BulkStatus string
+
+const (
+	BulkCreated BulkStatus = "created"
+	BulkUpdated BulkStatus = "updated"
+	BulkDeleted BulkStatus = "deleted"
+	BulkFailed  BulkStatus = "failed"
+)
+
+// BulkResult 批量操作中一项的结果，Index 为该项在输入中的下标
+type BulkResult struct {
+	Index  int
+	Id     int
+	Status BulkStatus
+	Err    error //Status 为 BulkFailed 时的原因（唯一约束、版本冲突、不存在等）
+}
+
+// BulkOptions 批量创建的选项
+type BulkOptions struct {
+	ChunkSize int  //每条 INSERT 的行数，0 使用 DefaultBulkChunkSize
+	Upsert    bool //用户名已存在时更新（INSERT ... ON DUPLICATE KEY UPDATE），否则该项失败
+}
+
+/*
+CreateUsers 批量创建用户（在 ctx 的事务中执行，见 WithTx），密码需要调用方先用 HashPassword 计算：
+1.按 ChunkSize 分批，每批一条多行 INSERT，每批在保存点中执行
+2.不是 Upsert 时某批违反唯一约束，回滚到保存点后逐行插入，找出冲突的行标记为失败，其余行照常插入
+3.Upsert 时先按用户名查出已存在的用户，结果中区分 created 和 updated（已软删除的用户会被恢复）
+4.同一请求中重复的用户名只处理第一个，后面的标记为失败
+只有项本身的错误记录在结果中，数据库等其他错误直接返回，由调用方回滚整个事务
+*/
+func CreateUsers(ctx context.Context, users []*User, opts BulkOptions) ([]BulkResult, error) {
+	size := opts.ChunkSize
+	if size <= 0 {
+		size = DefaultBulkChunkSize
+	}
+	size = min(size, MaxBulkChunkSize)
+
+	results := make([]BulkResult, len(users))
+	seen := make(map[string]bool, len(users))
+	var pending []int //待插入的下标
+	for i, u := range users {
+		results[i].Index = i
+		if seen[u.Username] {
+			results[i].Status = BulkFailed
+			results[i].Err = customerror.BadRequest(fmt.Sprintf("username %q appears more than once in the request", u.Username))
+			continue
+		}
+		seen[u.Username] = true
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(pending); start += size {
+		chunk := pending[start:min(start+size, len(pending))]
+		if err := createChunk(ctx, users, chunk, opts.Upsert, results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func createChunk(ctx context.Context, users []*User, chunk []int, upsert bool, results []BulkResult) error {
+	batch := make([]*User, len(chunk))
+	usernames := make([]string, len(chunk))
+	for i, index := range chunk {
+		batch[i] = users[index]
+		usernames[i] = users[index].Username
+	}
+	var existing map[string]int
+	if upsert {
+		var err error
+		if existing, err = userIDsByUsername(ctx, usernames); err != nil {
+			return err
+		}
+	}
+
+	err := WithTx(ctx, nil, func(ctx context.Context) error {
+		_, err := userRepo.InsertMany(ctx, executor(ctx), batch, upsert)
+		return err
+	})
+	if isDuplicate(err) && !upsert {
+		return createOneByOne(ctx, users, chunk, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 多行插入拿不到每一行的自增主键，按用户名（唯一）查询
+	ids, err := userIDsByUsername(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	for _, index := range chunk {
+		u := users[index]
+		u.Id = ids[u.Username]
+		results[index].Id = u.Id
+		results[index].Status = BulkCreated
+		if _, ok := existing[u.Username]; ok {
+			results[index].Status = BulkUpdated
+		}
+	}
+	return nil
+}
+
+// createOneByOne 逐行插入（每行一个保存点），违反唯一约束的行标记为失败
+func createOneByOne(ctx context.Context, users []*User, chunk []int, results []BulkResult) error {
+	for _, index := range chunk {
+		u := users[index]
+		err := WithTx(ctx, nil, func(ctx context.Context) error {
+			_, err := u.Create(ctx)
+			return err
+		})
+		switch {
+		case err == nil:
+			results[index].Id = u.Id
+			results[index].Status = BulkCreated
+		case errors.Is(err, ErrDuplicate):
+			results[index].Status = BulkFailed
+			results[index].Err = err
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// userIDsByUsername 按用户名查询主键（包括已软删除的用户，唯一索引同样包含它们）
+func userIDsByUsername(ctx context.Context, usernames []string) (map[string]int, error) {
+	args := make([]any, len(usernames))
+	for i, username := range usernames {
+		args[i] = username
+	}
+	rows, err := executor(ctx).QueryContext(ctx, "SELECT id, username FROM users WHERE username IN ("+placeholders(len(args))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[string]int, len(usernames))
+	for rows.Next() {
+		var id int
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		ids[username] = id
+	}
+	return ids, rows.Err()
+}
+
+/*
+UpdateUsers 批量更新用户（在 ctx 的事务中执行），与 Update 一样每个用户都要带上读取到的 version
+版本冲突、用户不存在和用户名重复记录在对应项的结果中（失败的 UPDATE 不修改数据，不影响其他项）
+*/
+func UpdateUsers(ctx context.Context, users []*User) ([]BulkResult, error) {
+	results := make([]BulkResult, len(users))
+	for i, u := range users {
+		results[i] = BulkResult{Index: i, Id: u.Id, Status: BulkUpdated}
+		err := u.Update(ctx)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrStaleVersion), errors.Is(err, ErrDuplicate), errors.Is(err, sql.ErrNoRows):
+			results[i].Status = BulkFailed
+			results[i].Err = err
+		default:
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// DeleteUsers 批量软删除用户（在 ctx 的事务中执行），每批一条 UPDATE，不存在或已删除的用户标记为失败
+func DeleteUsers(ctx context.Context, ids []int, chunkSize int) ([]BulkResult, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkChunkSize
+	}
+	chunkSize = min(chunkSize, MaxBulkChunkSize)
+
+	deleted := make(map[int64]bool, len(ids))
+	for start := 0; start < len(ids); start += chunkSize {
+		chunk := ids[start:min(start+chunkSize, len(ids))]
+		batch := make([]int64, len(chunk))
+		for i, id := range chunk {
+			batch[i] = int64(id)
+		}
+		done, err := userRepo.DeleteMany(ctx, executor(ctx), batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range done {
+			deleted[id] = true
+		}
+	}
+
+	results := make([]BulkResult, len(ids))
+	for i, id := range ids {
+		results[i] = BulkResult{Index: i, Id: id, Status: BulkDeleted}
+		if !deleted[int64(id)] {
+			results[i].Status = BulkFailed
+			results[i].Err = sql.ErrNoRows
+			continue
+		}
+		delete(deleted, int64(id)) //同一个ID出现多次时只有第一次算删除成功
+	}
+	return results, nil
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"mysql/customerror"
+)
+
+var ErrDuplicate = customerror.Conflict("duplicate entry") //违反唯一约束
+
+// duplicate 违反唯一约束的错误，消息（例如哪个用户名重复）返回给前端，errors.Is(err, ErrDuplicate) 成立
+func duplicate(format string, args ...any) error {
+	return &customerror.AppError{Code: customerror.CodeConflict, Message: fmt.Sprintf(format, args...), Err: ErrDuplicate}
+}
+
+// MySQL 错误码
+const errDupEntry = 1062 //ER_DUP_ENTRY
+
+// isDuplicate 是否违反唯一约束（MySQL 1062）
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDupEntry
+}
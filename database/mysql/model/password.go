@@ -0,0 +1,125 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"mysql/customerror"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidCredentials  = customerror.NewError(customerror.CodeUnauthorized, "invalid username or password") //用户名或密码错误，不区分哪个错，防止枚举用户名
+	ErrInvalidPasswordHash = errors.New("invalid password hash")                                                //数据库中的密码哈希格式错误
+)
+
+// PasswordParams argon2id 参数，调大参数后旧哈希在下次登录成功时自动升级
+type PasswordParams struct {
+	Memory  uint32 //内存（KiB）
+	Time    uint32 //迭代次数
+	Threads uint8  //并行度
+	SaltLen uint32 //盐长度（字节）
+	KeyLen  uint32 //哈希长度（字节）
+}
+
+// DefaultPasswordParams 新密码使用的参数（OWASP 推荐的 argon2id 最低配置之上）
+var DefaultPasswordParams = PasswordParams{
+	Memory:  64 * 1024,
+	Time:    3,
+	Threads: 2,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+/*
+HashPassword 使用 argon2id 和随机盐计算密码哈希，返回 PHC 格式的字符串：
+$argon2id$v=19$m=65536,t=3,p=2$<盐>$<哈希>
+参数和盐都保存在结果中，校验时不依赖当前配置，所以可以随时调整 DefaultPasswordParams
+*/
+func HashPassword(password string) (string, error) {
+	p := DefaultPasswordParams
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+/*
+HashPasswords 并行计算多个密码的哈希（argon2id 每次约几十毫秒、64MB 内存，批量导入几千个用户逐个计算要几分钟），
+并发数为 CPU 核数，ctx 取消时停止
+*/
+func HashPasswords(ctx context.Context, passwords []string) ([]string, error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i := range passwords {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			hashes[i], errs[i] = HashPassword(passwords[i])
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, errors.Join(errs...)
+}
+
+/*
+VerifyPassword 校验密码，needsRehash 为 true 时调用方应该用 HashPassword 重新计算并保存：
+1.哈希参数与 DefaultPasswordParams 不同（参数升级）
+2.历史数据中的明文密码（不是 $argon2id$ 开头），校验通过后升级为哈希
+*/
+func VerifyPassword(encoded, password string) (ok, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$argon2id$") {
+		ok = encoded != "" && subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1
+		return ok, ok, nil
+	}
+	p, salt, key, err := decodePasswordHash(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	actual := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	if subtle.ConstantTimeCompare(key, actual) != 1 {
+		return false, false, nil
+	}
+	return true, p != DefaultPasswordParams, nil
+}
+
+func decodePasswordHash(encoded string) (p PasswordParams, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"mysql/validate"
+	"time"
+)
+
+// Permission 权限，名称为 资源:操作，例如 users:read、users:write
+type Permission struct {
+	//主键
+	Id int `json:"id" db:"id,pk"`
+	//创建时间（插入时自动设置）
+	CreatedAt time.Time `json:"createdAt" db:"created_at,created"`
+	//创建者（插入时自动设置为当前登录用户）
+	CreatedBy string `json:"createdBy" db:"created_by,createdby"`
+	//修改时间（插入和更新时自动设置）
+	UpdateAt time.Time `json:"updateAt" db:"update_at,updated"`
+	//修改者（插入和更新时自动设置为当前登录用户）
+	UpdateBy string `json:"updateBy" db:"update_by,updatedby"`
+	//权限名
+	Name string `json:"name" db:"name" validate:"required,max=128,pattern=permission"`
+	//描述
+	Description string `json:"description" db:"description" validate:"max=255"`
+	//版本号（乐观锁），更新时必须带上读取到的版本号
+	Version int `json:"version" db:"version,version"`
+}
+
+func init() {
+	validate.RegisterPattern("permission", `^[a-z][a-z0-9_-]*(:[a-z0-9_*-]+)+$`)
+}
+
+// permissionRepo permissions 表的通用增删改查
+var permissionRepo = NewRepository[Permission]("permissions")
+
+// PermissionSortable 允许排序的字段（JSON字段名 -> 列名）
+var PermissionSortable = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"createdAt": "created_at",
+}
+
+// PermissionFilterable 允许等值过滤的字段（JSON字段名 -> 列名）
+var PermissionFilterable = map[string]string{
+	"name": "name",
+}
+
+// Create 创建权限（在 ctx 的事务中执行），权限名重复时返回 ErrDuplicate
+func (p *Permission) Create(ctx context.Context) (int64, error) {
+	id, err := permissionRepo.Insert(ctx, executor(ctx), p)
+	if isDuplicate(err) {
+		return 0, duplicate("permission %q already exists", p.Name)
+	}
+	return id, err
+}
+
+// FindPermissionByID 获取权限，不存在时返回 sql.ErrNoRows
+func FindPermissionByID(ctx context.Context, id int) (*Permission, error) {
+	return permissionRepo.FindByID(ctx, reader(ctx), id)
+}
+
+// ListPermissions 分页查询权限，q 中的列名需来自 PermissionSortable/PermissionFilterable
+func ListPermissions(ctx context.Context, q ListQuery) (*Page[Permission], error) {
+	return list(ctx, reader(ctx), permissionRepo.Table(), permissionRepo.Columns(), "id", "", q, permissionRepo.Scan, permissionRepo.ColumnValue)
+}
+
+// Update 更新权限（在 ctx 的事务中执行），错误与 User.Update 相同
+func (p *Permission) Update(ctx context.Context) error {
+	err := permissionRepo.Update(ctx, executor(ctx), p)
+	if isDuplicate(err) {
+		return duplicate("permission %q already exists", p.Name)
+	}
+	return err
+}
+
+// PatchPermission 部分更新权限（在 ctx 的事务中执行，见 Repository.Patch）
+func PatchPermission(ctx context.Context, id int, patch func(doc []byte) ([]byte, error)) (*Permission, error) {
+	p, err := permissionRepo.Patch(ctx, executor(ctx), id, patch, func(current, patched *Permission) error {
+		return validate.Struct(patched)
+	})
+	if isDuplicate(err) {
+		return nil, duplicate("permission name already exists")
+	}
+	return p, err
+}
+
+// DeletePermission 删除权限（在 ctx 的事务中执行），同时从所有角色中收回
+func DeletePermission(ctx context.Context, id int) error {
+	return permissionRepo.Delete(ctx, executor(ctx), id)
+}
@@ -0,0 +1,252 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mysql/customerror"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 分页默认值
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = customerror.BadRequest("invalid cursor") //游标无法解析或与排序字段不匹配
+
+// SortField 排序字段，Column 必须是经过白名单校验的列名
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+/*
+ListQuery 列表查询条件
+分页方式二选一：
+1.偏移分页：Page/Size，适合跳页，数据量大时越往后越慢
+2.游标分页（Keyset）：Keyset 为 true，Cursor 为上一页返回的 NextCursor（第一页为空），按排序字段定位，性能稳定
+*/
+type ListQuery struct {
+	Page    int
+	Size    int
+	Sort    []SortField
+	Filters map[string]interface{} //列名 -> 值，等值过滤，列名必须经过白名单校验
+	Keyset  bool
+	Cursor  string
+
+	WithDeleted bool //包含已软删除的行（仅管理员）
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"` //游标分页时为空
+	Size       int    `json:"size"`
+	NextCursor string `json:"nextCursor,omitempty"` //游标分页时还有下一页才返回
+}
+
+// normalize 补齐默认值，排序字段最后追加主键保证顺序稳定（游标分页依赖唯一排序）
+func (q *ListQuery) normalize(pk string) {
+	if q.Size <= 0 {
+		q.Size = DefaultPageSize
+	}
+	q.Size = min(q.Size, MaxPageSize)
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	for _, s := range q.Sort {
+		if s.Column == pk {
+			return
+		}
+	}
+	q.Sort = append(q.Sort, SortField{Column: pk})
+}
+
+// whereClause 生成过滤条件，按列名排序保证SQL稳定（便于预编译语句复用）
+func (q *ListQuery) whereClause() (string, []interface{}) {
+	if len(q.Filters) == 0 {
+		return "", nil
+	}
+	columns := make([]string, 0, len(q.Filters))
+	for c := range q.Filters {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+	conds := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, c := range columns {
+		conds[i] = c + " = ?"
+		args[i] = q.Filters[c]
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// orderClause ORDER BY 子句
+func (q *ListQuery) orderClause() string {
+	parts := make([]string, len(q.Sort))
+	for i, s := range q.Sort {
+		if s.Desc {
+			parts[i] = s.Column + " DESC"
+		} else {
+			parts[i] = s.Column + " ASC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+/*
+keysetClause 游标条件，排序 (a ASC, b DESC, id ASC) 对应：
+(a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?)
+注意：排序列的值不能为 NULL，否则 NULL 所在的行会被跳过
+*/
+func (q *ListQuery) keysetClause(values []interface{}) (string, []interface{}) {
+	var ors []string
+	var args []interface{}
+	for i, s := range q.Sort {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, q.Sort[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if s.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, s.Column+op)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// encodeCursor 把最后一行的排序字段值编码为游标
+func encodeCursor(values []interface{}) (string, error) {
+	for i, v := range values {
+		// 时间按 MySQL DATETIME 的格式编码，解码后可以直接作为查询参数
+		if t, ok := v.(time.Time); ok {
+			values[i] = t.UTC().Format("2006-01-02 15:04:05.999999")
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor 解码游标，值的个数必须与排序字段个数一致
+func decodeCursor(cursor string, n int) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var values []interface{}
+	if err := dec.Decode(&values); err != nil || len(values) != n {
+		return nil, ErrInvalidCursor
+	}
+	for i, v := range values {
+		switch v := v.(type) {
+		case json.Number:
+			values[i] = v.String()
+		case string, nil:
+		default:
+			return nil, fmt.Errorf("%w: unsupported value %v", ErrInvalidCursor, v)
+		}
+	}
+	return values, nil
+}
+
+// rowScanner *sql.Row 和 *sql.Rows 的公共方法
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+/*
+list 通用列表查询：过滤、排序、偏移或游标分页，并统计满足过滤条件的总数
+scope 为固定的附加条件（例如排除软删除的行），scan 把一行扫描为 T，valueOf 返回 T 某列的值（用于生成下一页游标）
+*/
+func list[T any](ctx context.Context, db DBTX, table, columns, pk, scope string, q ListQuery,
+	scan func(rowScanner) (T, error), valueOf func(T, string) interface{}) (*Page[T], error) {
+	q.normalize(pk)
+	where, args := q.whereClause()
+	if scope != "" && where != "" {
+		where = scope + " AND " + where
+	} else if scope != "" {
+		where = scope
+	}
+
+	countSql := "SELECT COUNT(*) FROM " + table
+	if where != "" {
+		countSql += " WHERE " + where
+	}
+	page := &Page[T]{Items: []T{}, Size: q.Size}
+	if err := db.QueryRowContext(ctx, countSql, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	conds := where
+	if q.Keyset && q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, len(q.Sort))
+		if err != nil {
+			return nil, err
+		}
+		keyset, keysetArgs := q.keysetClause(values)
+		if conds != "" {
+			conds += " AND "
+		}
+		conds += keyset
+		args = append(args, keysetArgs...)
+	}
+	querySql := "SELECT " + columns + " FROM " + table
+	if conds != "" {
+		querySql += " WHERE " + conds
+	}
+	querySql += " ORDER BY " + q.orderClause()
+	if q.Keyset {
+		// 多查一行判断是否还有下一页
+		querySql += " LIMIT ?"
+		args = append(args, q.Size+1)
+	} else {
+		querySql += " LIMIT ? OFFSET ?"
+		args = append(args, q.Size, (q.Page-1)*q.Size)
+		page.Page = q.Page
+	}
+
+	rows, err := db.QueryContext(ctx, querySql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.Keyset && len(page.Items) > q.Size {
+		page.Items = page.Items[:q.Size]
+		last := page.Items[q.Size-1]
+		values := make([]interface{}, len(q.Sort))
+		for i, s := range q.Sort {
+			values[i] = valueOf(last, s.Column)
+		}
+		if page.NextCursor, err = encodeCursor(values); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+AssignRole 给用户分配角色（在 ctx 的事务中执行），已经分配过时不报错（幂等）
+用户（已删除的用户也算不存在）或角色不存在时返回 sql.ErrNoRows
+*/
+func AssignRole(ctx context.Context, userID, roleID int) error {
+	if err := exists(ctx, "SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL", userID); err != nil {
+		return err
+	}
+	if err := exists(ctx, "SELECT 1 FROM roles WHERE id = ?", roleID); err != nil {
+		return err
+	}
+	_, err := executor(ctx).ExecContext(ctx, "INSERT IGNORE INTO user_roles (user_id, role_id, created_at, created_by) VALUES (?, ?, ?, ?)",
+		userID, roleID, time.Now(), principal(ctx))
+	return err
+}
+
+/*
+AssignRoleByName 按用户名和角色名给用户分配角色（在 ctx 的事务中执行），用于命令行指定管理员（见 main 的 grant-admin）
+已经分配过时不报错；用户（已删除的用户也算不存在）或角色不存在时返回 sql.ErrNoRows
+*/
+func AssignRoleByName(ctx context.Context, username, roleName string) error {
+	var userID, roleID int
+	if err := executor(ctx).QueryRowContext(ctx, "SELECT id FROM users WHERE username = ? AND deleted_at IS NULL", username).Scan(&userID); err != nil {
+		return fmt.Errorf("user %q: %w", username, err)
+	}
+	if err := executor(ctx).QueryRowContext(ctx, "SELECT id FROM roles WHERE name = ?", roleName).Scan(&roleID); err != nil {
+		return fmt.Errorf("role %q: %w", roleName, err)
+	}
+	return AssignRole(ctx, userID, roleID)
+}
+
+// UnassignRole 取消用户的角色（在 ctx 的事务中执行），没有分配过时返回 sql.ErrNoRows
+func UnassignRole(ctx context.Context, userID, roleID int) error {
+	return execAffected(ctx, "DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userID, roleID)
+}
+
+// GrantPermission 给角色授予权限（在 ctx 的事务中执行），已经授予过时不报错，角色或权限不存在时返回 sql.ErrNoRows
+func GrantPermission(ctx context.Context, roleID, permissionID int) error {
+	if err := exists(ctx, "SELECT 1 FROM roles WHERE id = ?", roleID); err != nil {
+		return err
+	}
+	if err := exists(ctx, "SELECT 1 FROM permissions WHERE id = ?", permissionID); err != nil {
+		return err
+	}
+	_, err := executor(ctx).ExecContext(ctx, "INSERT IGNORE INTO role_permissions (role_id, permission_id, created_at, created_by) VALUES (?, ?, ?, ?)",
+		roleID, permissionID, time.Now(), principal(ctx))
+	return err
+}
+
+// RevokePermission 收回角色的权限（在 ctx 的事务中执行），没有授予过时返回 sql.ErrNoRows
+func RevokePermission(ctx context.Context, roleID, permissionID int) error {
+	return execAffected(ctx, "DELETE FROM role_permissions WHERE role_id = ? AND permission_id = ?", roleID, permissionID)
+}
+
+// RolesOfUser 用户的角色（按名称排序），用户不存在时返回 sql.ErrNoRows
+func RolesOfUser(ctx context.Context, userID int) ([]Role, error) {
+	if err := exists(ctx, "SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL", userID); err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, roleRepo, "SELECT "+prefixed("r", roleRepo.Columns())+
+		" FROM roles r JOIN user_roles ur ON ur.role_id = r.id WHERE ur.user_id = ? ORDER BY r.name", userID)
+}
+
+// UserRoles 用户的角色名列表，用于签发令牌
+func UserRoles(ctx context.Context, id int) ([]string, error) {
+	roles, err := RolesOfUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return names, nil
+}
+
+// PermissionsOfRole 角色的权限（按名称排序），角色不存在时返回 sql.ErrNoRows
+func PermissionsOfRole(ctx context.Context, roleID int) ([]Permission, error) {
+	if err := exists(ctx, "SELECT 1 FROM roles WHERE id = ?", roleID); err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, permissionRepo, "SELECT "+prefixed("p", permissionRepo.Columns())+
+		" FROM permissions p JOIN role_permissions rp ON rp.permission_id = p.id WHERE rp.role_id = ? ORDER BY p.name", roleID)
+}
+
+/*
+UserPermissions 用户的有效权限：用户所有角色的权限的并集（去重，按名称排序），用户不存在时返回 sql.ErrNoRows
+注意管理员角色在接口层总是放行（见 auth.RequireRoles），不依赖这里返回的权限
+*/
+func UserPermissions(ctx context.Context, userID int) ([]Permission, error) {
+	if err := exists(ctx, "SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL", userID); err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, permissionRepo, "SELECT DISTINCT "+prefixed("p", permissionRepo.Columns())+
+		" FROM permissions p JOIN role_permissions rp ON rp.permission_id = p.id"+
+		" JOIN user_roles ur ON ur.role_id = rp.role_id WHERE ur.user_id = ? ORDER BY p.name", userID)
+}
+
+// exists 查询是否有结果，没有时返回 sql.ErrNoRows
+func exists(ctx context.Context, query string, args ...any) error {
+	var one int
+	return executor(ctx).QueryRowContext(ctx, query, args...).Scan(&one)
+}
+
+// execAffected 执行语句，没有影响到行时返回 sql.ErrNoRows
+func execAffected(ctx context.Context, query string, args ...any) error {
+	result, err := executor(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// queryAll 查询多行并用 repo 扫描（列顺序必须与 repo.Columns 一致）
+func queryAll[T any](ctx context.Context, repo *Repository[T], query string, args ...any) ([]T, error) {
+	rows, err := reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []T{}
+	for rows.Next() {
+		item, err := repo.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// prefixed 给逗号分隔的列名加上表别名：prefixed("r", "id, name") = "r.id, r.name"
+func prefixed(alias, columns string) string {
+	return alias + "." + strings.ReplaceAll(columns, ", ", ", "+alias+".")
+}
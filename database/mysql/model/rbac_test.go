@@ -0,0 +1,65 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"mysql/sqltest"
+	"testing"
+)
+
+func TestAssignRoleByName(t *testing.T) {
+	const (
+		selectUser = "SELECT id FROM users WHERE username = ? AND deleted_at IS NULL"
+		selectRole = "SELECT id FROM roles WHERE name = ?"
+	)
+	tests := []struct {
+		name    string
+		expect  func(db *sqltest.DB)
+		wantErr error
+	}{
+		{
+			name: "assign",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs("alice").WillReturnRows(sqltest.NewRows("id").AddRow(1))
+				db.ExpectQuery(selectRole).WithArgs("admin").WillReturnRows(sqltest.NewRows("id").AddRow(2))
+				db.ExpectQuery("SELECT 1 FROM users WHERE id = ?").WithArgs(1).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectQuery("SELECT 1 FROM roles WHERE id = ?").WithArgs(2).WillReturnRows(sqltest.NewRows("1").AddRow(1))
+				db.ExpectExec("INSERT IGNORE INTO user_roles").WithArgs(1, 2, sqltest.Any, SystemPrincipal).WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+		},
+		{
+			name: "unknown user",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs("alice").WillReturnRows(sqltest.NewRows("id"))
+				db.ExpectRollback()
+			},
+			wantErr: sql.ErrNoRows,
+		},
+		{
+			name: "unknown role",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectQuery(selectUser).WithArgs("alice").WillReturnRows(sqltest.NewRows("id").AddRow(1))
+				db.ExpectQuery(selectRole).WithArgs("admin").WillReturnRows(sqltest.NewRows("id"))
+				db.ExpectRollback()
+			},
+			wantErr: sql.ErrNoRows,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t)
+			tt.expect(db)
+			err := WithTxDB(context.Background(), db.DB, nil, func(ctx context.Context) error {
+				return AssignRoleByName(ctx, "alice", "admin")
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
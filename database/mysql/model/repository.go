@@ -0,0 +1,793 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"mysql/customerror"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DBTX *sql.DB 和 *sql.Tx 的公共方法，Repository 的方法既可以在事务内也可以在事务外调用
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// ErrStaleVersion 乐观锁冲突：数据在读取之后被其他请求修改过
+var ErrStaleVersion = customerror.Conflict("the resource has been modified by someone else, reload and retry")
+
+// SystemPrincipal 没有登录用户时（后台任务、命令行）记录的操作者
+const SystemPrincipal = "system"
+
+// Principal 从上下文中获取当前操作者，用于 createdby/updatedby 字段，由 main 设置（例如取令牌中的用户名）
+var Principal = func(ctx context.Context) string { return "" }
+
+func principal(ctx context.Context) string {
+	if p := Principal(ctx); p != "" {
+		return p
+	}
+	return SystemPrincipal
+}
+
+var (
+	_ DBTX = (*sql.DB)(nil)
+	_ DBTX = (*sql.Tx)(nil)
+)
+
+// fieldMeta 结构体字段与列的映射
+type fieldMeta struct {
+	column     string
+	jsonName   string //json 标签中的名称，用于错误信息
+	index      []int
+	pk         bool //主键（自增）
+	created    bool //插入时自动设置为当前时间，之后不再修改
+	updated    bool //插入和更新时自动设置为当前时间
+	noUpdate   bool //Update 不修改该列（例如密码，需要专门的方法修改）
+	createdBy  bool //插入时自动设置为当前操作者，之后不再修改
+	updatedBy  bool //插入和更新时自动设置为当前操作者
+	version    bool //乐观锁版本号，插入时为 1，每次更新加 1
+	softDelete bool //软删除时间，NULL 表示未删除
+}
+
+// tableMeta 结构体的列映射，按类型缓存，只反射一次
+type tableMeta struct {
+	fields     []fieldMeta
+	byColumn   map[string]*fieldMeta
+	pk         *fieldMeta
+	version    *fieldMeta
+	softDelete *fieldMeta
+	columns    string //逗号分隔的所有列，用于 SELECT
+}
+
+var metaCache sync.Map //reflect.Type -> *tableMeta
+
+/*
+parseMeta 解析 db 标签：`db:"列名,选项..."`，选项：
+pk        主键（自增），插入时不写入，插入后回填 LastInsertId
+created   插入时自动设置为当前时间，更新时不修改
+updated   插入和更新时自动设置为当前时间
+createdby 插入时自动设置为当前操作者（见 Principal），更新时不修改
+updatedby 插入和更新时自动设置为当前操作者
+version   乐观锁版本号（整数），Update 时带上读取到的版本号，不一致返回 ErrStaleVersion
+noupdate  Update 不修改该列
+softdelete 软删除时间（*time.Time），Delete 只设置删除时间，查询和更新默认排除已删除的行
+没有 db 标签或 db:"-" 的字段不映射；嵌入的结构体会展开
+*/
+func parseMeta(t reflect.Type) (*tableMeta, error) {
+	if m, ok := metaCache.Load(t); ok {
+		return m.(*tableMeta), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repository: %s is not a struct", t)
+	}
+	m := &tableMeta{byColumn: make(map[string]*fieldMeta)}
+	if err := collectFields(t, nil, m); err != nil {
+		return nil, err
+	}
+	if m.pk == nil {
+		return nil, fmt.Errorf("repository: %s has no primary key field (db:\"...,pk\")", t)
+	}
+	columns := make([]string, len(m.fields))
+	for i := range m.fields {
+		columns[i] = m.fields[i].column
+		m.byColumn[m.fields[i].column] = &m.fields[i]
+		if m.fields[i].pk {
+			m.pk = &m.fields[i]
+		}
+		if m.fields[i].version {
+			if m.version != nil {
+				return nil, fmt.Errorf("repository: %s has more than one version field", t)
+			}
+			m.version = &m.fields[i]
+		}
+		if m.fields[i].softDelete {
+			if m.softDelete != nil {
+				return nil, fmt.Errorf("repository: %s has more than one softdelete field", t)
+			}
+			m.softDelete = &m.fields[i]
+		}
+	}
+	m.columns = strings.Join(columns, ", ")
+	actual, _ := metaCache.LoadOrStore(t, m)
+	return actual.(*tableMeta), nil
+}
+
+func collectFields(t reflect.Type, parent []int, m *tableMeta) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("db") == "" {
+			if err := collectFields(f.Type, index, m); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := f.Tag.Get("db")
+		if tag == "" || tag == "-" || !f.IsExported() {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		fm := fieldMeta{column: parts[0], index: index, jsonName: f.Name}
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+			fm.jsonName = name
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "pk":
+				fm.pk = true
+			case "created":
+				fm.created = true
+			case "updated":
+				fm.updated = true
+			case "noupdate":
+				fm.noUpdate = true
+			case "createdby":
+				fm.createdBy = true
+			case "updatedby":
+				fm.updatedBy = true
+			case "version":
+				fm.version = true
+			case "softdelete":
+				fm.softDelete = true
+			default:
+				return fmt.Errorf("repository: field %s has unknown db option %q", f.Name, opt)
+			}
+		}
+		if (fm.created || fm.updated) && f.Type != reflect.TypeOf(time.Time{}) {
+			return fmt.Errorf("repository: auto timestamp field %s must be time.Time", f.Name)
+		}
+		if (fm.createdBy || fm.updatedBy) && f.Type.Kind() != reflect.String {
+			return fmt.Errorf("repository: auto principal field %s must be string", f.Name)
+		}
+		if fm.softDelete && f.Type != reflect.TypeOf((*time.Time)(nil)) {
+			return fmt.Errorf("repository: softdelete field %s must be *time.Time", f.Name)
+		}
+		if fm.version && !f.Type.ConvertibleTo(reflect.TypeOf(int64(0))) {
+			return fmt.Errorf("repository: version field %s must be an integer", f.Name)
+		}
+		if fm.pk {
+			if m.pk != nil {
+				return fmt.Errorf("repository: %s has more than one primary key", t)
+			}
+			m.pk = &fm //临时标记，fields 确定后重新指向切片元素
+		}
+		m.fields = append(m.fields, fm)
+	}
+	return nil
+}
+
+/*
+Repository 基于 db 标签的通用增删改查，T 为结构体类型，例如：
+
+	type User struct {
+		Id        int       `db:"id,pk"`
+		CreatedAt time.Time `db:"created_at,created"`
+		Name      string    `db:"name"`
+	}
+	var userRepo = NewRepository[User]("users")
+
+表名和列名来自代码（不是用户输入），可以安全地拼进SQL；值全部使用占位符
+*/
+type Repository[T any] struct {
+	table       string
+	meta        *tableMeta
+	withDeleted bool
+}
+
+// NewRepository 构造仓库，标签错误属于编程错误，直接 panic（一般在包初始化时调用）
+func NewRepository[T any](table string) *Repository[T] {
+	meta, err := parseMeta(reflect.TypeFor[T]())
+	if err != nil {
+		panic(err)
+	}
+	return &Repository[T]{table: table, meta: meta}
+}
+
+// Table 表名
+func (r *Repository[T]) Table() string {
+	return r.table
+}
+
+// WithDeleted 返回包含已软删除行的仓库（管理员查询、恢复），原仓库不受影响
+func (r *Repository[T]) WithDeleted() *Repository[T] {
+	c := *r
+	c.withDeleted = true
+	return &c
+}
+
+// Scope 排除已软删除行的条件，没有 softdelete 字段或使用 WithDeleted 时为空
+func (r *Repository[T]) Scope() string {
+	if r.meta.softDelete == nil || r.withDeleted {
+		return ""
+	}
+	return r.meta.softDelete.column + " IS NULL"
+}
+
+// scoped 在 where 条件上追加 Scope
+func (r *Repository[T]) scoped(where string) string {
+	if scope := r.Scope(); scope != "" {
+		return where + " AND " + scope
+	}
+	return where
+}
+
+// Columns 逗号分隔的所有列，顺序与 Scan 一致
+func (r *Repository[T]) Columns() string {
+	return r.meta.columns
+}
+
+// Scan 把一行（列顺序与 Columns 一致）扫描为 T
+func (r *Repository[T]) Scan(row rowScanner) (T, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	dest := make([]any, len(r.meta.fields))
+	for i, f := range r.meta.fields {
+		dest[i] = rv.FieldByIndex(f.index).Addr().Interface()
+	}
+	err := row.Scan(dest...)
+	return v, err
+}
+
+// ColumnValue 取 v 某列的值，列不存在时返回 nil
+func (r *Repository[T]) ColumnValue(v T, column string) any {
+	f, ok := r.meta.byColumn[column]
+	if !ok {
+		return nil
+	}
+	return reflect.ValueOf(v).FieldByIndex(f.index).Interface()
+}
+
+// Insert 插入一行，自动设置 created/updated 时间、操作者和版本号，并回填自增主键
+func (r *Repository[T]) Insert(ctx context.Context, db DBTX, v *T) (int64, error) {
+	rv := reflect.ValueOf(v).Elem()
+	now := time.Now()
+	who := principal(ctx)
+	columns := make([]string, 0, len(r.meta.fields))
+	args := make([]any, 0, len(r.meta.fields))
+	for _, f := range r.meta.fields {
+		if f.pk {
+			continue
+		}
+		fv := rv.FieldByIndex(f.index)
+		switch {
+		case f.created || f.updated:
+			fv.Set(reflect.ValueOf(now))
+		case f.createdBy || f.updatedBy:
+			fv.SetString(who)
+		case f.version:
+			setInt(fv, 1)
+		}
+		columns = append(columns, f.column)
+		args = append(args, fv.Interface())
+	}
+	result, err := db.ExecContext(ctx, "INSERT INTO "+r.table+" ("+strings.Join(columns, ", ")+") VALUES ("+placeholders(len(columns))+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	setInt(rv.FieldByIndex(r.meta.pk.index), id)
+	return id, nil
+}
+
+/*
+InsertMany 用一条多行 INSERT 插入 vs（自动设置时间、操作者和版本号），返回影响的行数，调用方负责分批（见 MaxBulkChunkSize）
+不回填自增主键：多行插入只能拿到第一行的 LastInsertId，innodb_autoinc_lock_mode=2 时后续的值不保证连续，需要时按唯一键重新查询
+upsert 为 true 时使用 INSERT ... ON DUPLICATE KEY UPDATE：唯一键冲突的行更新所有可修改的列和自动维护的列，
+版本号加 1（不做乐观锁校验，以最后一次写入为准），已软删除的行会被恢复；影响行数按 MySQL 的规则计算（插入 1，更新 2，没有变化 0）
+*/
+func (r *Repository[T]) InsertMany(ctx context.Context, db DBTX, vs []*T, upsert bool) (int64, error) {
+	if len(vs) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	who := principal(ctx)
+	var columns []string
+	for _, f := range r.meta.fields {
+		if !f.pk {
+			columns = append(columns, f.column)
+		}
+	}
+	row := "(" + placeholders(len(columns)) + ")"
+	rows := make([]string, 0, len(vs))
+	args := make([]any, 0, len(vs)*len(columns))
+	for _, v := range vs {
+		rv := reflect.ValueOf(v).Elem()
+		for _, f := range r.meta.fields {
+			if f.pk {
+				continue
+			}
+			fv := rv.FieldByIndex(f.index)
+			switch {
+			case f.created || f.updated:
+				fv.Set(reflect.ValueOf(now))
+			case f.createdBy || f.updatedBy:
+				fv.SetString(who)
+			case f.version:
+				setInt(fv, 1)
+			}
+			args = append(args, fv.Interface())
+		}
+		rows = append(rows, row)
+	}
+	query := "INSERT INTO " + r.table + " (" + strings.Join(columns, ", ") + ") VALUES " + strings.Join(rows, ", ")
+	if upsert {
+		// VALUES(列) 在 MySQL 8.0.20 之后不推荐使用，但新的别名写法要求 8.0.19 以上，这里兼容旧版本
+		var sets []string
+		for _, f := range r.meta.fields {
+			switch {
+			case f.updatable(), f.updated, f.updatedBy:
+				sets = append(sets, f.column+" = VALUES("+f.column+")")
+			case f.version:
+				sets = append(sets, f.column+" = "+f.column+" + 1")
+			case f.softDelete:
+				sets = append(sets, f.column+" = NULL")
+			}
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindByID 按主键查询，不存在时返回 sql.ErrNoRows
+func (r *Repository[T]) FindByID(ctx context.Context, db DBTX, id any) (*T, error) {
+	return r.FindOne(ctx, db, r.meta.pk.column+" = ?", id)
+}
+
+// FindOne 按条件查询一行，where 为带占位符的条件（不能包含用户输入），不存在时返回 sql.ErrNoRows
+func (r *Repository[T]) FindOne(ctx context.Context, db DBTX, where string, args ...any) (*T, error) {
+	v, err := r.Scan(db.QueryRowContext(ctx, "SELECT "+r.meta.columns+" FROM "+r.table+" WHERE "+r.scoped(where)+" LIMIT 1", args...))
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+/*
+All 按主键顺序遍历所有行（不包括已软删除的行，除非使用 WithDeleted），用法与 golang/base/iter.go 中的 queryUsersWithIterLoop 相同：
+
+	for user, err := range userRepo.All(ctx, db) {
+		if err != nil {
+			return err
+		}
+		...
+	}
+
+逐行读取，不把结果集读入内存（导出大表）；查询或扫描出错时 yield 一次错误后结束，消费者提前 break 时自动关闭 rows
+遍历期间一直占用一个连接，消费者不要在循环中做耗时的操作
+*/
+func (r *Repository[T]) All(ctx context.Context, db DBTX) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		query := "SELECT " + r.meta.columns + " FROM " + r.table
+		if scope := r.Scope(); scope != "" {
+			query += " WHERE " + scope
+		}
+		rows, err := db.QueryContext(ctx, query+" ORDER BY "+r.meta.pk.column)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			v, err := r.Scan(rows)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+/*
+Update 按主键更新除主键、created/createdby 和 noupdate 以外的所有列，自动设置 updated 时间和操作者
+有 version 字段时只更新版本号等于 v 中版本号的行并把版本号加 1：
+没有更新到行时，行存在返回 ErrStaleVersion（被其他请求修改过），不存在返回 sql.ErrNoRows
+*/
+func (r *Repository[T]) Update(ctx context.Context, db DBTX, v *T) error {
+	rv := reflect.ValueOf(v).Elem()
+	now := time.Now()
+	who := principal(ctx)
+	sets := make([]string, 0, len(r.meta.fields))
+	args := make([]any, 0, len(r.meta.fields)+2)
+	for _, f := range r.meta.fields {
+		if f.pk || f.created || f.createdBy || f.noUpdate || f.softDelete {
+			continue
+		}
+		fv := rv.FieldByIndex(f.index)
+		switch {
+		case f.version:
+			sets = append(sets, f.column+" = "+f.column+" + 1")
+			continue
+		case f.updated:
+			fv.Set(reflect.ValueOf(now))
+		case f.updatedBy:
+			fv.SetString(who)
+		}
+		sets = append(sets, f.column+" = ?")
+		args = append(args, fv.Interface())
+	}
+	id := rv.FieldByIndex(r.meta.pk.index).Interface()
+	where := r.scoped(r.meta.pk.column + " = ?")
+	args = append(args, id)
+	if r.meta.version != nil {
+		where += " AND " + r.meta.version.column + " = ?"
+		args = append(args, rv.FieldByIndex(r.meta.version.index).Interface())
+	}
+	result, err := db.ExecContext(ctx, "UPDATE "+r.table+" SET "+strings.Join(sets, ", ")+" WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL 默认返回实际修改的行数，数据没有变化时也是 0，所以需要再查一次是否存在
+		return r.missing(ctx, db, id)
+	}
+	if r.meta.version != nil {
+		fv := rv.FieldByIndex(r.meta.version.index)
+		setInt(fv, fv.Convert(reflect.TypeOf(int64(0))).Int()+1)
+	}
+	return nil
+}
+
+// updatable 是否可以由调用方修改（主键、自动维护的字段、noupdate 和软删除字段不可以）
+func (f *fieldMeta) updatable() bool {
+	return !f.pk && !f.created && !f.createdBy && !f.updated && !f.updatedBy && !f.version && !f.noUpdate && !f.softDelete
+}
+
+// Changed 比较 old 和 new，返回可以修改的列（见 updatable）中值不同的列，用于部分更新
+func (r *Repository[T]) Changed(old, new *T) []string {
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	var columns []string
+	for _, f := range r.meta.fields {
+		if !f.updatable() {
+			continue
+		}
+		if !sameValue(ov.FieldByIndex(f.index).Interface(), nv.FieldByIndex(f.index).Interface()) {
+			columns = append(columns, f.column)
+		}
+	}
+	return columns
+}
+
+/*
+readOnlyChanged 返回 new 中被修改的不可修改字段（版本号除外，补丁中的版本号是期望的版本号），没有时返回 nil
+比较的是 JSON 编码：new 由 old 经过 JSON 往返得到，时间的 Location 会变（例如连接参数 loc 不是 UTC 时），但编码不变
+*/
+func (r *Repository[T]) readOnlyChanged(old, new *T) (*fieldMeta, error) {
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := range r.meta.fields {
+		f := &r.meta.fields[i]
+		if f.updatable() || f.version {
+			continue
+		}
+		a, err := json.Marshal(ov.FieldByIndex(f.index).Interface())
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(nv.FieldByIndex(f.index).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(a, b) {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// sameValue 比较两个字段的值，时间比较的是时刻而不是 Location
+func sameValue(a, b any) bool {
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case *time.Time:
+		y, ok := b.(*time.Time)
+		if !ok || x == nil || y == nil {
+			return ok && x == y
+		}
+		return x.Equal(*y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+/*
+UpdateColumns 只更新 columns 中的列（加上自动维护的修改时间、操作者和版本号），用于 PATCH
+columns 中有不可修改的列时返回 customerror.BadRequest；版本号和行不存在的处理与 Update 相同
+*/
+func (r *Repository[T]) UpdateColumns(ctx context.Context, db DBTX, v *T, columns []string) error {
+	rv := reflect.ValueOf(v).Elem()
+	sets := make([]string, 0, len(columns)+3)
+	args := make([]any, 0, len(columns)+4)
+	for _, column := range columns {
+		f, ok := r.meta.byColumn[column]
+		if !ok {
+			return fmt.Errorf("repository: %s has no column %q", r.table, column)
+		}
+		if !f.updatable() {
+			return customerror.BadRequest(fmt.Sprintf("field %q is read-only", f.jsonName))
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, rv.FieldByIndex(f.index).Interface())
+	}
+	now := time.Now()
+	auditSets, auditArgs := r.auditSets(ctx, now)
+	sets = append(sets, auditSets...)
+	args = append(args, auditArgs...)
+
+	id := rv.FieldByIndex(r.meta.pk.index).Interface()
+	where := r.scoped(r.meta.pk.column + " = ?")
+	args = append(args, id)
+	if r.meta.version != nil {
+		where += " AND " + r.meta.version.column + " = ?"
+		args = append(args, rv.FieldByIndex(r.meta.version.index).Interface())
+	}
+	result, err := db.ExecContext(ctx, "UPDATE "+r.table+" SET "+strings.Join(sets, ", ")+" WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return r.missing(ctx, db, id)
+	}
+	return nil
+}
+
+/*
+Patch 部分更新（PATCH）：把当前行序列化为 JSON，交给 patch 修改（JSON Merge Patch 或 JSON Patch），反序列化为新的 T，
+调用 prepare 恢复不参与序列化的字段（例如密码）并校验，然后只更新值发生变化的列，返回更新后重新查询的行
+补丁修改了不可修改的字段（主键、创建时间等）时返回 customerror.BadRequest
+补丁中带版本号时作为期望的版本号（乐观锁）；没有任何变化时不执行 UPDATE，直接返回当前行
+*/
+func (r *Repository[T]) Patch(ctx context.Context, db DBTX, id any, patch func(doc []byte) ([]byte, error), prepare func(current, patched *T) error) (*T, error) {
+	current, err := r.FindByID(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = patch(doc); err != nil {
+		return nil, err
+	}
+	var patched T
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return nil, customerror.BadRequest("patched resource is invalid: " + err.Error())
+	}
+	if err := prepare(current, &patched); err != nil {
+		return nil, err
+	}
+
+	if f, err := r.readOnlyChanged(current, &patched); err != nil {
+		return nil, err
+	} else if f != nil {
+		return nil, customerror.BadRequest(fmt.Sprintf("field %q is read-only", f.jsonName))
+	}
+	columns := r.Changed(current, &patched)
+	sameVersion := r.meta.version == nil || reflect.DeepEqual(
+		reflect.ValueOf(current).Elem().FieldByIndex(r.meta.version.index).Interface(),
+		reflect.ValueOf(&patched).Elem().FieldByIndex(r.meta.version.index).Interface())
+	if len(columns) == 0 && sameVersion {
+		return current, nil
+	}
+	if err := r.UpdateColumns(ctx, db, &patched, columns); err != nil {
+		return nil, err
+	}
+	return r.FindByID(ctx, db, id)
+}
+
+// Delete 按主键删除，有 softdelete 字段时为软删除（同时更新修改时间、操作者和版本号），不存在时返回 sql.ErrNoRows
+func (r *Repository[T]) Delete(ctx context.Context, db DBTX, id any) error {
+	if r.meta.softDelete == nil {
+		return r.HardDelete(ctx, db, id)
+	}
+	now := time.Now()
+	sets, args := r.auditSets(ctx, now)
+	sets = append([]string{r.meta.softDelete.column + " = ?"}, sets...)
+	args = append([]any{now}, args...)
+	return r.execOne(ctx, db, "UPDATE "+r.table+" SET "+strings.Join(sets, ", ")+
+		" WHERE "+r.meta.pk.column+" = ? AND "+r.meta.softDelete.column+" IS NULL", append(args, id)...)
+}
+
+/*
+DeleteMany 按主键批量删除（有 softdelete 字段时为软删除），返回实际删除的主键，不存在或已删除的主键不在结果中
+先用 SELECT ... FOR UPDATE 锁定存在的行再删除，应该在事务中调用；主键必须是整数
+*/
+func (r *Repository[T]) DeleteMany(ctx context.Context, db DBTX, ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := r.meta.pk.column + " IN (" + placeholders(len(ids)) + ")"
+	rows, err := db.QueryContext(ctx, "SELECT "+r.meta.pk.column+" FROM "+r.table+" WHERE "+r.scoped(in)+" FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	var existing []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing = append(existing, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	args = args[:0]
+	for _, id := range existing {
+		args = append(args, id)
+	}
+	in = r.meta.pk.column + " IN (" + placeholders(len(existing)) + ")"
+	if r.meta.softDelete == nil {
+		_, err = db.ExecContext(ctx, "DELETE FROM "+r.table+" WHERE "+in, args...)
+		return existing, err
+	}
+	now := time.Now()
+	sets, setArgs := r.auditSets(ctx, now)
+	sets = append([]string{r.meta.softDelete.column + " = ?"}, sets...)
+	setArgs = append([]any{now}, setArgs...)
+	_, err = db.ExecContext(ctx, "UPDATE "+r.table+" SET "+strings.Join(sets, ", ")+" WHERE "+in, append(setArgs, args...)...)
+	return existing, err
+}
+
+// Restore 恢复软删除的行，行不存在或没有被删除时返回 sql.ErrNoRows
+func (r *Repository[T]) Restore(ctx context.Context, db DBTX, id any) error {
+	if r.meta.softDelete == nil {
+		return fmt.Errorf("repository: %s does not support soft delete", r.table)
+	}
+	sets, args := r.auditSets(ctx, time.Now())
+	sets = append([]string{r.meta.softDelete.column + " = NULL"}, sets...)
+	return r.execOne(ctx, db, "UPDATE "+r.table+" SET "+strings.Join(sets, ", ")+
+		" WHERE "+r.meta.pk.column+" = ? AND "+r.meta.softDelete.column+" IS NOT NULL", append(args, id)...)
+}
+
+// HardDelete 按主键物理删除（包括已软删除的行），不存在时返回 sql.ErrNoRows
+func (r *Repository[T]) HardDelete(ctx context.Context, db DBTX, id any) error {
+	return r.execOne(ctx, db, "DELETE FROM "+r.table+" WHERE "+r.meta.pk.column+" = ?", id)
+}
+
+/*
+Purge 物理删除 before 之前软删除的行，每批删除 batch 行（避免长时间锁表和大事务），返回删除的总行数
+一般由定时任务调用，db 传 *sql.DB，每批自动提交
+*/
+func (r *Repository[T]) Purge(ctx context.Context, db DBTX, before time.Time, batch int) (int64, error) {
+	if r.meta.softDelete == nil {
+		return 0, fmt.Errorf("repository: %s does not support soft delete", r.table)
+	}
+	var total int64
+	for {
+		result, err := db.ExecContext(ctx, "DELETE FROM "+r.table+" WHERE "+r.meta.softDelete.column+" < ? LIMIT ?", before, batch)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+	}
+}
+
+// auditSets 修改时间、操作者和版本号的 SET 子句（软删除、恢复时使用）
+func (r *Repository[T]) auditSets(ctx context.Context, now time.Time) ([]string, []any) {
+	var sets []string
+	var args []any
+	for _, f := range r.meta.fields {
+		switch {
+		case f.updated:
+			sets, args = append(sets, f.column+" = ?"), append(args, now)
+		case f.updatedBy:
+			sets, args = append(sets, f.column+" = ?"), append(args, principal(ctx))
+		case f.version:
+			sets = append(sets, f.column+" = "+f.column+" + 1")
+		}
+	}
+	return sets, args
+}
+
+// execOne 执行只应该影响一行的语句，没有影响到行时返回 sql.ErrNoRows
+func (r *Repository[T]) execOne(ctx context.Context, db DBTX, query string, args ...any) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// missing 更新没有影响到行时判断原因：行不存在返回 sql.ErrNoRows，有版本号时返回 ErrStaleVersion，否则为数据没有变化
+func (r *Repository[T]) missing(ctx context.Context, db DBTX, id any) error {
+	var one int
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM "+r.table+" WHERE "+r.scoped(r.meta.pk.column+" = ?"), id).Scan(&one)
+	if err != nil {
+		return err
+	}
+	if r.meta.version != nil {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// setInt 设置整数字段（有符号或无符号）
+func setInt(fv reflect.Value, n int64) {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(n))
+	}
+}
+
+// placeholders n 个逗号分隔的占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
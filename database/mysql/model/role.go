@@ -0,0 +1,116 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"mysql/auth"
+	"mysql/customerror"
+	"mysql/validate"
+	"time"
+)
+
+// ErrProtectedRole 管理员角色不能删除或改名，否则所有人都会失去管理权限
+var ErrProtectedRole = customerror.Conflict(fmt.Sprintf("the %s role cannot be renamed or deleted", auth.AdminRole))
+
+// Role 角色，通过 user_roles 分配给用户，通过 role_permissions 授予权限
+type Role struct {
+	//主键
+	Id int `json:"id" db:"id,pk"`
+	//创建时间（插入时自动设置）
+	CreatedAt time.Time `json:"createdAt" db:"created_at,created"`
+	//创建者（插入时自动设置为当前登录用户）
+	CreatedBy string `json:"createdBy" db:"created_by,createdby"`
+	//修改时间（插入和更新时自动设置）
+	UpdateAt time.Time `json:"updateAt" db:"update_at,updated"`
+	//修改者（插入和更新时自动设置为当前登录用户）
+	UpdateBy string `json:"updateBy" db:"update_by,updatedby"`
+	//角色名，例如 admin、editor
+	Name string `json:"name" db:"name" validate:"required,max=64,pattern=identifier"`
+	//描述
+	Description string `json:"description" db:"description" validate:"max=255"`
+	//版本号（乐观锁），更新时必须带上读取到的版本号
+	Version int `json:"version" db:"version,version"`
+}
+
+func init() {
+	validate.RegisterPattern("identifier", `^[a-z][a-z0-9_-]*$`)
+}
+
+// roleRepo roles 表的通用增删改查
+var roleRepo = NewRepository[Role]("roles")
+
+// RoleSortable 允许排序的字段（JSON字段名 -> 列名）
+var RoleSortable = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"createdAt": "created_at",
+}
+
+// RoleFilterable 允许等值过滤的字段（JSON字段名 -> 列名）
+var RoleFilterable = map[string]string{
+	"name": "name",
+}
+
+// Create 创建角色（在 ctx 的事务中执行），角色名重复时返回 ErrDuplicate
+func (role *Role) Create(ctx context.Context) (int64, error) {
+	id, err := roleRepo.Insert(ctx, executor(ctx), role)
+	if isDuplicate(err) {
+		return 0, duplicate("role %q already exists", role.Name)
+	}
+	return id, err
+}
+
+// FindRoleByID 获取角色，不存在时返回 sql.ErrNoRows
+func FindRoleByID(ctx context.Context, id int) (*Role, error) {
+	return roleRepo.FindByID(ctx, reader(ctx), id)
+}
+
+// ListRoles 分页查询角色，q 中的列名需来自 RoleSortable/RoleFilterable
+func ListRoles(ctx context.Context, q ListQuery) (*Page[Role], error) {
+	return list(ctx, reader(ctx), roleRepo.Table(), roleRepo.Columns(), "id", "", q, roleRepo.Scan, roleRepo.ColumnValue)
+}
+
+/*
+Update 更新角色（在 ctx 的事务中执行），管理员角色不能改名（ErrProtectedRole）
+角色名重复时返回 ErrDuplicate；版本号不一致时返回 ErrStaleVersion；角色不存在时返回 sql.ErrNoRows
+*/
+func (role *Role) Update(ctx context.Context) error {
+	current, err := FindRoleByID(ctx, role.Id)
+	if err != nil {
+		return err
+	}
+	if current.Name == auth.AdminRole && role.Name != current.Name {
+		return ErrProtectedRole
+	}
+	err = roleRepo.Update(ctx, executor(ctx), role)
+	if isDuplicate(err) {
+		return duplicate("role %q already exists", role.Name)
+	}
+	return err
+}
+
+// PatchRole 部分更新角色（在 ctx 的事务中执行，见 Repository.Patch），错误与 Update 相同
+func PatchRole(ctx context.Context, id int, patch func(doc []byte) ([]byte, error)) (*Role, error) {
+	role, err := roleRepo.Patch(ctx, executor(ctx), id, patch, func(current, patched *Role) error {
+		if current.Name == auth.AdminRole && patched.Name != current.Name {
+			return ErrProtectedRole
+		}
+		return validate.Struct(patched)
+	})
+	if isDuplicate(err) {
+		return nil, duplicate("role name already exists")
+	}
+	return role, err
+}
+
+// DeleteRole 删除角色（在 ctx 的事务中执行），同时删除分配给用户的关联和授予的权限，管理员角色不能删除
+func DeleteRole(ctx context.Context, id int) error {
+	role, err := FindRoleByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role.Name == auth.AdminRole {
+		return ErrProtectedRole
+	}
+	return roleRepo.Delete(ctx, executor(ctx), id)
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"log"
+	"mysql/config"
+	"mysql/replica"
+	"time"
+)
+
+// MySQL 死锁错误码，InnoDB 检测到死锁后回滚整个事务，可以重试
+const errLockDeadlock = 1213 //ER_LOCK_DEADLOCK
+
+// 默认的死锁重试次数
+const defaultTxRetries = 3
+
+// TxOptions 事务选项
+type TxOptions struct {
+	Isolation  sql.IsolationLevel //隔离级别，默认使用数据库的设置（MySQL 为 REPEATABLE READ）
+	ReadOnly   bool
+	MaxRetries int //死锁重试次数，0 使用默认 3 次，负数不重试
+}
+
+// txState 上下文中的事务
+type txState struct {
+	tx    *sql.Tx
+	depth int //嵌套层数，用于生成保存点名称
+}
+
+type txKey struct{}
+
+// WithTx 在 config.DB 上执行事务，见 WithTxDB
+func WithTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context) error) error {
+	return WithTxDB(ctx, config.DB, opts, fn)
+}
+
+/*
+WithTxDB 在事务中执行 fn：
+1.fn 返回 nil 时提交，返回错误或 panic 时回滚（panic 回滚后继续向上抛出）
+2.事务保存在 fn 的 ctx 中，model 的方法通过 executor(ctx) 自动加入该事务
+3.ctx 中已经有事务时（嵌套调用）使用保存点（SAVEPOINT），fn 失败只回滚到保存点，不影响外层事务
+4.最外层事务遇到死锁（MySQL 1213）时整个 fn 重新执行，所以 fn 不要有数据库以外的副作用
+5.非只读事务提交后记录当前请求写入过主库，之后的读操作不再走从库（见 replica.MarkWrite）
+*/
+func WithTxDB(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(ctx context.Context) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return withSavepoint(ctx, state, fn)
+	}
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = defaultTxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if !isDeadlock(err) || attempt >= retries {
+			return err
+		}
+		log.Printf("事务死锁，第 %d 次重试：%v", attempt+1, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt+1) * 20 * time.Millisecond):
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Printf("回滚事务失败：%v", rbErr)
+			}
+			return
+		}
+		if err = tx.Commit(); err == nil && !opts.ReadOnly {
+			replica.MarkWrite(ctx)
+		}
+	}()
+	return fn(context.WithValue(ctx, txKey{}, &txState{tx: tx}))
+}
+
+func withSavepoint(ctx context.Context, parent *txState, fn func(ctx context.Context) error) (err error) {
+	state := &txState{tx: parent.tx, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", state.depth)
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			state.tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(p)
+		}
+		if err != nil {
+			// 死锁时整个事务已经被回滚，保存点也不存在了，直接把错误交给最外层重试
+			if !isDeadlock(err) {
+				if _, rbErr := state.tx.Exec("ROLLBACK TO SAVEPOINT " + name); rbErr != nil {
+					log.Printf("回滚到保存点 %s 失败：%v", name, rbErr)
+				}
+			}
+			return
+		}
+		_, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	}()
+	return fn(context.WithValue(ctx, txKey{}, state))
+}
+
+// TxFromContext ctx 中的事务，不在事务中时返回 nil
+func TxFromContext(ctx context.Context) *sql.Tx {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return nil
+}
+
+// executor ctx 中有事务时返回事务，否则返回 config.DB（主库），写操作和需要强一致的读操作使用
+func executor(ctx context.Context) DBTX {
+	if tx := TxFromContext(ctx); tx != nil {
+		return tx
+	}
+	return config.DB
+}
+
+// reader ctx 中有事务时返回事务，否则返回 config.Reader 选择的数据库（配置了读写分离时为从库），可以容忍复制延迟的读操作使用
+func reader(ctx context.Context) DBTX {
+	if tx := TxFromContext(ctx); tx != nil {
+		return tx
+	}
+	return config.Reader(ctx)
+}
+
+func isDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errLockDeadlock
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"mysql/sqltest"
+	"testing"
+)
+
+func TestWithTxDB(t *testing.T) {
+	errFn := errors.New("fn failed")
+	deadlock := &mysql.MySQLError{Number: errLockDeadlock}
+	tests := []struct {
+		name    string
+		opts    *TxOptions
+		expect  func(db *sqltest.DB)
+		fn      func(ctx context.Context) error
+		wantErr error
+	}{
+		{
+			name: "commit",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("UPDATE users").WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			fn: func(ctx context.Context) error {
+				_, err := executor(ctx).ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "Alice", 1)
+				return err
+			},
+		},
+		{
+			name: "rollback on error",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectRollback()
+			},
+			fn:      func(ctx context.Context) error { return errFn },
+			wantErr: errFn,
+		},
+		{
+			name: "retry on deadlock",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("UPDATE users").WillReturnError(deadlock)
+				db.ExpectRollback()
+				db.ExpectBegin()
+				db.ExpectExec("UPDATE users").WillReturnResult(0, 1)
+				db.ExpectCommit()
+			},
+			fn: func(ctx context.Context) error {
+				_, err := executor(ctx).ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "Alice", 1)
+				return err
+			},
+		},
+		{
+			name: "no retry when disabled",
+			opts: &TxOptions{MaxRetries: -1},
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("UPDATE users").WillReturnError(deadlock)
+				db.ExpectRollback()
+			},
+			fn: func(ctx context.Context) error {
+				_, err := executor(ctx).ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "Alice", 1)
+				return err
+			},
+			wantErr: deadlock,
+		},
+		{
+			name: "nested failure rolls back to savepoint",
+			expect: func(db *sqltest.DB) {
+				db.ExpectBegin()
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec("ROLLBACK TO SAVEPOINT sp_1")
+				db.ExpectExec("SAVEPOINT sp_1")
+				db.ExpectExec("RELEASE SAVEPOINT sp_1")
+				db.ExpectCommit()
+			},
+			fn: func(ctx context.Context) error {
+				if err := WithTx(ctx, nil, func(ctx context.Context) error { return errFn }); !errors.Is(err, errFn) {
+					return errors.New("nested error was not returned")
+				}
+				return WithTx(ctx, nil, func(ctx context.Context) error { return nil })
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqltest.New(t)
+			tt.expect(db)
+			err := WithTxDB(context.Background(), db.DB, tt.opts, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithTxDB_Panic(t *testing.T) {
+	db := sqltest.New(t)
+	db.ExpectBegin()
+	db.ExpectRollback()
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recover() = %v, want boom", p)
+		}
+	}()
+	WithTxDB(context.Background(), db.DB, nil, func(ctx context.Context) error {
+		panic("boom")
+	})
+}
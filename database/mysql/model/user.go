@@ -1,8 +1,13 @@
 package model
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"iter"
+	"log"
 	"mysql/config"
+	"mysql/validate"
 	"time"
 )
 
@@ -17,59 +22,178 @@ TIMESTAMP	time.Time						时间戳（自动转换时区）
 */
 type User struct {
 	//主键
-	Id int `json:"id"`
-	//创建时间
-	CreatedAt time.Time `json:"createdAt"`
-	//创建者
-	CreatedBy string `json:"createdBy"`
-	//修改时间
-	UpdateAt time.Time `json:"updateAt"`
-	//修改者
-	UpdateBy string `json:"updateBy"`
+	Id int `json:"id" db:"id,pk"`
+	//创建时间（插入时自动设置）
+	CreatedAt time.Time `json:"createdAt" db:"created_at,created"`
+	//创建者（插入时自动设置为当前登录用户）
+	CreatedBy string `json:"createdBy" db:"created_by,createdby"`
+	//修改时间（插入和更新时自动设置）
+	UpdateAt time.Time `json:"updateAt" db:"update_at,updated"`
+	//修改者（插入和更新时自动设置为当前登录用户）
+	UpdateBy string `json:"updateBy" db:"update_by,updatedby"`
 	//用户名
-	Username string `json:"username"`
-	//密码
-	Password string `json:"password"`
+	Username string `json:"username" db:"username" validate:"required,min=3,max=32,pattern=username"`
+	//密码哈希（见 HashPassword），任何响应都不返回，Update 不修改
+	Password string `json:"-" db:"password,noupdate"`
 	//姓名
-	Name string `json:"name"`
+	Name string `json:"name" db:"name" validate:"required,max=64"`
+	//版本号（乐观锁），更新时必须带上读取到的版本号
+	Version int `json:"version" db:"version,version"`
+	//删除时间（软删除），未删除时为空
+	DeletedAt *time.Time `json:"deletedAt,omitempty" db:"deleted_at,softdelete"`
 }
 
-var baseSql = "id, created_at, created_by, update_at, update_by, username, password, name"
-var noIdSql = "created_at, created_by, update_at, update_by, username, password, name"
+func init() {
+	validate.RegisterPattern("username", `^[A-Za-z0-9_.-]+$`)
+}
 
-// Create 创建用户（包含事务控制）
-func (u *User) Create(tx *sql.Tx) (int64, error) {
-	result, err := tx.Exec(
-		"INSERT INTO users ("+noIdSql+") VALUES (?, ?, ?, ?, ?, ?, ?)",
-		u.CreatedAt, u.CreatedBy, u.UpdateAt, u.UpdateBy, u.Username, u.Password, u.Name,
-	)
-	if err != nil {
-		return 0, err
+// userRepo users 表的通用增删改查，SQL 由 User 的 db 标签生成
+var userRepo = NewRepository[User]("users")
+
+// UserSortable 允许排序的字段（JSON字段名 -> 列名），不在白名单中的字段不能拼进SQL
+var UserSortable = map[string]string{
+	"id":        "id",
+	"username":  "username",
+	"name":      "name",
+	"createdAt": "created_at",
+	"updateAt":  "update_at",
+}
+
+// UserFilterable 允许等值过滤的字段（JSON字段名 -> 列名）
+var UserFilterable = map[string]string{
+	"username":  "username",
+	"name":      "name",
+	"createdBy": "created_by",
+}
+
+// Create 创建用户（在 ctx 的事务中执行，见 WithTx），用户名重复时返回 ErrDuplicate
+func (u *User) Create(ctx context.Context) (int64, error) {
+	id, err := userRepo.Insert(ctx, executor(ctx), u)
+	if isDuplicate(err) {
+		return 0, duplicate("username %q already exists", u.Username)
 	}
-	return result.LastInsertId()
+	return id, err
 }
 
-// FindByID 获取用户
-func FindByID(id int) (*User, error) {
-	u := &User{}
+// FindByID 获取用户，不存在时返回 sql.ErrNoRows
+func FindByID(ctx context.Context, id int) (*User, error) {
 	//sqlStr := fmt.Sprintf("select id, name, age from user where name='%v'", id)//这种会有SQL注入问题。而应该使用预编译?代替这种方式
-	err := config.DB.QueryRow(
-		"SELECT "+baseSql+" FROM users WHERE id = ?", id,
-	).Scan(&u.Id, &u.CreatedAt, &u.CreatedBy, &u.UpdateAt, &u.UpdateBy, &u.Username, &u.Password, &u.Name)
-	return u, err
-}
-
-// Update 更新用户（包含事务控制）
-func (u *User) Update(tx *sql.Tx) error {
-	_, err := tx.Exec(
-		"UPDATE users SET created_at = ?, created_by = ?, update_at = ?, update_by = ?, username = ?, password = ?, name = ? WHERE id = ?",
-		u.CreatedAt, u.CreatedBy, u.UpdateAt, u.UpdateBy, u.Username, u.Password, u.Name, u.Id,
-	)
+	return userRepo.FindByID(ctx, reader(ctx), id)
+}
+
+// ListUsers 分页查询用户，q 中的列名需来自 UserSortable/UserFilterable
+func ListUsers(ctx context.Context, q ListQuery) (*Page[User], error) {
+	repo := userRepo
+	if q.WithDeleted {
+		repo = userRepo.WithDeleted()
+	}
+	return list(ctx, reader(ctx), repo.Table(), repo.Columns(), "id", repo.Scope(), q, repo.Scan, repo.ColumnValue)
+}
+
+// AllUsers 按ID顺序流式遍历用户（见 Repository.All），withDeleted 为 true 时包括已软删除的用户
+func AllUsers(ctx context.Context, withDeleted bool) iter.Seq2[User, error] {
+	repo := userRepo
+	if withDeleted {
+		repo = userRepo.WithDeleted()
+	}
+	return repo.All(ctx, reader(ctx))
+}
+
+/*
+Update 更新用户（在 ctx 的事务中执行），不修改密码和创建信息，修改密码见 UpdatePassword
+用户名重复时返回 ErrDuplicate；版本号与数据库不一致时返回 ErrStaleVersion；用户不存在时返回 sql.ErrNoRows
+*/
+func (u *User) Update(ctx context.Context) error {
+	err := userRepo.Update(ctx, executor(ctx), u)
+	if isDuplicate(err) {
+		return duplicate("username %q already exists", u.Username)
+	}
 	return err
 }
 
-// Delete 删除用户（包含事务控制）
-func Delete(tx *sql.Tx, id int) error {
-	_, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+/*
+PatchUser 部分更新用户（在 ctx 的事务中执行，见 Repository.Patch），密码不参与序列化，保持不变
+用户名重复时返回 ErrDuplicate；版本号不一致时返回 ErrStaleVersion
+*/
+func PatchUser(ctx context.Context, id int, patch func(doc []byte) ([]byte, error)) (*User, error) {
+	user, err := userRepo.Patch(ctx, executor(ctx), id, patch, func(current, patched *User) error {
+		patched.Password = current.Password
+		return validate.Struct(patched)
+	})
+	if isDuplicate(err) {
+		return nil, duplicate("username already exists")
+	}
+	return user, err
+}
+
+// FindByUsername 按用户名获取用户，不存在时返回 sql.ErrNoRows
+func FindByUsername(ctx context.Context, username string) (*User, error) {
+	return userRepo.FindOne(ctx, executor(ctx), "username = ?", username)
+}
+
+// UpdatePassword 保存新的密码哈希（在 ctx 的事务中执行）
+func UpdatePassword(ctx context.Context, id int, hash string) error {
+	result, err := executor(ctx).ExecContext(ctx, "UPDATE users SET password = ?, update_at = ?, update_by = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL",
+		hash, time.Now(), principal(ctx), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
 	return err
 }
+
+/*
+Authenticate 校验用户名和密码，失败统一返回 ErrInvalidCredentials
+校验通过且哈希需要升级（参数调整或历史明文密码）时顺便重新计算并保存，保存失败不影响登录
+*/
+func Authenticate(ctx context.Context, username, password string) (*User, error) {
+	u, err := FindByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		// 用户不存在时也计算一次哈希，避免通过响应时间判断用户名是否存在
+		HashPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, needsRehash, err := VerifyPassword(u.Password, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if needsRehash {
+		if err := rehashPassword(ctx, u.Id, password); err != nil {
+			log.Printf("升级用户 %d 的密码哈希失败：%v", u.Id, err)
+		}
+	}
+	return u, nil
+}
+
+func rehashPassword(ctx context.Context, id int, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return WithTx(ctx, nil, func(ctx context.Context) error {
+		return UpdatePassword(ctx, id, hash)
+	})
+}
+
+// Delete 软删除用户（在 ctx 的事务中执行），用户不存在或已删除时返回 sql.ErrNoRows
+func Delete(ctx context.Context, id int) error {
+	return userRepo.Delete(ctx, executor(ctx), id)
+}
+
+// Restore 恢复软删除的用户，用户不存在或没有被删除时返回 sql.ErrNoRows
+func Restore(ctx context.Context, id int) error {
+	return userRepo.Restore(ctx, executor(ctx), id)
+}
+
+// PurgeDeletedUsers 物理删除软删除时间超过 retention 的用户，返回删除的行数
+func PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	return userRepo.Purge(ctx, config.DB, time.Now().Add(-retention), 500)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// UserCSVHeader 导出用户 CSV 的表头，与 CSVRecord 的顺序一致（列名与 JSON 字段名相同）
+var UserCSVHeader = []string{"id", "username", "name", "createdAt", "createdBy", "updateAt", "updateBy", "version", "deletedAt"}
+
+// CSVRecord 用户的一行 CSV，时间为 RFC 3339 格式，未删除时 deletedAt 为空
+func (u *User) CSVRecord() []string {
+	var deletedAt string
+	if u.DeletedAt != nil {
+		deletedAt = u.DeletedAt.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(u.Id),
+		u.Username,
+		u.Name,
+		u.CreatedAt.Format(time.RFC3339),
+		u.CreatedBy,
+		u.UpdateAt.Format(time.RFC3339),
+		u.UpdateBy,
+		strconv.Itoa(u.Version),
+		deletedAt,
+	}
+}
@@ -0,0 +1,148 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer 从库的选择策略
+type Balancer string
+
+const (
+	RoundRobin Balancer = "round-robin" //轮询
+	LeastConn  Balancer = "least-conn"  //当前使用中的连接数最少
+)
+
+// Options 读写分离的配置
+type Options struct {
+	Balancer            Balancer
+	HealthCheckInterval time.Duration //健康检查间隔，0 使用 5 秒
+	ReadYourWrites      time.Duration //写入后同一客户端的读请求走主库的时长（覆盖复制延迟），0 表示只在当前请求内走主库
+}
+
+// Replica 一个从库
+type Replica struct {
+	Name string
+	DB   *sql.DB
+
+	healthy atomic.Bool
+}
+
+/*
+Cluster 一主多从的读写分离：
+1.写操作和事务使用主库（Primary），由调用方保证
+2.读操作用 Reader 选择一个健康的从库，没有健康的从库时回退到主库
+3.后台定期 Ping 从库，失败的从库被移出，恢复后重新加入
+4.请求中写入过（见 MarkWrite）或者被固定到主库（见 WithPrimary）的读操作使用主库，保证读到自己的写入
+*/
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*Replica
+	opts     Options
+	next     atomic.Uint64
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// New 创建读写分离集群并开始健康检查，不再使用时调用 Close 停止（不会关闭连接池）
+func New(primary *sql.DB, replicas []*Replica, opts Options) *Cluster {
+	if opts.Balancer == "" {
+		opts.Balancer = RoundRobin
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = 5 * time.Second
+	}
+	c := &Cluster{primary: primary, replicas: replicas, opts: opts, stop: make(chan struct{})}
+	for _, r := range replicas {
+		r.healthy.Store(true)
+	}
+	c.done.Add(1)
+	go c.healthCheck()
+	return c
+}
+
+// Primary 主库
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Options 集群配置
+func (c *Cluster) Options() Options {
+	return c.opts
+}
+
+// Reader 读操作使用的数据库：ctx 被固定到主库时返回主库，否则按策略选择一个健康的从库，没有时返回主库
+func (c *Cluster) Reader(ctx context.Context) *sql.DB {
+	if Pinned(ctx) {
+		return c.primary
+	}
+	healthy := make([]*Replica, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return c.primary
+	}
+	if c.opts.Balancer == LeastConn {
+		best := healthy[0]
+		for _, r := range healthy[1:] {
+			if r.DB.Stats().InUse < best.DB.Stats().InUse {
+				best = r
+			}
+		}
+		return best.DB
+	}
+	return healthy[c.next.Add(1)%uint64(len(healthy))].DB
+}
+
+// Status 从库的健康状态，键为从库名称
+func (c *Cluster) Status() map[string]bool {
+	status := make(map[string]bool, len(c.replicas))
+	for _, r := range c.replicas {
+		status[r.Name] = r.healthy.Load()
+	}
+	return status
+}
+
+// Close 停止健康检查
+func (c *Cluster) Close() {
+	close(c.stop)
+	c.done.Wait()
+}
+
+func (c *Cluster) healthCheck() {
+	defer c.done.Done()
+	ticker := time.NewTicker(c.opts.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, r := range c.replicas {
+				c.check(r)
+			}
+		}
+	}
+}
+
+// check Ping 一个从库，状态变化时打印日志
+func (c *Cluster) check(r *Replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), min(c.opts.HealthCheckInterval, 2*time.Second))
+	defer cancel()
+	err := r.DB.PingContext(ctx)
+	if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Printf("从库 %s 已恢复，重新加入读负载", r.Name)
+		} else {
+			log.Printf("从库 %s 不可用，已移出读负载：%v", r.Name, err)
+		}
+	}
+}
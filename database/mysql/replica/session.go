@@ -0,0 +1,78 @@
+package replica
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// PinCookie 写入后把客户端固定到主库的 Cookie，值为过期时间（Unix 秒）
+const PinCookie = "db_primary_until"
+
+// session 一个请求的读写状态
+type session struct {
+	pinned  atomic.Bool
+	w       http.ResponseWriter
+	window  time.Duration
+	written atomic.Bool //Cookie 已经设置过
+}
+
+type sessionKey struct{}
+
+type primaryKey struct{}
+
+/*
+Session 读你所写（read your writes）中间件：
+1.请求中写入后（MarkWrite），同一请求之后的读操作都走主库
+2.window 大于 0 时写入后设置 Cookie，window 内同一客户端的请求都走主库，覆盖从库的复制延迟
+*/
+func Session(window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := &session{w: w, window: window}
+			if c, err := r.Cookie(PinCookie); err == nil {
+				if until, err := strconv.ParseInt(c.Value, 10, 64); err == nil && time.Now().Unix() < until {
+					s.pinned.Store(true)
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, s)))
+		})
+	}
+}
+
+// MarkWrite 记录当前请求写入了主库（提交事务后调用），不在 Session 中间件中时不做任何事
+func MarkWrite(ctx context.Context) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return
+	}
+	s.pinned.Store(true)
+	if s.window > 0 && s.written.CompareAndSwap(false, true) {
+		until := time.Now().Add(s.window)
+		// 响应头已经写出时设置无效，写操作一般在写响应之前完成
+		http.SetCookie(s.w, &http.Cookie{
+			Name:     PinCookie,
+			Value:    strconv.FormatInt(until.Unix(), 10),
+			Path:     "/",
+			Expires:  until,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// WithPrimary 返回固定到主库的 ctx，之后的读操作都走主库（需要强一致的读取，例如校验密码）
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Pinned ctx 的读操作是否必须走主库
+func Pinned(ctx context.Context) bool {
+	if pinned, _ := ctx.Value(primaryKey{}).(bool); pinned {
+		return true
+	}
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.pinned.Load()
+}
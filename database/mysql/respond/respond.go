@@ -0,0 +1,99 @@
+package respond
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"mysql/customerror"
+	"mysql/validate"
+	"net/http"
+)
+
+// RequestIDHeader 请求ID的请求头/响应头
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+/*
+RequestID 中间件：使用请求头 X-Request-ID（网关传入），没有时生成一个，
+放入请求上下文并写回响应头，错误响应和日志都带上它，方便排查
+*/
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext 当前请求的ID，没有经过 RequestID 中间件时为空
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ErrorBody 错误响应体
+type ErrorBody struct {
+	Code      customerror.Code `json:"code"`
+	Message   string           `json:"message"`
+	RequestID string           `json:"requestId,omitempty"`
+	Details   interface{}      `json:"details,omitempty"`
+}
+
+// JSON 以 JSON 格式写响应
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+/*
+Error 把错误转换为统一的 JSON 错误响应（见 ErrorBodyOf），500 错误记录日志
+*/
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	body := ErrorBodyOf(err)
+	body.RequestID = RequestIDFromContext(r.Context())
+	status := body.Code.Status()
+	if status >= http.StatusInternalServerError || err.Error() != body.Message {
+		// 响应中只有错误自身的信息，包装时补充的上下文和底层错误只记录在日志中
+		log.Printf("请求 %s %s 失败，request_id=%s：%v", r.Method, r.URL.Path, body.RequestID, err)
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	JSON(w, status, body)
+}
+
+/*
+ErrorBodyOf 把错误转换为错误响应体（不含请求ID），批量接口中每一项的错误也使用它：
+1.customerror.AppError 按错误码映射状态码，message 只取 AppError 自身的 Message，不包括包装的上下文和底层错误
+2.validate.Errors 为 400，details 为字段错误列表
+3.sql.ErrNoRows 为 404
+4.其他错误为 500，只返回通用信息，原始错误（可能包含SQL、驱动信息）不返回给前端
+*/
+func ErrorBodyOf(err error) ErrorBody {
+	var body ErrorBody
+	var appErr *customerror.AppError
+	var fields validate.Errors
+	switch {
+	case errors.As(err, &fields):
+		body.Code, body.Message, body.Details = customerror.CodeValidation, "validation failed", fields
+	case errors.As(err, &appErr) && appErr.Code != customerror.CodeInternal:
+		body.Code, body.Message, body.Details = appErr.Code, appErr.Message, appErr.Details
+	case errors.Is(err, sql.ErrNoRows):
+		body.Code, body.Message = customerror.CodeNotFound, "resource not found"
+	default:
+		body.Code, body.Message = customerror.CodeInternal, "internal server error"
+	}
+	return body
+}
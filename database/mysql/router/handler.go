@@ -1,54 +1,103 @@
 package router
 
 import (
+	"context"
 	"fmt"
+	"mysql/auth"
+	"mysql/customerror"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
+var (
+	ErrMissingID = customerror.BadRequest("missing resource ID") //路由中没有ID参数（例如 PUT /users）
+	errInvalidID = customerror.BadRequest("invalid resource ID")
+)
+
 // ResourceHandler 定义资源操作接口
 type ResourceHandler interface {
 	Create(w http.ResponseWriter, r *http.Request)
 	FindByID(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
 }
 
-// RegisterResource 通用资源注册函数，功能有限。企业级开发推荐使用第三方开源库：GORM、sqlx等
-func RegisterResource(path string, handler ResourceHandler) {
-	// 注册 /{path} 路由
-	http.HandleFunc("/"+path, func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("请求方法：%s，请求路径：%s\n", r.Method, path)
-		switch r.Method {
-		case http.MethodPost:
-			handler.Create(w, r)
-		case http.MethodPut:
-			handler.Update(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-
-	// 注册 /{path}/{id} 路由
-	http.HandleFunc("/"+path+"/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("请求方法：%s，请求路径：%s\n", r.Method, path)
-		switch r.Method {
-		case http.MethodGet:
-			handler.FindByID(w, r)
-		case http.MethodDelete:
-			handler.Delete(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+/*
+Access 资源每个HTTP方法允许的角色，例如 Access{http.MethodPost: {"admin"}}
+没有列出的方法（或角色为空）只要求登录，所有方法都需要携带有效令牌
+*/
+type Access map[string][]string
+
+// idParamKey 上下文中当前资源ID的路径参数名
+type idParamKey struct{}
+
+/*
+Resource 注册资源的标准路由（name 为复数名词，例如 users；param 为ID的路径参数名，例如 id）：
+
+	GET    /users       List
+	POST   /users       Create
+	PUT    /users       Update（ID 在请求体中，兼容旧接口）
+	GET    /users/{id}  FindByID
+	PUT    /users/{id}  Update
+	PATCH  /users/{id}  Patch
+	DELETE /users/{id}  Delete
+
+每个方法按 access 包装认证和授权中间件。返回前缀为 /users/{id} 的路由组，用于注册自定义动作（POST /users/{id}/password）
+和子资源：子资源的 param 不能与父资源的重名，例如 user.Resource("roles", "roleId", ...) 注册 /users/{id}/roles/{roleId}，
+重名属于编程错误，直接 panic
+处理函数统一用 ID 读取当前资源的ID，父资源的ID用 r.PathValue 读取
+企业级开发推荐使用第三方开源库：GORM、sqlx等
+*/
+func (rt *Router) Resource(name, param string, handler ResourceHandler, access Access) *Router {
+	if strings.Contains(rt.prefix, "{"+param+"}") {
+		panic(fmt.Sprintf("router: resource %s: path parameter {%s} is already used in %s", name, param, rt.prefix))
+	}
+	withParam := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), idParamKey{}, param)))
+		})
+	}
+	protect := func(method string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return auth.RequireRoles(access[method], next)
 		}
-	})
+	}
+	resource := rt.Group("/"+name, withParam)
+	resource.HandleFunc(http.MethodGet, "", handler.List, protect(http.MethodGet))
+	resource.HandleFunc(http.MethodPost, "", handler.Create, protect(http.MethodPost))
+	resource.HandleFunc(http.MethodPut, "", handler.Update, protect(http.MethodPut))
+	item := "/{" + param + "}"
+	resource.HandleFunc(http.MethodGet, item, handler.FindByID, protect(http.MethodGet))
+	resource.HandleFunc(http.MethodPut, item, handler.Update, protect(http.MethodPut))
+	resource.HandleFunc(http.MethodPatch, item, handler.Patch, protect(http.MethodPatch))
+	resource.HandleFunc(http.MethodDelete, item, handler.Delete, protect(http.MethodDelete))
+	return resource.Group(item)
 }
 
-// ExtractIDFromPath 通用路径ID提取函数
-func ExtractIDFromPath(path, resource string) (int, error) {
-	segments := strings.Split(path, "/")
-	if len(segments) < 3 || segments[1] != resource {
-		return 0, fmt.Errorf("invalid path format")
+/*
+ID 当前资源的整数ID：在 Resource 注册的路由中为注册时指定的参数（例如 {id}、子资源的 {roleId}），其他路由为 {id}
+路径中没有ID时返回 ErrMissingID，不是正整数时返回 customerror.BadRequest
+*/
+func ID(r *http.Request) (int, error) {
+	param, ok := r.Context().Value(idParamKey{}).(string)
+	if !ok {
+		param = "id"
+	}
+	return IntParam(r, param)
+}
+
+// IntParam 整数路径参数（例如子资源的 {roleId}），没有该参数时返回 ErrMissingID，不是正整数时返回 customerror.BadRequest
+func IntParam(r *http.Request, name string) (int, error) {
+	value := r.PathValue(name)
+	if value == "" {
+		return 0, ErrMissingID
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
 	}
-	return strconv.Atoi(segments[2])
+	return id, nil
 }
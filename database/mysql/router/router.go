@@ -0,0 +1,148 @@
+package router
+
+import (
+	"fmt"
+	"mysql/customerror"
+	"mysql/respond"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	errMethodNotAllowed = customerror.NewError(customerror.CodeMethodNotAllowed, "method not allowed")
+	errRouteNotFound    = customerror.NotFound("route not found")
+)
+
+// Middleware 中间件，包装一个处理器返回新的处理器
+type Middleware func(http.Handler) http.Handler
+
+// Chain 把多个中间件组合为一个，按参数顺序从外到内执行：Chain(a, b)(h) 等价于 a(b(h))
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+/*
+Router 基于 Go 1.22 ServeMux 模式（"GET /users/{id}"）的路由，在 ServeMux 之上增加：
+1.路由组：Group 返回带路径前缀和中间件的子路由，子路由共享同一个 ServeMux，可以继续嵌套
+2.中间件链：路由组的中间件 + 注册路由时传入的中间件，按顺序从外到内执行
+3.路径存在但方法不匹配时返回 JSON 格式的 405 和 Allow 头（ServeMux 自带的 405 是纯文本）
+4.OPTIONS 返回 204 和 Allow 头；HEAD 由 ServeMux 自动匹配 GET 路由
+路径参数用 r.PathValue 读取，资源ID见 ID
+*/
+type Router struct {
+	mux         *http.ServeMux
+	prefix      string
+	middlewares []Middleware
+	methods     *methodSet //所有路由注册过的方法，所有路由组共享
+}
+
+// methodSet 注册过的HTTP方法，用于计算 Allow 头
+type methodSet struct {
+	mu      sync.RWMutex
+	methods []string
+}
+
+// New 创建路由
+func New() *Router {
+	return &Router{mux: http.NewServeMux(), methods: &methodSet{}}
+}
+
+// Use 追加中间件，只影响之后注册的路由
+func (rt *Router) Use(mws ...Middleware) {
+	rt.middlewares = append(rt.middlewares, mws...)
+}
+
+// Group 创建路由组：路径前缀为当前前缀 + prefix，中间件为当前中间件 + mws，对组的修改不影响当前路由
+func (rt *Router) Group(prefix string, mws ...Middleware) *Router {
+	return &Router{
+		mux:         rt.mux,
+		prefix:      rt.prefix + prefix,
+		middlewares: append(slices.Clone(rt.middlewares), mws...),
+		methods:     rt.methods,
+	}
+}
+
+/*
+Handle 注册路由，path 相对于路由组前缀，可以使用 ServeMux 的通配符（{id}、{path...}）
+method 为空时匹配所有方法；模式冲突时 ServeMux 会 panic（属于编程错误）
+*/
+func (rt *Router) Handle(method, path string, h http.Handler, mws ...Middleware) {
+	pattern := rt.prefix + path
+	if method != "" {
+		pattern = method + " " + pattern
+		rt.methods.add(method)
+	}
+	rt.mux.Handle(pattern, Chain(append(slices.Clone(rt.middlewares), mws...)...)(h))
+}
+
+// HandleFunc 注册处理函数，见 Handle
+func (rt *Router) HandleFunc(method, path string, h http.HandlerFunc, mws ...Middleware) {
+	rt.Handle(method, path, h, mws...)
+}
+
+// ServeHTTP 实现 http.Handler，路径匹配但方法不匹配时返回 405（OPTIONS 返回 204），都不匹配时返回 404
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+	allowed := rt.allowed(r)
+	if len(allowed) == 0 {
+		respond.Error(w, r, errRouteNotFound)
+		return
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respond.Error(w, r, fmt.Errorf("%w: %s %s", errMethodNotAllowed, r.Method, r.URL.Path))
+}
+
+// allowed 请求路径可以使用的方法：用每个注册过的方法重新匹配一次
+func (rt *Router) allowed(r *http.Request) []string {
+	var allowed []string
+	probe := *r
+	for _, method := range rt.methods.list() {
+		probe.Method = method
+		if _, pattern := rt.mux.Handler(&probe); pattern != "" {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+	if len(allowed) > 0 && !slices.Contains(allowed, http.MethodOptions) {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+func (s *methodSet) add(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.methods, method) {
+		s.methods = append(s.methods, method)
+	}
+}
+
+func (s *methodSet) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.methods)
+}
+
+// Logger 打印请求方法和路径的中间件
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("请求方法：%s，请求路径：%s\n", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
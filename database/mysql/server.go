@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mysql/controller"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTP 服务器的地址和超时设置
+const (
+	serverAddr        = ":8080"
+	readHeaderTimeout = 5 * time.Second   //读取请求头的超时，防止慢速攻击占满连接
+	readTimeout       = 30 * time.Second  //读取整个请求的超时，批量接口会自行延长（见 controller.decodeBulk）
+	writeTimeout      = 60 * time.Second  //写响应的超时，导出和批量接口会自行延长（见 controller.Export、controller.decodeBulk）
+	idleTimeout       = 120 * time.Second //keep-alive 连接的空闲超时
+	shutdownTimeout   = 30 * time.Second  //关闭时等待处理中的请求完成的最长时间
+)
+
+// 关闭前等待负载均衡摘除实例的时长，默认 defaultDrainDelay，可以用环境变量设置（例如 "15s"，"0s" 表示不等待）
+const (
+	drainDelayEnv     = "SERVER_DRAIN_DELAY"
+	defaultDrainDelay = 5 * time.Second
+)
+
+// loadDrainDelay 读取 SERVER_DRAIN_DELAY，未设置或格式错误时使用默认值
+func loadDrainDelay() time.Duration {
+	s := os.Getenv(drainDelayEnv)
+	if s == "" {
+		return defaultDrainDelay
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		log.Printf("%s=%q 无效，使用默认值 %s", drainDelayEnv, s, defaultDrainDelay)
+		return defaultDrainDelay
+	}
+	return d
+}
+
+/*
+serve 启动 HTTP 服务器，ctx 取消（收到 SIGINT/SIGTERM）时优雅关闭：
+1.就绪检查开始返回 503（见 controller.Health.Drain），继续处理请求 drainDelay，
+等负载均衡的健康检查发现 503 并摘除实例，期间新请求仍然正常处理
+2.停止接受新连接，关闭空闲连接，等待处理中的请求完成，最多等待 shutdownTimeout
+3.超时后强制关闭剩余的连接并返回错误
+serve 返回后调用方再关闭数据库连接池，保证处理中的请求不会用到已关闭的连接池
+*/
+func serve(ctx context.Context, handler http.Handler, health *controller.Health, drainDelay time.Duration) error {
+	srv := &http.Server{
+		Addr:              serverAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("服务器运行在 %s", serverAddr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		// 监听失败（例如端口被占用）
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("收到退出信号，就绪检查返回 503，%s 后停止接受新请求", drainDelay)
+	health.Drain()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(drainDelay):
+	}
+
+	log.Printf("停止接受新请求，最多等待 %s 处理中的请求完成", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("服务器已关闭")
+	return nil
+}
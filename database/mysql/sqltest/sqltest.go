@@ -0,0 +1,408 @@
+/*
+Package sqltest 测试用的内存 database/sql 驱动，不需要 MySQL：
+1.测试按顺序编排预期的语句（ExpectBegin/ExpectQuery/ExpectExec/ExpectCommit/ExpectRollback），并给出返回的行、影响行数或错误
+2.代码执行的语句必须按顺序与预期一致：SQL 包含预期的片段，参数与 WithArgs 相同（Any 匹配任意值）
+3.不符合预期的语句返回错误并让测试失败；测试结束时检查所有预期都已执行
+
+用法：
+
+	db := sqltest.New(t)
+	db.ExpectBegin()
+	db.ExpectExec("INSERT INTO users").WillReturnResult(1, 1)
+	db.ExpectCommit()
+	// 把 db.DB 交给被测代码
+*/
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 预期的操作类型
+const (
+	kindBegin    = "BEGIN"
+	kindCommit   = "COMMIT"
+	kindRollback = "ROLLBACK"
+	kindQuery    = "query"
+	kindExec     = "exec"
+)
+
+// Any 在 WithArgs 中匹配任意参数（例如当前时间）
+var Any = anyArg{}
+
+type anyArg struct{}
+
+// DB 内存数据库，嵌入的 *sql.DB 交给被测代码使用
+type DB struct {
+	*sql.DB
+	t testing.TB
+
+	mu       sync.Mutex
+	expected []*Expectation
+	executed []string //已执行的语句（按顺序），用于失败时的提示
+}
+
+// New 创建内存数据库，测试结束时关闭并检查所有预期都已执行（见 ExpectationsWereMet）
+func New(t testing.TB) *DB {
+	db := &DB{t: t}
+	db.DB = sql.OpenDB(&connector{db: db})
+	t.Cleanup(func() {
+		db.DB.Close()
+		if err := db.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return db
+}
+
+// Expectation 一条预期的操作
+type Expectation struct {
+	kind     string
+	sql      string
+	args     []any
+	hasArgs  bool
+	rows     *Rows
+	result   driver.Result
+	err      error
+	consumed bool
+}
+
+// ExpectBegin 预期开启事务
+func (db *DB) ExpectBegin() *Expectation {
+	return db.expect(kindBegin, "")
+}
+
+// ExpectCommit 预期提交事务
+func (db *DB) ExpectCommit() *Expectation {
+	return db.expect(kindCommit, "")
+}
+
+// ExpectRollback 预期回滚事务
+func (db *DB) ExpectRollback() *Expectation {
+	return db.expect(kindRollback, "")
+}
+
+// ExpectQuery 预期一条查询，执行的 SQL（合并空白后）必须包含 sql，默认返回空结果集
+func (db *DB) ExpectQuery(sql string) *Expectation {
+	return db.expect(kindQuery, sql)
+}
+
+// ExpectExec 预期一条不返回行的语句，执行的 SQL（合并空白后）必须包含 sql，默认影响 0 行
+func (db *DB) ExpectExec(sql string) *Expectation {
+	return db.expect(kindExec, sql)
+}
+
+func (db *DB) expect(kind, sql string) *Expectation {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	e := &Expectation{kind: kind, sql: compact(sql)}
+	db.expected = append(db.expected, e)
+	return e
+}
+
+// WithArgs 预期的参数，按 database/sql 的规则转换后比较（int 与 int64 相同），Any 匹配任意值
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args, e.hasArgs = args, true
+	return e
+}
+
+// WillReturnRows 查询返回的行
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult 语句的自增ID和影响行数
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID, rowsAffected}
+	return e
+}
+
+// WillReturnError 操作返回的错误（例如 &mysql.MySQLError{Number: 1062}）
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.sql == "" {
+		return e.kind
+	}
+	if e.hasArgs {
+		return fmt.Sprintf("%s %q %v", e.kind, e.sql, e.args)
+	}
+	return fmt.Sprintf("%s %q", e.kind, e.sql)
+}
+
+// ExpectationsWereMet 所有预期是否都已执行，没有时返回列出剩余预期的错误
+func (db *DB) ExpectationsWereMet() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var remaining []string
+	for _, e := range db.expected {
+		if !e.consumed {
+			remaining = append(remaining, "  "+e.String())
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	return fmt.Errorf("sqltest: %d expectations were not met:\n%s\nexecuted:\n  %s",
+		len(remaining), strings.Join(remaining, "\n"), strings.Join(db.executed, "\n  "))
+}
+
+// Executed 已执行的操作（BEGIN、COMMIT、ROLLBACK 和 SQL），按执行顺序
+func (db *DB) Executed() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.executed...)
+}
+
+// match 用下一条预期匹配实际的操作，不匹配时让测试失败并返回错误
+func (db *DB) match(kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	query = compact(query)
+	actual := kind
+	if query != "" {
+		actual = fmt.Sprintf("%s %q %v", kind, query, values(args))
+	}
+	db.executed = append(db.executed, actual)
+
+	var next *Expectation
+	for _, e := range db.expected {
+		if !e.consumed {
+			next = e
+			break
+		}
+	}
+	var err error
+	switch {
+	case next == nil:
+		err = fmt.Errorf("sqltest: unexpected %s, all expectations were already met", actual)
+	case next.kind != kind || !strings.Contains(query, next.sql):
+		err = fmt.Errorf("sqltest: unexpected %s, want %s", actual, next)
+	case next.hasArgs:
+		err = matchArgs(next.args, args)
+		if err != nil {
+			err = fmt.Errorf("sqltest: %s: %w", actual, err)
+		}
+	}
+	if err != nil {
+		db.t.Error(err)
+		return nil, err
+	}
+	next.consumed = true
+	return next, nil
+}
+
+func matchArgs(want []any, got []driver.NamedValue) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("want %d args, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if _, ok := w.(anyArg); ok {
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(w)
+		if err != nil {
+			return fmt.Errorf("arg %d: %w", i, err)
+		}
+		if !reflect.DeepEqual(v, got[i].Value) {
+			return fmt.Errorf("arg %d: want %#v, got %#v", i, v, got[i].Value)
+		}
+	}
+	return nil
+}
+
+func values(args []driver.NamedValue) []any {
+	vs := make([]any, len(args))
+	for i, arg := range args {
+		vs[i] = arg.Value
+	}
+	return vs
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+func compact(query string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(query, " "))
+}
+
+// Rows 查询返回的结果集
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+	err     error
+}
+
+// NewRows 创建结果集，列名只用于 Columns，扫描按列的顺序进行
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow 追加一行，值按 database/sql 的规则转换（int 转为 int64，nil 为 NULL）
+func (r *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("sqltest: row has %d values, want %d", len(values), len(r.columns)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		dv, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			panic(fmt.Sprintf("sqltest: column %s: %v", r.columns[i], err))
+		}
+		row[i] = dv
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+// RowError 读完所有行之后返回的错误（模拟读取结果时连接中断等）
+func (r *Rows) RowError(err error) *Rows {
+	r.err = err
+	return r
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type connector struct {
+	db *DB
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: c.db}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("sqltest: use sqltest.New")
+}
+
+// conn 实现 ExecerContext 和 QueryerContext，database/sql 不会预处理语句
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("sqltest: prepared statements are not supported")
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	e, err := c.db.match(kindBegin, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &tx{db: c.db}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.db.match(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.result == nil {
+		return result{}, nil
+	}
+	return e.result, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.db.match(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.rows == nil {
+		return &rows{Rows: &Rows{}}, nil
+	}
+	return &rows{Rows: e.rows}, nil
+}
+
+// CheckNamedValue 接受 database/sql 默认支持的所有类型
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) (err error) {
+	nv.Value, err = driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	return err
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t *tx) Commit() error {
+	e, err := t.db.match(kindCommit, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+func (t *tx) Rollback() error {
+	e, err := t.db.match(kindRollback, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+type rows struct {
+	*Rows
+	next int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
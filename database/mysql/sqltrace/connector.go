@@ -0,0 +1,353 @@
+package sqltrace
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// explainTimeout 执行 EXPLAIN 的超时时间
+const explainTimeout = 5 * time.Second
+
+// Options 语句跟踪的配置
+type Options struct {
+	LogQueries         bool          //打印每条语句（参数只打印类型）
+	SlowQueryThreshold time.Duration //超过该耗时的语句打印慢查询日志，0 表示不检测
+	ExplainSlowQueries bool          //慢查询日志附带 EXPLAIN 执行计划
+}
+
+/*
+Wrap 包装 driver.Connector，跟踪通过它执行的所有语句：
+1.按数据库名称和规范化的语句记录耗时直方图和错误次数，见 Handler
+2.LogQueries 时打印语句，参数只打印类型
+3.耗时超过 SlowQueryThreshold 时打印慢查询日志，ExplainSlowQueries 时在同一连接上执行 EXPLAIN 并附带执行计划
+用法：sql.OpenDB(sqltrace.Wrap("default", connector, opts))
+*/
+func Wrap(name string, c driver.Connector, opts Options) driver.Connector {
+	return &connector{Connector: c, name: name, opts: opts}
+}
+
+type connector struct {
+	driver.Connector
+	name string
+	opts Options
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	inner, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: inner, name: c.name, opts: c.opts}, nil
+}
+
+// conn 包装的连接，实现 database/sql 会使用的可选接口并转发给原连接
+type conn struct {
+	driver.Conn
+	name string
+	opts Options
+}
+
+var (
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var inner driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		inner, err = p.PrepareContext(ctx, query)
+	} else {
+		inner, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: inner, conn: c, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("sqltrace: driver does not support transaction options")
+	}
+	return c.Conn.Begin()
+}
+
+// ExecContext 原连接不支持（或返回 driver.ErrSkip）时 database/sql 会改为预处理后执行，由 stmt 记录
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args)
+	c.observe(query, args, start, err)
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		c.observe(query, args, start, err)
+		return nil, err
+	}
+	return &traceRows{Rows: rows, conn: c, query: query, args: args, start: start}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+/*
+observe 记录一次语句执行：
+1.driver.ErrSkip 表示原驱动要求 database/sql 换一种方式执行，不记录（换的方式会再次记录）
+2.慢查询执行成功时才 EXPLAIN，失败的连接可能已经不可用
+*/
+func (c *conn) observe(query string, args []driver.NamedValue, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	d := time.Since(start)
+	slow := c.opts.SlowQueryThreshold > 0 && d >= c.opts.SlowQueryThreshold
+	metrics.observe(c.name, normalize(query), d, err != nil, slow)
+
+	if c.opts.LogQueries {
+		if err != nil {
+			log.Printf("SQL[%s] %v %s 参数：%s 错误：%v", c.name, d, redact(query), redactArgs(args), err)
+		} else {
+			log.Printf("SQL[%s] %v %s 参数：%s", c.name, d, redact(query), redactArgs(args))
+		}
+	}
+	if slow {
+		plan := ""
+		if c.opts.ExplainSlowQueries && err == nil && explainable(query) {
+			plan = "\n执行计划：\n" + c.explain(query, args)
+		}
+		log.Printf("慢查询[%s] 耗时 %v（阈值 %v）：%s 参数：%s%s", c.name, d, c.opts.SlowQueryThreshold, redact(query), redactArgs(args), plan)
+	}
+}
+
+// explain 在原连接上执行 EXPLAIN（绕过包装，不计入指标），返回制表符分隔的执行计划，失败时返回错误信息
+func (c *conn) explain(query string, args []driver.NamedValue) string {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+	var s driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = p.PrepareContext(ctx, "EXPLAIN "+query)
+	} else {
+		s, err = c.Conn.Prepare("EXPLAIN " + query)
+	}
+	if err != nil {
+		return "EXPLAIN 失败：" + err.Error()
+	}
+	defer s.Close()
+	q, ok := s.(driver.StmtQueryContext)
+	if !ok {
+		return "EXPLAIN 失败：驱动不支持 StmtQueryContext"
+	}
+	rows, err := q.QueryContext(ctx, args)
+	if err != nil {
+		return "EXPLAIN 失败：" + err.Error()
+	}
+	defer rows.Close()
+
+	var b strings.Builder
+	columns := rows.Columns()
+	b.WriteString(strings.Join(columns, "\t"))
+	values := make([]driver.Value, len(columns))
+	for {
+		if err := rows.Next(values); err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(&b, "\nEXPLAIN 失败：%v", err)
+			}
+			break
+		}
+		b.WriteByte('\n')
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			switch v := v.(type) {
+			case nil:
+				b.WriteString("NULL")
+			case []byte:
+				b.Write(v)
+			default:
+				fmt.Fprint(&b, v)
+			}
+		}
+	}
+	return b.String()
+}
+
+// stmt 包装的预处理语句
+type stmt struct {
+	driver.Stmt
+	conn  *conn
+	query string
+}
+
+var (
+	_ driver.StmtExecContext   = (*stmt)(nil)
+	_ driver.StmtQueryContext  = (*stmt)(nil)
+	_ driver.NamedValueChecker = (*stmt)(nil)
+)
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("sqltrace: driver does not support StmtExecContext")
+	}
+	start := time.Now()
+	result, err := e.ExecContext(ctx, args)
+	s.conn.observe(s.query, args, start, err)
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("sqltrace: driver does not support StmtQueryContext")
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, args)
+	if err != nil {
+		s.conn.observe(s.query, args, start, err)
+		return nil, err
+	}
+	return &traceRows{Rows: rows, conn: s.conn, query: s.query, args: args, start: start}, nil
+}
+
+// CheckNamedValue 原语句不支持时交给连接检查，与 database/sql 的顺序一致
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+/*
+traceRows 包装的结果集，关闭时记录语句（耗时包括读取结果的时间），
+并转发 database/sql 会使用的可选接口（多结果集、列类型）
+*/
+type traceRows struct {
+	driver.Rows
+	conn  *conn
+	query string
+	args  []driver.NamedValue
+	start time.Time
+	err   error //读取结果时的错误
+}
+
+func (r *traceRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *traceRows) Close() error {
+	err := r.Rows.Close()
+	r.conn.observe(r.query, r.args, r.start, errors.Join(r.err, err))
+	return err
+}
+
+func (r *traceRows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *traceRows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *traceRows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return t.ColumnTypeScanType(index)
+	}
+	return reflect.TypeFor[any]()
+}
+
+func (r *traceRows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *traceRows) ColumnTypeLength(index int) (int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return t.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *traceRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return t.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *traceRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return t.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,132 @@
+package sqltrace
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSeries 最多记录的（数据库, 语句）组合数，超过后新的语句记为 otherStatement，防止标签数量失控
+const maxSeries = 1000
+
+const otherStatement = "other"
+
+// buckets 耗时直方图的上界（秒）
+var buckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type seriesKey struct {
+	db        string
+	statement string
+}
+
+// series 一种语句的统计
+type series struct {
+	counts []uint64 //每个桶的计数（不累加），最后一个为 +Inf
+	sum    float64
+	count  uint64
+	errors uint64
+	slow   uint64
+}
+
+// registry 所有语句的统计，Handler 以 Prometheus 文本格式输出
+type registry struct {
+	mu     sync.Mutex
+	series map[seriesKey]*series
+}
+
+var metrics = &registry{series: make(map[seriesKey]*series)}
+
+func (r *registry) observe(db, statement string, d time.Duration, failed, slow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := seriesKey{db, statement}
+	s, ok := r.series[key]
+	if !ok {
+		if len(r.series) >= maxSeries {
+			key.statement = otherStatement
+			s = r.series[key]
+		}
+		if s == nil {
+			s = &series{counts: make([]uint64, len(buckets)+1)}
+			r.series[key] = s
+		}
+	}
+	seconds := d.Seconds()
+	i, _ := slices.BinarySearch(buckets, seconds)
+	s.counts[i]++
+	s.sum += seconds
+	s.count++
+	if failed {
+		s.errors++
+	}
+	if slow {
+		s.slow++
+	}
+}
+
+/*
+Handler 以 Prometheus 文本格式（0.0.4）输出 SQL 指标，标签 db 为数据库名称，statement 为规范化的语句（见 normalize）：
+1.mysql_query_duration_seconds 语句耗时直方图（查询的耗时到结果集关闭为止）
+2.mysql_query_errors_total 执行失败的次数
+3.mysql_slow_queries_total 超过慢查询阈值的次数
+*/
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		metrics.write(bw)
+		bw.Flush()
+	})
+}
+
+func (r *registry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]seriesKey, 0, len(r.series))
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b seriesKey) int {
+		if c := strings.Compare(a.db, b.db); c != 0 {
+			return c
+		}
+		return strings.Compare(a.statement, b.statement)
+	})
+
+	fmt.Fprintln(w, "# HELP mysql_query_duration_seconds SQL statement latency in seconds.")
+	fmt.Fprintln(w, "# TYPE mysql_query_duration_seconds histogram")
+	for _, key := range keys {
+		s, labels := r.series[key], key.labels()
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "mysql_query_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "mysql_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.count)
+		fmt.Fprintf(w, "mysql_query_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "mysql_query_duration_seconds_count{%s} %d\n", labels, s.count)
+	}
+
+	fmt.Fprintln(w, "# HELP mysql_query_errors_total SQL statements that returned an error.")
+	fmt.Fprintln(w, "# TYPE mysql_query_errors_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "mysql_query_errors_total{%s} %d\n", key.labels(), r.series[key].errors)
+	}
+
+	fmt.Fprintln(w, "# HELP mysql_slow_queries_total SQL statements slower than the slow query threshold.")
+	fmt.Fprintln(w, "# TYPE mysql_slow_queries_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "mysql_slow_queries_total{%s} %d\n", key.labels(), r.series[key].slow)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (k seriesKey) labels() string {
+	return fmt.Sprintf(`db="%s",statement="%s"`, labelEscaper.Replace(k.db), labelEscaper.Replace(k.statement))
+}
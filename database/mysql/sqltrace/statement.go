@@ -0,0 +1,61 @@
+package sqltrace
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxStatementLen 指标中语句标签的最大长度
+const maxStatementLen = 256
+
+var (
+	spaces       = regexp.MustCompile(`\s+`)
+	literals     = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+	placeholders = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	valueGroups  = regexp.MustCompile(`(\(\?(?:\.\.\.)?\))(?:\s*,\s*\(\?(?:\.\.\.)?\))+`)
+)
+
+// redact 去掉语句中的字符串字面量（参数化的语句不受影响），合并空白，用于日志
+func redact(query string) string {
+	return literals.ReplaceAllString(strings.TrimSpace(spaces.ReplaceAllString(query, " ")), "?")
+}
+
+/*
+normalize 语句的指标标签，同一种语句得到相同的标签，避免标签数量随参数个数增长：
+1.去掉字符串字面量，合并空白（见 redact）
+2.IN (?, ?, ?) 合并为 IN (?...)，多行 VALUES (?...), (?...) 合并为 VALUES (?...)...
+3.超过 maxStatementLen 时截断
+*/
+func normalize(query string) string {
+	s := placeholders.ReplaceAllString(redact(query), "?...")
+	s = valueGroups.ReplaceAllString(s, "$1...")
+	if len(s) > maxStatementLen {
+		s = strings.ToValidUTF8(s[:maxStatementLen], "") + "..."
+	}
+	return s
+}
+
+// redactArgs 参数只打印类型（例如 [string int64 <nil>]），不打印值，避免密码哈希等敏感数据出现在日志中
+func redactArgs(args []driver.NamedValue) string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		if arg.Value == nil {
+			types[i] = "<nil>"
+			continue
+		}
+		types[i] = fmt.Sprintf("%T", arg.Value)
+	}
+	return "[" + strings.Join(types, " ") + "]"
+}
+
+// explainable 可以用 EXPLAIN 查看执行计划的语句
+func explainable(query string) bool {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch strings.ToUpper(verb) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package sqltrace
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []interface{}
+		want string
+	}{
+		{name: "no args", want: "[]"},
+		{name: "types only", args: []interface{}{"$argon2id$v=19$secret", int64(1), nil, 3.5, true, []byte("x"), time.Time{}},
+			want: "[string int64 <nil> float64 bool []uint8 time.Time]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]driver.NamedValue, len(tt.args))
+			for i, v := range tt.args {
+				args[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+			}
+			got := redactArgs(args)
+			if got != tt.want {
+				t.Errorf("redactArgs() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(got, "secret") {
+				t.Errorf("redactArgs() leaked an argument value: %q", got)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{query: "SELECT id FROM user WHERE id = ?", want: "SELECT id FROM user WHERE id = ?"},
+		{query: "SELECT id\n\tFROM user   WHERE name = 'Meta'", want: "SELECT id FROM user WHERE name = ?"},
+		{query: `UPDATE user SET password = "p@ss" WHERE id = 1`, want: "UPDATE user SET password = ? WHERE id = 1"},
+		{query: `SELECT 'it''s', 'a\'b', "x""y"`, want: "SELECT ?, ?, ?"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.query); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{query: "SELECT * FROM user WHERE id IN (?, ?, ?)", want: "SELECT * FROM user WHERE id IN (?...)"},
+		{query: "SELECT * FROM user WHERE id IN (?,?)", want: "SELECT * FROM user WHERE id IN (?...)"},
+		{query: "INSERT INTO t (a, b) VALUES (?, ?), (?, ?), (?, ?)", want: "INSERT INTO t (a, b) VALUES (?...)..."},
+		{query: "INSERT INTO t (a) VALUES (?), (?)", want: "INSERT INTO t (a) VALUES (?)..."},
+		{query: "SELECT * FROM user WHERE name = 'x' AND id = ?", want: "SELECT * FROM user WHERE name = ? AND id = ?"},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.query); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	long := "SELECT " + strings.Repeat("列", maxStatementLen)
+	got := normalize(long)
+	if !strings.HasSuffix(got, "...") || len(got) > maxStatementLen+3 {
+		t.Errorf("normalize(long) has length %d, want at most %d with ... suffix", len(got), maxStatementLen+3)
+	}
+	if !strings.HasPrefix(got, "SELECT 列") || strings.ContainsRune(got, '�') {
+		t.Errorf("normalize(long) must cut on a UTF-8 boundary: %q", got[:20])
+	}
+}
+
+func TestExplainable(t *testing.T) {
+	for query, want := range map[string]bool{
+		"select * from user":              true,
+		"  WITH x AS (SELECT 1) SELECT 1": true,
+		"DELETE FROM user WHERE id = ?":   true,
+		"SHOW TABLES":                     false,
+		"CREATE TABLE t (id INT)":         false,
+		"":                                false,
+	} {
+		if got := explainable(query); got != want {
+			t.Errorf("explainable(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+Struct 按结构体字段的 validate 标签校验，多个规则用逗号分隔：
+required      必填（字符串不能为空白，数字不能为0，切片/映射不能为空，指针不能为nil）
+min=n,max=n   字符串为字符数，数字为数值，切片/映射为元素个数
+len=n         字符串字符数或切片长度必须等于 n
+email         邮箱格式
+pattern=name  匹配用 RegisterPattern 注册的正则（正则中可能有逗号，所以按名称引用）
+oneof=a|b|c   必须是其中一个值
+
+字段为空且没有 required 时跳过其他规则；嵌入（匿名）的结构体会递归校验。
+错误中的字段名使用 json 标签的名称，方便前端对应到表单。
+*/
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a struct", v)
+	}
+	var errs Errors
+	if err := validateStruct(rv, &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// FieldError 一个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors 所有字段的校验错误
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+var (
+	patternsMu sync.RWMutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+// RegisterPattern 注册 pattern 规则使用的正则，一般在包初始化时调用
+func RegisterPattern(name, expr string) {
+	re := regexp.MustCompile(expr)
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	patterns[name] = re
+}
+
+func validateStruct(rv reflect.Value, errs *Errors) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := rv.Field(i)
+		if f.Anonymous && fv.Kind() == reflect.Struct {
+			if err := validateStruct(fv, errs); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "" || !f.IsExported() {
+			continue
+		}
+		if err := validateField(fieldName(f), fv, tag, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName json 标签中的名称，没有时使用字段名
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func validateField(name string, fv reflect.Value, tag string, errs *Errors) error {
+	rules := strings.Split(tag, ",")
+	empty := isEmpty(fv)
+	for _, rule := range rules {
+		if rule == "required" && empty {
+			*errs = append(*errs, FieldError{Field: name, Rule: "required", Message: "is required"})
+			return nil
+		}
+	}
+	if empty {
+		return nil
+	}
+	for _, rule := range rules {
+		key, param, _ := strings.Cut(rule, "=")
+		msg, err := check(key, param, fv)
+		if err != nil {
+			return fmt.Errorf("validate: field %s: %w", name, err)
+		}
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Rule: key, Message: msg})
+			// 一个字段只报告第一个错误
+			return nil
+		}
+	}
+	return nil
+}
+
+// check 校验一个规则，不通过时返回错误描述；规则本身写错时返回 error（编程错误）
+func check(rule, param string, fv reflect.Value) (string, error) {
+	switch rule {
+	case "required":
+		return "", nil
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s parameter %q", rule, param)
+		}
+		size, unit, ok := measure(fv)
+		if !ok {
+			return "", fmt.Errorf("%s does not apply to %s", rule, fv.Kind())
+		}
+		switch {
+		case rule == "min" && size < n:
+			return fmt.Sprintf("must be at least %s%s", param, unit), nil
+		case rule == "max" && size > n:
+			return fmt.Sprintf("must be at most %s%s", param, unit), nil
+		case rule == "len" && size != n:
+			return fmt.Sprintf("must be exactly %s%s", param, unit), nil
+		}
+		return "", nil
+	case "email":
+		s, ok := stringOf(fv)
+		if !ok {
+			return "", fmt.Errorf("email does not apply to %s", fv.Kind())
+		}
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+	case "pattern":
+		patternsMu.RLock()
+		re := patterns[param]
+		patternsMu.RUnlock()
+		if re == nil {
+			return "", fmt.Errorf("unknown pattern %q", param)
+		}
+		s, ok := stringOf(fv)
+		if !ok {
+			return "", fmt.Errorf("pattern does not apply to %s", fv.Kind())
+		}
+		if !re.MatchString(s) {
+			return "has an invalid format", nil
+		}
+		return "", nil
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		options := strings.Split(param, "|")
+		for _, o := range options {
+			if s == o {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.Join(options, ", "), nil
+	}
+	return "", fmt.Errorf("unknown rule %q", rule)
+}
+
+// measure 字符串字符数、数字的值或集合的长度
+func measure(fv reflect.Value) (float64, string, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), " characters", true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), "", true
+	case reflect.Pointer:
+		return measure(fv.Elem())
+	}
+	return 0, "", false
+}
+
+func stringOf(fv reflect.Value) (string, bool) {
+	if fv.Kind() == reflect.Pointer {
+		fv = fv.Elem()
+	}
+	if fv.Kind() != reflect.String {
+		return "", false
+	}
+	return fv.String(), true
+}
+
+func isEmpty(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(fv.String()) == ""
+	case reflect.Pointer, reflect.Interface:
+		return fv.IsNil()
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
@@ -0,0 +1,98 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type base struct {
+	Status string `json:"status" validate:"oneof=active|disabled"`
+}
+
+type form struct {
+	base
+	Username string   `json:"username" validate:"required,min=3,max=8,pattern=username"`
+	Email    string   `json:"email" validate:"email"`
+	Age      int      `json:"age" validate:"min=0,max=150"`
+	Roles    []string `json:"roles" validate:"max=2"`
+	Code     *string  `json:"code" validate:"len=4"`
+	Note     string   `validate:"max=2"`
+}
+
+func TestStruct(t *testing.T) {
+	RegisterPattern("username", `^[a-z0-9_]+$`)
+	code := "12345"
+	tests := []struct {
+		name string
+		v    interface{}
+		want Errors
+	}{
+		{name: "valid", v: &form{Username: "meta_39", Email: "m@example.com", Age: 18}},
+		{name: "empty optional fields are skipped", v: form{Username: "meta"}},
+		{name: "required", v: &form{Username: "   "}, want: Errors{{Field: "username", Rule: "required", Message: "is required"}}},
+		{name: "min counts characters", v: &form{Username: "张三"}, want: Errors{{Field: "username", Rule: "min", Message: "must be at least 3 characters"}}},
+		{name: "only the first error per field", v: &form{Username: "ABCDEFGHIJ"}, want: Errors{{Field: "username", Rule: "max", Message: "must be at most 8 characters"}}},
+		{name: "pattern", v: &form{Username: "Meta"}, want: Errors{{Field: "username", Rule: "pattern", Message: "has an invalid format"}}},
+		{name: "all fields", v: &form{
+			base:     base{Status: "deleted"},
+			Username: "meta",
+			Email:    "Meta <m@example.com>",
+			Age:      200,
+			Roles:    []string{"a", "b", "c"},
+			Code:     &code,
+			Note:     "abc",
+		}, want: Errors{
+			{Field: "status", Rule: "oneof", Message: "must be one of active, disabled"},
+			{Field: "email", Rule: "email", Message: "must be a valid email address"},
+			{Field: "age", Rule: "max", Message: "must be at most 150"},
+			{Field: "roles", Rule: "max", Message: "must be at most 2 items"},
+			{Field: "code", Rule: "len", Message: "must be exactly 4 characters"},
+			{Field: "Note", Rule: "max", Message: "must be at most 2 characters"},
+		}},
+		{name: "nil pointer", v: (*form)(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("Struct() = %v, want Errors", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructProgrammingErrors(t *testing.T) {
+	tests := []interface{}{
+		"not a struct",
+		&struct {
+			Name string `validate:"unknown"`
+		}{Name: "x"},
+		&struct {
+			Name string `validate:"pattern=missing"`
+		}{Name: "x"},
+		&struct {
+			Age int `validate:"email"`
+		}{Age: 1},
+		&struct {
+			Name string `validate:"min=abc"`
+		}{Name: "x"},
+	}
+	for _, v := range tests {
+		err := Struct(v)
+		var fieldErrs Errors
+		if err == nil || errors.As(err, &fieldErrs) {
+			t.Errorf("Struct(%#v) = %v, want a programming error", v, err)
+		}
+	}
+}